@@ -2,12 +2,15 @@ package config
 
 import (
 	"os"
+	"strconv"
 )
 
 type Config struct {
 	Database    DatabaseConfig
 	JWT         JWTConfig
 	Server      ServerConfig
+	Submission  SubmissionConfig
+	Judge       JudgeConfig
 	Environment string
 }
 
@@ -31,6 +34,22 @@ type ServerConfig struct {
 	Port string
 }
 
+// SubmissionConfig ограничения частоты отправки решений
+type SubmissionConfig struct {
+	UserRatePerMinute    int    // пополнение токенов пользователя в минуту, 0 отключает ограничение
+	UserBurst            int    // максимальный запас токенов пользователя
+	ProblemRatePerMinute int    // пополнение токенов пользователя по одной задаче в минуту, 0 отключает ограничение
+	ProblemBurst         int    // максимальный запас токенов по одной задаче
+	DuplicateWindow      string // окно поиска повторной отправки того же кода
+}
+
+// JudgeConfig параметры сторожа зависших проверок
+type JudgeConfig struct {
+	StuckAfter       string // через сколько отправка в pending/running считается зависшей
+	WatchdogInterval string // период проверки
+	MaxAttempts      int    // число запусков проверки, после которого отправка получает system_error
+}
+
 func Load() *Config {
 	return &Config{
 		Database: DatabaseConfig{
@@ -50,6 +69,18 @@ func Load() *Config {
 			Host: getEnv("SERVER_HOST", "localhost"),
 			Port: getEnv("SERVER_PORT", "8080"),
 		},
+		Submission: SubmissionConfig{
+			UserRatePerMinute:    getEnvInt("SUBMISSION_USER_RATE_PER_MINUTE", 10),
+			UserBurst:            getEnvInt("SUBMISSION_USER_BURST", 5),
+			ProblemRatePerMinute: getEnvInt("SUBMISSION_PROBLEM_RATE_PER_MINUTE", 4),
+			ProblemBurst:         getEnvInt("SUBMISSION_PROBLEM_BURST", 3),
+			DuplicateWindow:      getEnv("SUBMISSION_DUPLICATE_WINDOW", "10m"),
+		},
+		Judge: JudgeConfig{
+			StuckAfter:       getEnv("JUDGE_STUCK_AFTER", "10m"),
+			WatchdogInterval: getEnv("JUDGE_WATCHDOG_INTERVAL", "1m"),
+			MaxAttempts:      getEnvInt("JUDGE_MAX_ATTEMPTS", 3),
+		},
 		Environment: getEnv("ENVIRONMENT", "development"),
 	}
 }
@@ -59,4 +90,13 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
@@ -47,12 +47,32 @@ func Migrate(db *gorm.DB) error {
 		&models.Section{},
 		&models.Lesson{},
 		&models.Problem{},
+		&models.ProblemHint{},
+		&models.ProblemUnlock{},
+		&models.ProblemDraft{},
+		&models.ProblemList{},
+		&models.ProblemListItem{},
+		&models.Contest{},
+		&models.ContestProblem{},
+		&models.ContestRegistration{},
+		&models.Challenge{},
+		&models.ChallengeCompletion{},
+		&models.CodeReview{},
+		&models.ReviewComment{},
+		&models.Notification{},
+		&models.DiscussionThread{},
+		&models.DiscussionPost{},
+		&models.DiscussionVote{},
 		&models.Test{},
 		&models.TestQuestion{},
 		&models.TestAnswer{},
+		&models.QuestionBank{},
+		&models.TestRule{},
 		&models.UserProgress{},
 		&models.UserSubmission{},
 		&models.UserTestResult{},
+		&models.TestAttemptAnswer{},
+		&models.PlacementAttempt{},
 		&models.Certificate{},
 		&models.RefreshToken{},
 		&models.Feature{},
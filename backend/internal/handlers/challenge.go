@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-education-platform/internal/middleware"
+	"go-education-platform/internal/models"
+	"go-education-platform/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChallengeHandler struct {
+	challengeService *services.ChallengeService
+}
+
+func NewChallengeHandler(challengeService *services.ChallengeService) *ChallengeHandler {
+	return &ChallengeHandler{challengeService: challengeService}
+}
+
+// GetDailyChallenge возвращает задачу дня
+func (h *ChallengeHandler) GetDailyChallenge(c *gin.Context) {
+	h.getCurrentChallenge(c, models.ChallengeKindDaily)
+}
+
+// GetWeeklyChallenge возвращает задачу недели
+func (h *ChallengeHandler) GetWeeklyChallenge(c *gin.Context) {
+	h.getCurrentChallenge(c, models.ChallengeKindWeekly)
+}
+
+func (h *ChallengeHandler) getCurrentChallenge(c *gin.Context, kind models.ChallengeKind) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	challenge, err := h.challengeService.GetCurrentChallenge(userID, kind)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, challenge)
+}
+
+// GetStreak возвращает серию решённых подряд задач дня
+func (h *ChallengeHandler) GetStreak(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	streak, err := h.challengeService.GetStreak(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, streak)
+}
+
+// Admin methods
+
+// GetChallenges возвращает расписание задач-вызовов (админ). По умолчанию — на 30 дней вперёд
+func (h *ChallengeHandler) GetChallenges(c *gin.Context) {
+	now := time.Now()
+	from, err := time.Parse("2006-01-02", c.DefaultQuery("from", now.Format("2006-01-02")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверная дата from"})
+		return
+	}
+	to, err := time.Parse("2006-01-02", c.DefaultQuery("to", now.AddDate(0, 0, 30).Format("2006-01-02")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверная дата to"})
+		return
+	}
+
+	challenges, err := h.challengeService.GetChallenges(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, challenges)
+}
+
+// ScheduleChallenge назначает задачу дня или недели (админ)
+func (h *ChallengeHandler) ScheduleChallenge(c *gin.Context) {
+	var req services.ScheduleChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	challenge, err := h.challengeService.ScheduleChallenge(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, challenge)
+}
+
+// DeleteChallenge удаляет назначенную задачу-вызов, которую ещё никто не решил (админ)
+func (h *ChallengeHandler) DeleteChallenge(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	if err := h.challengeService.DeleteChallenge(uint(id)); err != nil {
+		if errors.Is(err, services.ErrChallengeCompleted) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "задача-вызов удалена"})
+}
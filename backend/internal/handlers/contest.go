@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"go-education-platform/internal/middleware"
+	"go-education-platform/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ContestHandler struct {
+	contestService    *services.ContestService
+	problemService    *services.ProblemService
+	submissionLimiter *services.SubmissionLimiter
+}
+
+func NewContestHandler(contestService *services.ContestService, problemService *services.ProblemService, submissionLimiter *services.SubmissionLimiter) *ContestHandler {
+	return &ContestHandler{
+		contestService:    contestService,
+		problemService:    problemService,
+		submissionLimiter: submissionLimiter,
+	}
+}
+
+func (h *ContestHandler) GetContests(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	contests, total, err := h.contestService.GetContests(page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"contests": contests,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+func (h *ContestHandler) GetContest(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	contest, err := h.contestService.GetContest(uint(id), userID, isAdmin(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, contest)
+}
+
+func (h *ContestHandler) Register(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	if err := h.contestService.Register(uint(id), userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "вы зарегистрированы на соревнование"})
+}
+
+// SubmitSolution отправляет решение задачи соревнования на проверку общим судьёй
+func (h *ContestHandler) SubmitSolution(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+	contestID := uint(id)
+
+	var req services.SubmitSolutionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	contestProblem, err := h.contestService.ContestProblemForSubmission(contestID, userID, c.Param("label"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Повторная отправка того же кода не запускает проверку заново
+	duplicate, err := h.problemService.FindDuplicateSubmission(userID, contestProblem.ProblemID, &contestID, req.Code, h.submissionLimiter.DuplicateWindow())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if duplicate != nil {
+		c.Header("X-Duplicate-Submission", "true")
+		c.JSON(http.StatusOK, duplicate)
+		return
+	}
+
+	if allowed, wait := h.submissionLimiter.Allow(userID, contestProblem.ProblemID); !allowed {
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "слишком много отправок, повторите попытку позже",
+			"retry_after": retryAfter,
+		})
+		return
+	}
+
+	submission, err := h.problemService.CreateSubmission(userID, contestProblem.ProblemID, &contestID, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Выполняем код в sandbox
+	go h.problemService.JudgeSubmission(submission, &contestProblem.Problem)
+
+	c.JSON(http.StatusCreated, submission)
+}
+
+func (h *ContestHandler) GetUserSubmissions(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	submissions, err := h.contestService.GetUserSubmissions(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, submissions)
+}
+
+func (h *ContestHandler) GetScoreboard(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	scoreboard, err := h.contestService.GetScoreboard(uint(id), isAdmin(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, scoreboard)
+}
+
+// Admin methods
+
+func (h *ContestHandler) CreateContest(c *gin.Context) {
+	var req services.CreateContestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	contest, err := h.contestService.CreateContest(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, contest)
+}
+
+func (h *ContestHandler) UpdateContest(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	var req services.UpdateContestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	contest, err := h.contestService.UpdateContest(uint(id), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, contest)
+}
+
+func (h *ContestHandler) DeleteContest(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	if err := h.contestService.DeleteContest(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "соревнование удалено"})
+}
+
+func (h *ContestHandler) UnfreezeScoreboard(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	if err := h.contestService.UnfreezeScoreboard(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "таблица результатов разморожена"})
+}
+
+// isAdmin проверяет роль администратора у текущего пользователя
+func isAdmin(c *gin.Context) bool {
+	role, exists := middleware.GetUserRoleFromContext(c)
+	return exists && role == "admin"
+}
@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-education-platform/internal/middleware"
+	"go-education-platform/internal/models"
+	"go-education-platform/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DiscussionHandler struct {
+	discussionService *services.DiscussionService
+}
+
+func NewDiscussionHandler(discussionService *services.DiscussionService) *DiscussionHandler {
+	return &DiscussionHandler{discussionService: discussionService}
+}
+
+// GetProblemThreads возвращает обсуждения задачи
+func (h *DiscussionHandler) GetProblemThreads(c *gin.Context) {
+	h.getThreads(c, models.DiscussionEntityProblem)
+}
+
+// GetLessonThreads возвращает обсуждения урока
+func (h *DiscussionHandler) GetLessonThreads(c *gin.Context) {
+	h.getThreads(c, models.DiscussionEntityLesson)
+}
+
+func (h *DiscussionHandler) getThreads(c *gin.Context, entityType models.DiscussionEntityType) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	threads, total, err := h.discussionService.GetThreads(entityType, uint(id), userID, role, c.Query("sort"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"threads": threads,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// CreateProblemThread открывает обсуждение задачи
+func (h *DiscussionHandler) CreateProblemThread(c *gin.Context) {
+	h.createThread(c, models.DiscussionEntityProblem)
+}
+
+// CreateLessonThread открывает обсуждение урока
+func (h *DiscussionHandler) CreateLessonThread(c *gin.Context) {
+	h.createThread(c, models.DiscussionEntityLesson)
+}
+
+func (h *DiscussionHandler) createThread(c *gin.Context, entityType models.DiscussionEntityType) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	var req services.CreateThreadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	thread, err := h.discussionService.CreateThread(entityType, uint(id), userID, role, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, thread)
+}
+
+func (h *DiscussionHandler) GetThread(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	thread, err := h.discussionService.GetThread(uint(id), userID, role)
+	if err != nil {
+		c.JSON(discussionErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, thread)
+}
+
+func (h *DiscussionHandler) UpdateThread(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	var req services.UpdateThreadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	thread, err := h.discussionService.UpdateThread(uint(id), userID, role, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, thread)
+}
+
+// AddPost добавляет ответ в тему
+func (h *DiscussionHandler) AddPost(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	var req services.CreatePostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	post, err := h.discussionService.AddPost(uint(id), userID, role, &req)
+	if err != nil {
+		c.JSON(discussionErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, post)
+}
+
+// AcceptAnswer отмечает принятый ответ в теме
+func (h *DiscussionHandler) AcceptAnswer(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	var req services.AcceptAnswerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	if err := h.discussionService.AcceptAnswer(uint(id), userID, role, req.PostID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "принятый ответ обновлён"})
+}
+
+func (h *DiscussionHandler) UpdatePost(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("postId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID сообщения"})
+		return
+	}
+
+	var req services.UpdatePostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	post, err := h.discussionService.UpdatePost(uint(id), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, post)
+}
+
+func (h *DiscussionHandler) DeletePost(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("postId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID сообщения"})
+		return
+	}
+
+	if err := h.discussionService.DeletePost(uint(id), userID, role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "сообщение удалено"})
+}
+
+// Vote голосует за сообщение и возвращает его новый рейтинг
+func (h *DiscussionHandler) Vote(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("postId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID сообщения"})
+		return
+	}
+
+	var req services.VoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	score, err := h.discussionService.Vote(uint(id), userID, role, *req.Value)
+	if err != nil {
+		c.JSON(discussionErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"score": score, "my_vote": *req.Value})
+}
+
+// Admin methods
+
+// ModerateThread закрепляет или закрывает тему
+func (h *DiscussionHandler) ModerateThread(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	var req services.ModerateThreadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	thread, err := h.discussionService.ModerateThread(uint(id), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, thread)
+}
+
+func (h *DiscussionHandler) DeleteThread(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	if err := h.discussionService.DeleteThread(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "тема удалена"})
+}
+
+// discussionErrorStatus возвращает 403 для закрытых спойлером тем и fallback для остальных ошибок
+func discussionErrorStatus(err error, fallback int) int {
+	if errors.Is(err, services.ErrSpoilerLocked) {
+		return http.StatusForbidden
+	}
+	return fallback
+}
@@ -1,11 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
 
 	"go-education-platform/internal/middleware"
-	"go-education-platform/internal/models"
 	"go-education-platform/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -191,7 +192,17 @@ func (h *CourseHandler) GetCourseSections(c *gin.Context) {
 		return
 	}
 
-	sections, err := h.courseService.GetCourseSections(uint(id))
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	sections, err := h.courseService.GetCourseSections(uint(id), userID)
+	if errors.Is(err, services.ErrCourseLocked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -207,7 +218,17 @@ func (h *CourseHandler) GetLesson(c *gin.Context) {
 		return
 	}
 
-	lesson, err := h.courseService.GetLessonByID(uint(id))
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	lesson, err := h.courseService.GetLessonByID(uint(id), userID)
+	if errors.Is(err, services.ErrCourseLocked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -377,14 +398,16 @@ func (h *CourseHandler) DeleteLesson(c *gin.Context) {
 }
 
 type ProblemHandler struct {
-	problemService *services.ProblemService
-	sandboxService *services.SandboxService
+	problemService    *services.ProblemService
+	sandboxService    *services.SandboxService
+	submissionLimiter *services.SubmissionLimiter
 }
 
-func NewProblemHandler(problemService *services.ProblemService, sandboxService *services.SandboxService) *ProblemHandler {
+func NewProblemHandler(problemService *services.ProblemService, sandboxService *services.SandboxService, submissionLimiter *services.SubmissionLimiter) *ProblemHandler {
 	return &ProblemHandler{
-		problemService: problemService,
-		sandboxService: sandboxService,
+		problemService:    problemService,
+		sandboxService:    sandboxService,
+		submissionLimiter: submissionLimiter,
 	}
 }
 
@@ -449,39 +472,37 @@ func (h *ProblemHandler) SubmitSolution(c *gin.Context) {
 		return
 	}
 
-	// Создаём отправку
-	submission, err := h.problemService.CreateSubmission(userID, uint(id), req.Code)
+	// Повторная отправка того же кода не запускает проверку заново
+	duplicate, err := h.problemService.FindDuplicateSubmission(userID, uint(id), nil, req.Code, h.submissionLimiter.DuplicateWindow())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if duplicate != nil {
+		c.Header("X-Duplicate-Submission", "true")
+		c.JSON(http.StatusOK, duplicate)
+		return
+	}
 
-	// Выполняем код в sandbox
-	go func() {
-		execResult, err := h.sandboxService.ExecuteSubmission(submission, problem)
-		if err != nil {
-			// Обновляем статус как ошибка системы
-			result := &services.SubmissionResult{
-				Status:      models.SubmissionStatusRuntimeError,
-				ErrorOutput: err.Error(),
-			}
-			h.problemService.UpdateSubmissionResult(submission.ID, result)
-			return
-		}
+	if allowed, wait := h.submissionLimiter.Allow(userID, uint(id)); !allowed {
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "слишком много отправок, повторите попытку позже",
+			"retry_after": retryAfter,
+		})
+		return
+	}
 
-		// Обновляем результат
-		result := &services.SubmissionResult{
-			Status:        execResult.Status,
-			Score:         execResult.Score,
-			TestsPassed:   execResult.TestsPassed,
-			TestsTotal:    execResult.TestsTotal,
-			ExecutionTime: execResult.ExecutionTime,
-			MemoryUsed:    execResult.MemoryUsed,
-			ErrorOutput:   execResult.ErrorOutput,
-		}
+	// Создаём отправку
+	submission, err := h.problemService.CreateSubmission(userID, uint(id), nil, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-		h.problemService.UpdateSubmissionResult(submission.ID, result)
-	}()
+	// Выполняем код в sandbox
+	go h.problemService.JudgeSubmission(submission, problem)
 
 	c.JSON(http.StatusCreated, submission)
 }
@@ -609,7 +630,7 @@ func (h *TestHandler) GetTests(c *gin.Context) {
 	})
 }
 
-// GetTest получает тест по ID с вопросами и ответами
+// GetTest получает описание теста без вопросов
 func (h *TestHandler) GetTest(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -617,7 +638,7 @@ func (h *TestHandler) GetTest(c *gin.Context) {
 		return
 	}
 	
-	test, err := h.testService.GetTestByID(uint(id))
+	test, err := h.testService.GetTest(uint(id))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "тест не найден"})
 		return
@@ -626,7 +647,7 @@ func (h *TestHandler) GetTest(c *gin.Context) {
 	c.JSON(http.StatusOK, test)
 }
 
-// StartTest запускает тест для пользователя
+// StartTest начинает или продолжает попытку прохождения теста
 func (h *TestHandler) StartTest(c *gin.Context) {
 	userID, exists := middleware.GetUserIDFromContext(c)
 	if !exists {
@@ -640,15 +661,20 @@ func (h *TestHandler) StartTest(c *gin.Context) {
 		return
 	}
 	
-	// TODO: Implement test start logic
-	c.JSON(http.StatusOK, gin.H{
-		"message": "тест начат",
-		"test_id": testID,
-		"user_id": userID,
-	})
+	attempt, err := h.testService.StartTest(uint(testID), userID)
+	if errors.Is(err, services.ErrTestAttemptsExhausted) || errors.Is(err, services.ErrTestCooldown) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	c.JSON(http.StatusOK, attempt)
 }
 
-// SubmitTest отправляет результаты теста
+// SubmitTest отправляет ответы и завершает текущую попытку
 func (h *TestHandler) SubmitTest(c *gin.Context) {
 	userID, exists := middleware.GetUserIDFromContext(c)
 	if !exists {
@@ -662,12 +688,56 @@ func (h *TestHandler) SubmitTest(c *gin.Context) {
 		return
 	}
 	
-	// TODO: Implement test submission logic
-	c.JSON(http.StatusOK, gin.H{
-		"message": "результаты теста отправлены",
-		"test_id": testID,
-		"user_id": userID,
-	})
+	var req services.SubmitTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	result, err := h.testService.SubmitTest(uint(testID), userID, req)
+	if errors.Is(err, services.ErrTestTimeExpired) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	c.JSON(http.StatusOK, result)
+}
+
+// SaveTestAnswers автосохраняет ответы текущей попытки
+func (h *TestHandler) SaveTestAnswers(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+	
+	testID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID теста"})
+		return
+	}
+	
+	var req services.SaveTestAnswersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	result, err := h.testService.SaveTestAnswers(uint(testID), userID, req)
+	if errors.Is(err, services.ErrTestTimeExpired) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	c.JSON(http.StatusOK, result)
 }
 
 // GetTestResults получает результаты конкретного теста
@@ -684,12 +754,13 @@ func (h *TestHandler) GetTestResults(c *gin.Context) {
 		return
 	}
 	
-	// TODO: Implement test results retrieval logic
-	c.JSON(http.StatusOK, gin.H{
-		"message": "результаты теста",
-		"test_id": testID,
-		"user_id": userID,
-	})
+	results, standing, err := h.testService.GetTestResults(uint(testID), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{"results": results, "standing": standing})
 }
 
 // GetUserTestResults получает все результаты тестов пользователя
@@ -700,28 +771,119 @@ func (h *TestHandler) GetUserTestResults(c *gin.Context) {
 		return
 	}
 	
-	// TODO: Implement user test results retrieval logic
-	c.JSON(http.StatusOK, gin.H{
-		"message": "результаты всех тестов пользователя",
-		"user_id": userID,
-	})
+	results, err := h.testService.GetUserTestResults(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// GetTestForAdmin получает тест с верными ответами и разборами (админ)
+func (h *TestHandler) GetTestForAdmin(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID теста"})
+		return
+	}
+	
+	test, err := h.testService.GetTestForAdmin(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	
+	c.JSON(http.StatusOK, test)
+}
+
+// GetTestsForAdmin получает все тесты, включая неактивные (админ)
+func (h *TestHandler) GetTestsForAdmin(c *gin.Context) {
+	tests, err := h.testService.GetTestsForAdmin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{"tests": tests})
 }
 
-// CreateTest создаёт новый тест (админ)
+// CreateTest создаёт новый тест с вопросами (админ)
 func (h *TestHandler) CreateTest(c *gin.Context) {
-	// TODO: Implement test creation logic
-	c.JSON(http.StatusOK, gin.H{"message": "тест создан"})
+	var req services.CreateTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+	
+	test, err := h.testService.CreateTest(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	c.JSON(http.StatusCreated, test)
 }
 
-// UpdateTest обновляет тест (админ)
+// UpdateTest обновляет тест и его вопросы (админ)
 func (h *TestHandler) UpdateTest(c *gin.Context) {
-	// TODO: Implement test update logic
-	c.JSON(http.StatusOK, gin.H{"message": "тест обновлён"})
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID теста"})
+		return
+	}
+	
+	var req services.UpdateTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+	
+	test, err := h.testService.UpdateTest(uint(id), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	c.JSON(http.StatusOK, test)
+}
+
+// ReorderQuestions меняет порядок вопросов теста (админ)
+func (h *TestHandler) ReorderQuestions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID теста"})
+		return
+	}
+	
+	var req services.ReorderTestQuestionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+	
+	test, err := h.testService.ReorderQuestions(uint(id), req.QuestionIDs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	c.JSON(http.StatusOK, test)
 }
 
-// DeleteTest удаляет тест (админ)
+// DeleteTest удаляет тест, сохраняя результаты пользователей (админ)
 func (h *TestHandler) DeleteTest(c *gin.Context) {
-	// TODO: Implement test deletion logic
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID теста"})
+		return
+	}
+	
+	if err := h.testService.DeleteTest(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	
 	c.JSON(http.StatusOK, gin.H{"message": "тест удалён"})
 }
 
@@ -977,6 +1139,14 @@ func (h *CertificateHandler) GenerateCertificate(c *gin.Context) {
 	}
 	
 	certificate, err := h.certificateService.GenerateCertificate(userID, &req)
+	if errors.Is(err, services.ErrCertificateNotEligible) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrCertificateIssued) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка генерации сертификата"})
 		return
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-education-platform/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JudgeHandler struct {
+	judgeHealthService *services.JudgeHealthService
+}
+
+func NewJudgeHandler(judgeHealthService *services.JudgeHealthService) *JudgeHandler {
+	return &JudgeHandler{judgeHealthService: judgeHealthService}
+}
+
+// GetHealth проверяет состояние проверяющей системы. При сбое любой проверки
+// возвращает 503, чтобы эндпоинт можно было использовать в мониторинге
+func (h *JudgeHandler) GetHealth(c *gin.Context) {
+	health, err := h.judgeHealthService.Check()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	if !health.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, health)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-education-platform/internal/middleware"
+	"go-education-platform/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PlacementHandler struct {
+	placementService *services.PlacementService
+}
+
+func NewPlacementHandler(placementService *services.PlacementService) *PlacementHandler {
+	return &PlacementHandler{placementService: placementService}
+}
+
+// GetPlacement возвращает состояние вступительного теста
+func (h *PlacementHandler) GetPlacement(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	placement, err := h.placementService.GetPlacement(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, placement)
+}
+
+// StartPlacement начинает или продолжает вступительный тест
+func (h *PlacementHandler) StartPlacement(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	placement, err := h.placementService.StartPlacement(userID)
+	if errors.Is(err, services.ErrPlacementCompleted) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, placement)
+}
+
+// AnswerPlacement принимает ответ на текущий вопрос вступительного теста
+func (h *PlacementHandler) AnswerPlacement(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	var req services.SubmittedTestAnswer
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	placement, err := h.placementService.AnswerPlacement(userID, req)
+	if errors.Is(err, services.ErrPlacementCompleted) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, placement)
+}
+
+// ApplyPlacement устанавливает уровень пользователя по результату вступительного теста
+func (h *PlacementHandler) ApplyPlacement(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	placement, err := h.placementService.ApplyPlacement(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, placement)
+}
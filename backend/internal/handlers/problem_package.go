@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPackageUploadSize ограничивает размер загружаемого пакета задачи
+const maxPackageUploadSize = 64 << 20
+
+// ImportProblemPackage импортирует задачу из zip-пакета (админ)
+// @Summary Импорт пакета задачи
+// @Description Принимает zip-архив в собственном формате, Kattis или Polygon. Эталонное решение проверяется на всех тестах перед публикацией
+// @Tags admin
+// @Accept multipart/form-data
+// @Produce json
+// @Param package formData file true "zip-архив пакета"
+// @Param publish query bool false "опубликовать задачу после проверки (по умолчанию true)"
+// @Success 201 {object} services.PackageImportReport
+// @Failure 400 {object} map[string]interface{}
+// @Router /api/admin/problems/import [post]
+func (h *ProblemHandler) ImportProblemPackage(c *gin.Context) {
+	fileHeader, err := c.FormFile("package")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "не передан файл пакета", "details": err.Error()})
+		return
+	}
+	if fileHeader.Size > maxPackageUploadSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "пакет слишком большой"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ошибка чтения пакета", "details": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxPackageUploadSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ошибка чтения пакета", "details": err.Error()})
+		return
+	}
+
+	publish := c.DefaultQuery("publish", "true") != "false"
+
+	report, err := h.problemService.ImportProblemPackage(data, publish)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// ExportProblemPackage выгружает задачу в zip-пакет (админ)
+// @Summary Экспорт пакета задачи
+// @Tags admin
+// @Produce application/zip
+// @Param id path int true "ID задачи"
+// @Param format query string false "native или kattis"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]interface{}
+// @Router /api/admin/problems/{id}/export [get]
+func (h *ProblemHandler) ExportProblemPackage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "native")
+	data, err := h.problemService.ExportProblemPackage(uint(id), format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("problem-%d-%s.zip", id, format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/zip", data)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-education-platform/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetQuestionBanks получает список банков вопросов (админ)
+func (h *TestHandler) GetQuestionBanks(c *gin.Context) {
+	banks, err := h.testService.GetQuestionBanks()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"banks": banks})
+}
+
+// GetQuestionBank получает банк вопросов с верными ответами (админ)
+func (h *TestHandler) GetQuestionBank(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID банка вопросов"})
+		return
+	}
+
+	bank, err := h.testService.GetQuestionBank(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, bank)
+}
+
+// CreateQuestionBank создаёт банк вопросов (админ)
+func (h *TestHandler) CreateQuestionBank(c *gin.Context) {
+	var req services.CreateQuestionBankRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	bank, err := h.testService.CreateQuestionBank(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, bank)
+}
+
+// UpdateQuestionBank обновляет банк вопросов (админ)
+func (h *TestHandler) UpdateQuestionBank(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID банка вопросов"})
+		return
+	}
+
+	var req services.UpdateQuestionBankRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	bank, err := h.testService.UpdateQuestionBank(uint(id), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, bank)
+}
+
+// DeleteQuestionBank удаляет неиспользуемый банк вопросов (админ)
+func (h *TestHandler) DeleteQuestionBank(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID банка вопросов"})
+		return
+	}
+
+	if err := h.testService.DeleteQuestionBank(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "банк вопросов удалён"})
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go-education-platform/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxQuizUploadSize ограничивает размер загружаемого файла с вопросами
+const maxQuizUploadSize = 8 << 20
+
+// quizFileTypes расширение и Content-Type выгрузки по формату
+var quizFileTypes = map[string][2]string{
+	services.QuizFormatGIFT:      {"gift", "text/plain; charset=utf-8"},
+	services.QuizFormatMoodleXML: {"xml", "application/xml; charset=utf-8"},
+	services.QuizFormatCSV:       {"csv", "text/csv; charset=utf-8"},
+}
+
+// ImportTest импортирует тест из GIFT, Moodle XML или CSV (админ)
+// @Summary Импорт теста
+// @Description Создаёт неактивный тест из файла. При dry_run тест не создаётся, отчёт показывает вопросы и конструкции, которые не удалось перенести
+// @Tags admin
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "файл с вопросами"
+// @Param format query string false "gift, moodle_xml или csv (по умолчанию по расширению и содержимому)"
+// @Param title query string false "название теста"
+// @Param dry_run query bool false "только проверить файл"
+// @Success 201 {object} services.QuizImportReport
+// @Failure 400 {object} map[string]interface{}
+// @Router /api/admin/tests/import [post]
+func (h *TestHandler) ImportTest(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "не передан файл", "details": err.Error()})
+		return
+	}
+	if fileHeader.Size > maxQuizUploadSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "файл слишком большой"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ошибка чтения файла", "details": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxQuizUploadSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ошибка чтения файла", "details": err.Error()})
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+		case ".gift":
+			format = services.QuizFormatGIFT
+		case ".xml":
+			format = services.QuizFormatMoodleXML
+		case ".csv":
+			format = services.QuizFormatCSV
+		}
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	report, err := h.testService.ImportTest(data, format, c.Query("title"), dryRun)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	}
+	c.JSON(status, report)
+}
+
+// ExportTest выгружает вопросы теста в GIFT, Moodle XML или CSV (админ)
+// @Summary Экспорт теста
+// @Tags admin
+// @Produce plain
+// @Param id path int true "ID теста"
+// @Param format query string false "gift, moodle_xml или csv (по умолчанию gift)"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]interface{}
+// @Router /api/admin/tests/{id}/export [get]
+func (h *TestHandler) ExportTest(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	format := c.DefaultQuery("format", services.QuizFormatGIFT)
+	fileType, ok := quizFileTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("неизвестный формат %q", format)})
+		return
+	}
+
+	data, err := h.testService.ExportTest(uint(id), format)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("test-%d.%s", id, fileType[0])
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, fileType[1], data)
+}
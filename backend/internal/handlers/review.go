@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"go-education-platform/internal/middleware"
+	"go-education-platform/internal/models"
+	"go-education-platform/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReviewHandler struct {
+	reviewService     *services.ReviewService
+	problemService    *services.ProblemService
+	submissionLimiter *services.SubmissionLimiter
+}
+
+func NewReviewHandler(reviewService *services.ReviewService, problemService *services.ProblemService, submissionLimiter *services.SubmissionLimiter) *ReviewHandler {
+	return &ReviewHandler{
+		reviewService:     reviewService,
+		problemService:    problemService,
+		submissionLimiter: submissionLimiter,
+	}
+}
+
+// RequestReview открывает ветку ревью по принятой отправке
+func (h *ReviewHandler) RequestReview(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(c)
+
+	var req services.RequestReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	review, err := h.reviewService.RequestReview(req.SubmissionID, userID, role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// GetUserReviews возвращает ревью решений текущего пользователя
+func (h *ReviewHandler) GetUserReviews(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	reviews, err := h.reviewService.GetUserReviews(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reviews)
+}
+
+// GetReview возвращает ветку ревью с ревизиями и комментариями
+func (h *ReviewHandler) GetReview(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	thread, err := h.reviewService.GetReview(uint(id), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, thread)
+}
+
+// AddComment добавляет комментарий в ветку ревью
+func (h *ReviewHandler) AddComment(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	var req services.AddReviewCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	comment, err := h.reviewService.AddComment(uint(id), userID, role, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// Resubmit отправляет исправленное решение в рамках ревью
+func (h *ReviewHandler) Resubmit(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	var req services.SubmitSolutionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+		return
+	}
+
+	thread, err := h.reviewService.GetReview(uint(id), userID, "")
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Повторная отправка того же кода не запускает проверку заново
+	duplicate, err := h.problemService.FindDuplicateSubmission(userID, thread.Review.ProblemID, nil, req.Code, h.submissionLimiter.DuplicateWindow())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if duplicate != nil {
+		c.Header("X-Duplicate-Submission", "true")
+		c.JSON(http.StatusOK, duplicate)
+		return
+	}
+
+	if allowed, wait := h.submissionLimiter.Allow(userID, thread.Review.ProblemID); !allowed {
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "слишком много отправок, повторите попытку позже",
+			"retry_after": retryAfter,
+		})
+		return
+	}
+
+	submission, problem, err := h.reviewService.Resubmit(uint(id), userID, req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Выполняем код в sandbox
+	go h.problemService.JudgeSubmission(submission, problem)
+
+	c.JSON(http.StatusCreated, submission)
+}
+
+// Reviewer methods
+
+// GetReviewQueue возвращает ревью, ожидающие проверки
+func (h *ReviewHandler) GetReviewQueue(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	reviews, total, err := h.reviewService.GetReviewQueue(page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reviews": reviews,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// Approve одобряет решение
+func (h *ReviewHandler) Approve(c *gin.Context) {
+	h.setDecision(c, models.ReviewStatusApproved)
+}
+
+// RequestChanges запрашивает исправления решения
+func (h *ReviewHandler) RequestChanges(c *gin.Context) {
+	h.setDecision(c, models.ReviewStatusChangesRequested)
+}
+
+func (h *ReviewHandler) setDecision(c *gin.Context, status models.ReviewStatus) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "пользователь не авторизован"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID"})
+		return
+	}
+
+	var req services.ReviewDecisionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные", "details": err.Error()})
+			return
+		}
+	}
+
+	review, err := h.reviewService.SetDecision(uint(id), userID, status, req.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
@@ -96,4 +96,27 @@ func GetUserRoleFromContext(c *gin.Context) (string, bool) {
 
 	role, ok := userRole.(string)
 	return role, ok
-}
\ No newline at end of file
+}
+// ReviewerMiddleware проверяет права ревьюера. Администратор также имеет эти права
+func ReviewerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRole, exists := c.Get("user_role")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Пользователь не авторизован",
+			})
+			c.Abort()
+			return
+		}
+
+		if userRole != "reviewer" && userRole != "admin" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Недостаточно прав доступа",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// ChallengeKind определяет периодичность задачи-вызова
+type ChallengeKind string
+
+const (
+	ChallengeKindDaily  ChallengeKind = "daily"  // задача дня
+	ChallengeKindWeekly ChallengeKind = "weekly" // задача недели, Date — понедельник недели
+)
+
+// Challenge задача дня или недели с бонусными баллами за решение в срок
+type Challenge struct {
+	ID          uint          `json:"id" gorm:"primaryKey"`
+	Kind        ChallengeKind `json:"kind" gorm:"not null;uniqueIndex:idx_challenge_kind_date"`
+	Date        time.Time     `json:"date" gorm:"type:date;not null;uniqueIndex:idx_challenge_kind_date"`
+	ProblemID   uint          `json:"problem_id" gorm:"not null;index"`
+	BonusPoints int           `json:"bonus_points" gorm:"default:0"`
+	IsScheduled bool          `json:"is_scheduled" gorm:"default:false"` // назначена администратором, а не выбрана автоматически
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+
+	// Связи
+	Problem Problem `json:"problem,omitempty" gorm:"foreignKey:ProblemID"`
+}
+
+// ChallengeCompletion фиксирует решение задачи-вызова пользователем в срок
+type ChallengeCompletion struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ChallengeID  uint      `json:"challenge_id" gorm:"not null;uniqueIndex:idx_challenge_completion"`
+	UserID       uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_challenge_completion;index"`
+	SubmissionID uint      `json:"submission_id"`
+	BonusAwarded int       `json:"bonus_awarded"`
+	CompletedAt  time.Time `json:"completed_at"`
+
+	// Связи
+	Challenge Challenge `json:"challenge,omitempty" gorm:"foreignKey:ChallengeID"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+// Contest представляет соревнование: набор задач с ограниченным временем решения
+type Contest struct {
+	ID                 uint               `json:"id" gorm:"primaryKey"`
+	Title              string             `json:"title" gorm:"not null"`
+	Description        string             `json:"description" gorm:"type:text"`
+	ScoringType        ContestScoringType `json:"scoring_type" gorm:"default:'icpc'"`
+	StartTime          time.Time          `json:"start_time" gorm:"not null"`
+	EndTime            time.Time          `json:"end_time" gorm:"not null"`
+	FreezeMinutes      int                `json:"freeze_minutes" gorm:"default:0"`   // за сколько минут до конца замораживается таблица
+	PenaltyMinutes     int                `json:"penalty_minutes" gorm:"default:20"` // штраф за каждую неверную попытку
+	ScoreboardUnfrozen bool               `json:"scoreboard_unfrozen" gorm:"default:false"`
+	IsActive           bool               `json:"is_active" gorm:"default:true"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+
+	// Связи
+	Problems      []ContestProblem      `json:"problems,omitempty" gorm:"foreignKey:ContestID"`
+	Registrations []ContestRegistration `json:"-" gorm:"foreignKey:ContestID"`
+}
+
+// ContestScoringType определяет систему подсчёта результатов соревнования
+type ContestScoringType string
+
+const (
+	ContestScoringICPC   ContestScoringType = "icpc"   // число решённых задач и штрафное время
+	ContestScoringPoints ContestScoringType = "points" // сумма баллов с учётом частичных решений
+)
+
+// ContestProblem связывает задачу с соревнованием под буквенной меткой
+type ContestProblem struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	ContestID uint   `json:"contest_id" gorm:"not null;uniqueIndex:idx_contest_problem_label;uniqueIndex:idx_contest_problem_problem"`
+	ProblemID uint   `json:"problem_id" gorm:"not null;uniqueIndex:idx_contest_problem_problem"`
+	Label     string `json:"label" gorm:"size:10;not null;uniqueIndex:idx_contest_problem_label"`
+	Points    int    `json:"points" gorm:"default:0"` // 0 означает баллы самой задачи
+
+	// Связи
+	Problem Problem `json:"problem,omitempty" gorm:"foreignKey:ProblemID"`
+}
+
+// ContestRegistration фиксирует регистрацию пользователя на соревнование
+type ContestRegistration struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ContestID    uint      `json:"contest_id" gorm:"not null;uniqueIndex:idx_contest_registration"`
+	UserID       uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_contest_registration"`
+	RegisteredAt time.Time `json:"registered_at"`
+
+	// Связи
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
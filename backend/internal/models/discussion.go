@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+)
+
+// DiscussionEntityType определяет, к чему привязано обсуждение
+type DiscussionEntityType string
+
+const (
+	DiscussionEntityProblem DiscussionEntityType = "problem"
+	DiscussionEntityLesson  DiscussionEntityType = "lesson"
+)
+
+// DiscussionThread тема обсуждения задачи или урока. Тема с IsSpoiler содержит
+// разбор решения и открывается только после принятого решения задачи.
+// Темы и сообщения переживают удаление автора, поэтому связь с ним не объявлена
+type DiscussionThread struct {
+	ID             uint                 `json:"id" gorm:"primaryKey"`
+	EntityType     DiscussionEntityType `json:"entity_type" gorm:"not null;index:idx_discussion_entity"`
+	EntityID       uint                 `json:"entity_id" gorm:"not null;index:idx_discussion_entity"`
+	AuthorID       uint                 `json:"author_id" gorm:"not null;index"`
+	Title          string               `json:"title" gorm:"not null"`
+	Body           string               `json:"body" gorm:"type:text;not null"` // markdown
+	IsSpoiler      bool                 `json:"is_spoiler" gorm:"default:false"`
+	IsPinned       bool                 `json:"is_pinned" gorm:"default:false"`
+	IsLocked       bool                 `json:"is_locked" gorm:"default:false"` // новые ответы запрещены
+	AcceptedPostID *uint                `json:"accepted_post_id"`
+	PostsCount     int                  `json:"posts_count" gorm:"default:0"`
+	LastActivityAt time.Time            `json:"last_activity_at"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+}
+
+// DiscussionPost сообщение в теме. ParentID задаёт ответ на другое сообщение,
+// nil — ответ на саму тему. Удалённые сообщения остаются в дереве без текста
+type DiscussionPost struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ThreadID  uint      `json:"thread_id" gorm:"not null;index"`
+	ParentID  *uint     `json:"parent_id" gorm:"index"`
+	AuthorID  uint      `json:"author_id" gorm:"not null;index"`
+	Body      string    `json:"body" gorm:"type:text;not null"` // markdown
+	Score     int       `json:"score" gorm:"default:0"`
+	IsDeleted bool      `json:"is_deleted" gorm:"default:false"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DiscussionVote голос пользователя за сообщение: +1 или -1
+type DiscussionVote struct {
+	ID     uint `json:"id" gorm:"primaryKey"`
+	PostID uint `json:"post_id" gorm:"not null;uniqueIndex:idx_discussion_vote"`
+	UserID uint `json:"user_id" gorm:"not null;uniqueIndex:idx_discussion_vote;index"`
+	Value  int  `json:"value" gorm:"not null"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProblemHint представляет подсказку к задаче. Подсказки открываются по порядку
+type ProblemHint struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	ProblemID     uint      `json:"problem_id" gorm:"not null;index"`
+	Content       string    `json:"content" gorm:"type:text;not null"`
+	Order         int       `json:"order" gorm:"default:0"`
+	PointsPenalty int       `json:"points_penalty" gorm:"default:0"` // штраф в баллах за открытие
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"` // удалённая подсказка скрыта, открытия и штрафы по ней сохраняются
+
+	// Связи
+	Problem Problem `json:"problem,omitempty" gorm:"foreignKey:ProblemID"`
+}
+
+// ProblemUnlock фиксирует открытие пользователем подсказки или разбора задачи
+type ProblemUnlock struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"not null;index:idx_problem_unlock_user_problem;uniqueIndex:idx_problem_unlock_hint;uniqueIndex:idx_problem_unlock_editorial,where:is_editorial"`
+	ProblemID   uint      `json:"problem_id" gorm:"not null;index:idx_problem_unlock_user_problem;uniqueIndex:idx_problem_unlock_hint;uniqueIndex:idx_problem_unlock_editorial,where:is_editorial"`
+	HintID      *uint     `json:"hint_id" gorm:"uniqueIndex:idx_problem_unlock_hint"` // nil для разбора, разбор уникален по idx_problem_unlock_editorial
+	IsEditorial bool      `json:"is_editorial" gorm:"default:false"`
+	Penalty     int       `json:"penalty" gorm:"default:0"` // штраф, применяемый при начислении баллов
+	UnlockedAt  time.Time `json:"unlocked_at"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Связи
+	User    User         `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Problem Problem      `json:"problem,omitempty" gorm:"foreignKey:ProblemID"`
+	Hint    *ProblemHint `json:"hint,omitempty" gorm:"foreignKey:HintID"`
+}
+
+// ProblemDraft хранит последнее содержимое редактора пользователя по задаче
+type ProblemDraft struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_problem_draft_user_problem"`
+	ProblemID uint      `json:"problem_id" gorm:"not null;uniqueIndex:idx_problem_draft_user_problem"`
+	Code      string    `json:"code" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Связи
+	User    User    `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Problem Problem `json:"problem,omitempty" gorm:"foreignKey:ProblemID"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// ProblemList упорядоченная подборка задач пользователя. Официальные подборки
+// (учебные траектории) создаются администраторами и показываются рядом с курсами
+// и переживают удаление своего автора, поэтому связь с владельцем не объявлена
+type ProblemList struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	OwnerID     uint      `json:"owner_id" gorm:"not null;index"`
+	Title       string    `json:"title" gorm:"not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	IsOfficial  bool      `json:"is_official" gorm:"default:false;index"`
+	Order       int       `json:"order" gorm:"default:0"`                           // порядок официальных траекторий
+	ShareToken  *string   `json:"share_token,omitempty" gorm:"size:32;uniqueIndex"` // nil — доступ по ссылке закрыт
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Связи
+	Items []ProblemListItem `json:"items,omitempty" gorm:"foreignKey:ListID"`
+}
+
+// ProblemListItem задача в подборке на позиции Position
+type ProblemListItem struct {
+	ID        uint `json:"id" gorm:"primaryKey"`
+	ListID    uint `json:"list_id" gorm:"not null;uniqueIndex:idx_problem_list_item"`
+	ProblemID uint `json:"problem_id" gorm:"not null;uniqueIndex:idx_problem_list_item;index"`
+	Position  int  `json:"position" gorm:"not null"`
+
+	// Связи
+	Problem Problem `json:"problem,omitempty" gorm:"foreignKey:ProblemID"`
+}
@@ -30,7 +30,10 @@ type UserSubmission struct {
 	ID          uint             `json:"id" gorm:"primaryKey"`
 	UserID      uint             `json:"user_id" gorm:"not null"`
 	ProblemID   uint             `json:"problem_id" gorm:"not null"`
+	ContestID   *uint            `json:"contest_id,omitempty" gorm:"index"` // nil для отправок вне соревнований
+	ReviewID    *uint            `json:"review_id,omitempty" gorm:"index"`  // ветка ревью, в рамках которой сделана отправка
 	Code        string           `json:"code" gorm:"type:text"`
+	CodeHash    string           `json:"-" gorm:"size:64;index"` // sha256 нормализованного кода для поиска повторов
 	Language    string           `json:"language" gorm:"default:'go'"`
 	Status      SubmissionStatus `json:"status" gorm:"default:'pending'"`
 	Score       int              `json:"score" gorm:"default:0"`
@@ -39,6 +42,9 @@ type UserSubmission struct {
 	ExecutionTime int            `json:"execution_time"` // в миллисекундах
 	MemoryUsed  int              `json:"memory_used"`    // в байтах
 	ErrorOutput string           `json:"error_output" gorm:"type:text"`
+	GroupResults string          `json:"group_results" gorm:"type:text"` // JSON с результатами групп тестов
+	PointsAwarded int            `json:"points_awarded" gorm:"default:0"` // баллы, начисленные за эту отправку
+	JudgeAttempts int            `json:"-" gorm:"default:0"` // число запусков проверки, включая повторные после сбоев
 	SubmittedAt time.Time        `json:"submitted_at"`
 	CreatedAt   time.Time        `json:"created_at"`
 	UpdatedAt   time.Time        `json:"updated_at"`
@@ -60,21 +66,28 @@ const (
 	SubmissionStatusMemoryLimitExceeded SubmissionStatus = "memory_limit_exceeded"
 	SubmissionStatusRuntimeError SubmissionStatus = "runtime_error"
 	SubmissionStatusCompileError SubmissionStatus = "compile_error"
+	SubmissionStatusConstraintViolation SubmissionStatus = "constraint_violation" // код нарушает ограничения задачи
+	SubmissionStatusSystemError SubmissionStatus = "system_error" // сбой проверяющей системы, не ошибка решения
 )
 
 // UserTestResult представляет результат прохождения теста
 type UserTestResult struct {
 	ID            uint      `json:"id" gorm:"primaryKey"`
-	UserID        uint      `json:"user_id" gorm:"not null"`
-	TestID        uint      `json:"test_id" gorm:"not null"`
+	UserID        uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_test_attempt_active,where:completed_at IS NULL"`
+	TestID        uint      `json:"test_id" gorm:"not null;uniqueIndex:idx_test_attempt_active,where:completed_at IS NULL"`
 	Score         int       `json:"score"`         // набранные баллы
 	MaxScore      int       `json:"max_score"`     // максимальные баллы
 	Percentage    float64   `json:"percentage"`    // процент правильных ответов
 	IsPassed      bool      `json:"is_passed"`     // прошёл ли тест
 	TimeSpent     int       `json:"time_spent"`    // время в секундах
 	Answers       string    `json:"answers" gorm:"type:text"` // JSON с ответами
+	QuestionIDs   string    `json:"-" gorm:"type:text"`       // JSON с вопросами билета попытки в порядке показа
+	Seed          int64     `json:"-"`                        // зерно перемешивания вариантов ответа
+	PointsAwarded int       `json:"points_awarded"` // баллы за первое успешное прохождение
 	StartedAt     time.Time `json:"started_at"`
-	CompletedAt   *time.Time `json:"completed_at"`
+	Deadline      *time.Time `json:"deadline"`    // nil, если у теста нет ограничения по времени
+	CompletedAt   *time.Time `json:"completed_at"` // nil, пока попытка не завершена
+	SweepFailures int       `json:"-" gorm:"default:0"` // неудачные попытки фонового завершения просроченной попытки
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 
@@ -83,11 +96,39 @@ type UserTestResult struct {
 	Test Test `json:"test,omitempty" gorm:"foreignKey:TestID"`
 }
 
+// TestAttemptAnswer автосохранённый ответ на вопрос незавершённой попытки.
+// При завершении попытки ответы проверяются и удаляются
+type TestAttemptAnswer struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	AttemptID  uint      `json:"attempt_id" gorm:"not null;uniqueIndex:idx_attempt_answer"`
+	QuestionID uint      `json:"question_id" gorm:"not null;uniqueIndex:idx_attempt_answer"`
+	Answer     string    `json:"answer" gorm:"type:text"` // JSON с ответом в формате отправки теста
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// PlacementAttempt адаптивный вступительный тест пользователя. Следующий
+// вопрос выбирается по текущей оценке способности Ability, тест завершается
+// оценкой уровня Level
+type PlacementAttempt struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	UserID            uint       `json:"user_id" gorm:"not null;uniqueIndex"` // вступительный тест проходится один раз
+	Ability           float64    `json:"ability"`        // оценка способности по модели Раша
+	StandardError     float64    `json:"standard_error"` // погрешность оценки
+	Answers           string     `json:"-" gorm:"type:text"` // JSON с ответами в порядке показа
+	CurrentQuestionID *uint      `json:"-"`                  // показанный, но ещё не отвеченный вопрос
+	Level             UserLevel  `json:"level" gorm:"size:10"` // оценка уровня, заполняется при завершении
+	Applied           bool       `json:"applied" gorm:"default:false"` // уровень пользователя обновлён по результату
+	StartedAt         time.Time  `json:"started_at"`
+	CompletedAt       *time.Time `json:"completed_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
 // Certificate представляет сертификат
 type Certificate struct {
 	ID           uint           `json:"id" gorm:"primaryKey"`
-	UserID       uint           `json:"user_id" gorm:"not null"`
-	Type         CertificateType `json:"type"`
+	UserID       uint           `json:"user_id" gorm:"not null;uniqueIndex:idx_certificate_user_level,where:type <> 'course' AND type <> 'achievement'"`
+	Type         CertificateType `json:"type" gorm:"uniqueIndex:idx_certificate_user_level"` // сертификат уровня выдаётся пользователю один раз
 	Title        string         `json:"title" gorm:"not null"`
 	Description  string         `json:"description"`
 	CertificateNumber string    `json:"certificate_number" gorm:"uniqueIndex;not null"`
@@ -104,6 +145,11 @@ type Certificate struct {
 // CertificateType определяет тип сертификата
 type CertificateType string
 
+// LevelCertificateCondition условие частичного уникального индекса
+// idx_certificate_user_level: сертификаты уровней, а не курсов и достижений.
+// Должно совпадать с условием where в теге Certificate.UserID
+const LevelCertificateCondition = "type <> 'course' AND type <> 'achievement'"
+
 const (
 	CertificateTypeJunior     CertificateType = "junior"
 	CertificateTypeMiddle     CertificateType = "middle"
@@ -131,7 +177,19 @@ func (u *User) BeforeDelete(tx *gorm.DB) error {
 	// Удаляем все связанные записи
 	tx.Where("user_id = ?", u.ID).Delete(&UserProgress{})
 	tx.Where("user_id = ?", u.ID).Delete(&UserSubmission{})
+	tx.Where("user_id = ?", u.ID).Delete(&ProblemUnlock{})
+	tx.Where("user_id = ?", u.ID).Delete(&ProblemDraft{})
+	tx.Where("list_id IN (?)", tx.Model(&ProblemList{}).Select("id").Where("owner_id = ? AND is_official = ?", u.ID, false)).Delete(&ProblemListItem{})
+	tx.Where("owner_id = ? AND is_official = ?", u.ID, false).Delete(&ProblemList{})
+	tx.Where("user_id = ?", u.ID).Delete(&ContestRegistration{})
+	tx.Where("user_id = ?", u.ID).Delete(&ChallengeCompletion{})
+	tx.Where("user_id = ?", u.ID).Delete(&Notification{})
+	tx.Where("user_id = ?", u.ID).Delete(&DiscussionVote{})
+	tx.Where("review_id IN (?)", tx.Model(&CodeReview{}).Select("id").Where("user_id = ?", u.ID)).Delete(&ReviewComment{})
+	tx.Where("user_id = ?", u.ID).Delete(&CodeReview{})
+	tx.Where("attempt_id IN (?)", tx.Model(&UserTestResult{}).Select("id").Where("user_id = ?", u.ID)).Delete(&TestAttemptAnswer{})
 	tx.Where("user_id = ?", u.ID).Delete(&UserTestResult{})
+	tx.Where("user_id = ?", u.ID).Delete(&PlacementAttempt{})
 	tx.Where("user_id = ?", u.ID).Delete(&Certificate{})
 	tx.Where("user_id = ?", u.ID).Delete(&RefreshToken{})
 	return nil
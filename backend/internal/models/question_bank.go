@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// QuestionBank банк вопросов, из которого тесты набирают билеты по правилам
+type QuestionBank struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Title       string `json:"title" gorm:"not null"`
+	Description string `json:"description" gorm:"type:text"`
+	// Вопросы банка участвуют в адаптивном вступительном тесте. Сложность
+	// вопросов определяет, как ответ влияет на оценку уровня
+	IsPlacement bool      `json:"is_placement" gorm:"default:false"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Связи
+	Questions []TestQuestion `json:"questions,omitempty" gorm:"foreignKey:BankID"`
+}
+
+// TestRule правило набора билета: Count случайных вопросов банка с заданными
+// темой и сложностью. Пустые Topic и Difficulty означают любую тему и сложность
+type TestRule struct {
+	ID         uint         `json:"id" gorm:"primaryKey"`
+	TestID     uint         `json:"test_id" gorm:"not null;index"`
+	BankID     uint         `json:"bank_id" gorm:"not null;index"`
+	Topic      string       `json:"topic" gorm:"size:50"`
+	Difficulty ProblemLevel `json:"difficulty" gorm:"size:10"`
+	Count      int          `json:"count" gorm:"not null"`
+	Order      int          `json:"order" gorm:"default:0"`
+	CreatedAt  time.Time    `json:"created_at"`
+
+	// Связи
+	Bank QuestionBank `json:"-" gorm:"foreignKey:BankID"`
+}
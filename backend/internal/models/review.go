@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+)
+
+// CodeReview ветка ревью кода студента по задаче. Ревью ведётся по принятой
+// отправке, повторные отправки в рамках ревью становятся новыми ревизиями
+type CodeReview struct {
+	ID           uint         `json:"id" gorm:"primaryKey"`
+	UserID       uint         `json:"user_id" gorm:"not null;index"` // автор кода
+	ProblemID    uint         `json:"problem_id" gorm:"not null;index"`
+	SubmissionID uint         `json:"submission_id" gorm:"not null;index"` // текущая ревизия
+	ReviewerID   *uint        `json:"reviewer_id" gorm:"index"`
+	Status       ReviewStatus `json:"status" gorm:"default:'pending'"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+
+	// Связи
+	User       User           `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Problem    Problem        `json:"problem,omitempty" gorm:"foreignKey:ProblemID"`
+	Submission UserSubmission `json:"submission,omitempty" gorm:"foreignKey:SubmissionID"`
+	Reviewer   *User          `json:"reviewer,omitempty" gorm:"foreignKey:ReviewerID"`
+}
+
+// ReviewStatus определяет состояние ревью
+type ReviewStatus string
+
+const (
+	ReviewStatusPending          ReviewStatus = "pending"           // ожидает проверки ревьюером
+	ReviewStatusChangesRequested ReviewStatus = "changes_requested" // ревьюер запросил исправления
+	ReviewStatusApproved         ReviewStatus = "approved"
+)
+
+// ReviewComment комментарий в ветке ревью. Line привязывает комментарий к строке
+// кода ревизии SubmissionID, nil — общий комментарий
+type ReviewComment struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ReviewID     uint      `json:"review_id" gorm:"not null;index"`
+	SubmissionID uint      `json:"submission_id" gorm:"not null"`
+	AuthorID     uint      `json:"author_id" gorm:"not null"`
+	Line         *int      `json:"line"`
+	Body         string    `json:"body" gorm:"type:text;not null"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// Связи
+	Author User `json:"author,omitempty" gorm:"foreignKey:AuthorID"`
+}
+
+// Notification уведомление пользователя
+type Notification struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"not null;index"`
+	Type       string    `json:"type" gorm:"not null"`
+	Title      string    `json:"title" gorm:"not null"`
+	Message    string    `json:"message" gorm:"type:text"`
+	EntityType string    `json:"entity_type"` // тип связанного объекта, например "review"
+	EntityID   uint      `json:"entity_id"`
+	IsRead     bool      `json:"is_read" gorm:"default:false;index"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Типы уведомлений
+const (
+	NotificationReviewRequested   = "review_requested"
+	NotificationReviewComment     = "review_comment"
+	NotificationChangesRequested  = "review_changes_requested"
+	NotificationReviewApproved    = "review_approved"
+	NotificationReviewResubmitted = "review_resubmitted"
+	NotificationDiscussionReply   = "discussion_reply"
+	NotificationAnswerAccepted    = "discussion_answer_accepted"
+	NotificationJudgeFailure      = "judge_failure" // сбой проверяющей системы, для администраторов
+)
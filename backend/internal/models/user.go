@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // User представляет пользователя системы
@@ -29,8 +31,9 @@ type User struct {
 type UserRole string
 
 const (
-	UserRoleUser  UserRole = "user"
-	UserRoleAdmin UserRole = "admin"
+	UserRoleUser     UserRole = "user"
+	UserRoleReviewer UserRole = "reviewer" // наставник, проверяющий код студентов
+	UserRoleAdmin    UserRole = "admin"
 )
 
 // UserLevel определяет уровень пользователя
@@ -42,6 +45,18 @@ const (
 	UserLevelSenior UserLevel = "senior"
 )
 
+// Rank возвращает порядковый номер уровня: junior < middle < senior
+func (l UserLevel) Rank() int {
+	switch l {
+	case UserLevelMiddle:
+		return 1
+	case UserLevelSenior:
+		return 2
+	default:
+		return 0
+	}
+}
+
 // Course представляет курс
 type Course struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
@@ -49,6 +64,8 @@ type Course struct {
 	Description string    `json:"description"`
 	ImageURL    string    `json:"image_url"`
 	Order       int       `json:"order" gorm:"default:0"`
+	Level       UserLevel `json:"level" gorm:"size:10;default:'junior'"` // рекомендуемый уровень курса
+	LevelLocked bool      `json:"level_locked" gorm:"default:false"`      // уроки открыты только с уровня Level
 	IsActive    bool      `json:"is_active" gorm:"default:true"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
@@ -99,9 +116,18 @@ type Problem struct {
 	Difficulty   ProblemLevel    `json:"difficulty" gorm:"default:'easy'"`
 	InitialCode  string          `json:"initial_code" gorm:"type:text"`
 	TestCases    string          `json:"test_cases" gorm:"type:text"` // JSON строка с тест-кейсами
+	TestGroups   string          `json:"test_groups" gorm:"type:text"` // JSON строка с группами тестов (подзадачами)
+	Constraints  string          `json:"constraints" gorm:"type:text"` // JSON строка с ограничениями на код решения
 	Points       int             `json:"points" gorm:"default:20"`
 	TimeLimit    int             `json:"time_limit" gorm:"default:5"` // в секундах
 	MemoryLimit  int             `json:"memory_limit" gorm:"default:128"` // в MB
+	ReferenceSolution string     `json:"-" gorm:"type:text"` // эталонное решение автора
+	Checker      string          `json:"-" gorm:"type:text"` // исходный код чекера (testlib-совместимый)
+	Interactor   string          `json:"-" gorm:"type:text"` // исходный код интерактора для интерактивных задач
+	Generator    string          `json:"-" gorm:"type:text"` // исходный код генератора тестов
+	GeneratorArgs string         `json:"-" gorm:"type:text"` // JSON массив строк аргументов генератора
+	Editorial    string          `json:"-" gorm:"type:text"` // разбор решения
+	EditorialPenalty int         `json:"editorial_penalty" gorm:"default:0"` // штраф за открытие разбора
 	IsActive     bool            `json:"is_active" gorm:"default:true"`
 	CreatedAt    time.Time       `json:"created_at"`
 	UpdatedAt    time.Time       `json:"updated_at"`
@@ -128,23 +154,70 @@ type Test struct {
 	PassScore   int       `json:"pass_score" gorm:"default:70"` // процент для прохождения
 	Points      int       `json:"points" gorm:"default:50"`
 	IsActive    bool      `json:"is_active" gorm:"default:true"`
+	// Перемешивать вопросы и варианты ответов в каждой попытке. Тесты с
+	// правилами выбора из банка перемешиваются всегда
+	ShuffleQuestions bool `json:"shuffle_questions"`
+	MaxAttempts    int       `json:"max_attempts" gorm:"default:0"`    // 0 — без ограничения
+	RetakeCooldown int       `json:"retake_cooldown" gorm:"default:0"` // минут до пересдачи после неудачной попытки
+	ScoringPolicy  TestScoringPolicy `json:"scoring_policy" gorm:"size:10;default:'best'"`
+	// Показывать разбор после каждой попытки, если число попыток ограничено.
+	// Иначе разбор откроется, когда тест сдан или попытки закончились
+	ShowFeedback   bool      `json:"show_feedback" gorm:"default:false"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"` // удалённый тест скрыт, результаты по нему сохраняются
 
 	// Связи
 	Questions []TestQuestion   `json:"questions,omitempty" gorm:"foreignKey:TestID"`
+	Rules     []TestRule       `json:"rules,omitempty" gorm:"foreignKey:TestID"`
 	Results   []UserTestResult `json:"results,omitempty" gorm:"foreignKey:TestID"`
 }
 
-// TestQuestion представляет вопрос теста
+// TestScoringPolicy определяет, какая попытка идёт в зачёт теста
+type TestScoringPolicy string
+
+const (
+	TestScoringBest    TestScoringPolicy = "best"    // лучшая попытка
+	TestScoringLast    TestScoringPolicy = "last"    // последняя попытка
+	TestScoringAverage TestScoringPolicy = "average" // средний процент по всем попыткам
+)
+
+// QuestionType определяет вид вопроса теста и способ его проверки
+type QuestionType string
+
+const (
+	QuestionTypeSingleChoice   QuestionType = "single_choice"   // один верный вариант
+	QuestionTypeMultipleChoice QuestionType = "multiple_choice" // несколько верных вариантов, частичный балл
+	QuestionTypeText           QuestionType = "text"            // короткий ответ, варианты ответа — допустимые ответы
+	QuestionTypeCodeOutput     QuestionType = "code_output"     // что выведет фрагмент Code
+	QuestionTypeOrdering       QuestionType = "ordering"        // расставить варианты в порядке Order
+	QuestionTypeMatching       QuestionType = "matching"        // сопоставить варианты с их MatchText
+	QuestionTypeCode           QuestionType = "code"            // решение проверяется тестами задачи ProblemID
+)
+
+// TestQuestion представляет вопрос теста. Вопрос принадлежит либо самому
+// тесту (TestID), либо банку вопросов (BankID)
 type TestQuestion struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
-	TestID    uint      `json:"test_id" gorm:"not null"`
+	TestID    *uint     `json:"test_id" gorm:"index"`
+	BankID    *uint     `json:"bank_id" gorm:"index"`
+	Topic     string    `json:"topic" gorm:"size:50;index"`  // тема для правил выбора из банка
+	Difficulty ProblemLevel `json:"difficulty" gorm:"size:10"` // сложность для правил выбора из банка
+	Type      QuestionType `json:"type" gorm:"size:20;default:'single_choice'"`
 	Question  string    `json:"question" gorm:"type:text;not null"`
+	Explanation string  `json:"explanation" gorm:"type:text"` // разбор, показывается после завершения попытки
+	Code      string    `json:"code" gorm:"type:text"`         // фрагмент для code_output, заготовка для code
+	AnswerPattern string `json:"answer_pattern"`              // регулярное выражение для text
+	CaseSensitive bool  `json:"case_sensitive"`               // учитывать регистр в text
+	ExpectedOutput string `json:"expected_output" gorm:"type:text"` // вывод фрагмента code_output, вычисляется в sandbox
+	PlacementDifficulty *float64 `json:"placement_difficulty"` // сложность во вступительном тесте, уточняется по ответам; nil — по Difficulty
+	PlacementAnswers    int      `json:"-" gorm:"default:0"`   // ответов, учтённых в PlacementDifficulty
+	ProblemID *uint     `json:"problem_id"`                    // задача для вопросов code
 	Order     int       `json:"order" gorm:"default:0"`
 	Points    int       `json:"points" gorm:"default:1"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"` // удалённый вопрос остаётся в разборах прошлых попыток
 
 	// Связи
 	Test    Test         `json:"test,omitempty" gorm:"foreignKey:TestID"`
@@ -156,10 +229,12 @@ type TestAnswer struct {
 	ID         uint      `json:"id" gorm:"primaryKey"`
 	QuestionID uint      `json:"question_id" gorm:"not null"`
 	Answer     string    `json:"answer" gorm:"not null"`
+	MatchText  string    `json:"match_text"` // пара варианта в вопросах matching
 	IsCorrect  bool      `json:"is_correct" gorm:"default:false"`
 	Order      int       `json:"order" gorm:"default:0"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Связи
 	Question TestQuestion `json:"question,omitempty" gorm:"foreignKey:QuestionID"`
@@ -11,7 +11,10 @@ import (
 	"gorm.io/gorm"
 )
 
-func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
+// Setup создаёт роутер. Sandbox и сервис задач передаются из main: с ними же
+// работает сторож зависших проверок, которому нужно знать занятые отправки и
+// директории sandbox
+func Setup(db *gorm.DB, cfg *config.Config, sandboxService *services.SandboxService, problemService *services.ProblemService) *gin.Engine {
 	r := gin.Default()
 
 	// CORS middleware
@@ -19,6 +22,7 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		AllowOrigins:     []string{"http://localhost:3000"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"Retry-After", "X-Duplicate-Submission"},
 		AllowCredentials: true,
 	}))
 
@@ -32,19 +36,34 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	authService := services.NewAuthService(db, cfg)
 	userService := services.NewUserService(db)
 	courseService := services.NewCourseService(db)
-	problemService := services.NewProblemService(db)
-	testService := services.NewTestService(db)
+	submissionLimiter := services.NewSubmissionLimiter(cfg.Submission)
+	contestService := services.NewContestService(db)
+	challengeService := services.NewChallengeService(db)
+	problemListService := services.NewProblemListService(db)
+	judgeHealthService := services.NewJudgeHealthService(db, sandboxService)
+	reviewService := services.NewReviewService(db, problemService)
+	notificationService := services.NewNotificationService(db)
+	discussionService := services.NewDiscussionService(db)
+	testService := services.NewTestService(db, sandboxService)
+	placementService := services.NewPlacementService(db, testService)
 	progressService := services.NewProgressService(db)
 	certificateService := services.NewCertificateService(db)
-	sandboxService := services.NewSandboxService()
 	platformService := services.NewPlatformService(db)
 
 	// Инициализируем хендлеры
 	authHandler := handlers.NewAuthHandler(authService)
 	userHandler := handlers.NewUserHandler(userService)
 	courseHandler := handlers.NewCourseHandler(courseService)
-	problemHandler := handlers.NewProblemHandler(problemService, sandboxService)
+	problemHandler := handlers.NewProblemHandler(problemService, sandboxService, submissionLimiter)
+	contestHandler := handlers.NewContestHandler(contestService, problemService, submissionLimiter)
+	challengeHandler := handlers.NewChallengeHandler(challengeService)
+	problemListHandler := handlers.NewProblemListHandler(problemListService)
+	judgeHandler := handlers.NewJudgeHandler(judgeHealthService)
+	reviewHandler := handlers.NewReviewHandler(reviewService, problemService, submissionLimiter)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	discussionHandler := handlers.NewDiscussionHandler(discussionService)
 	testHandler := handlers.NewTestHandler(testService)
+	placementHandler := handlers.NewPlacementHandler(placementService)
 	progressHandler := handlers.NewProgressHandler(progressService)
 	certificateHandler := handlers.NewCertificateHandler(certificateService)
 	platformHandler := handlers.NewPlatformHandler(platformService)
@@ -97,6 +116,7 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	courses := protected.Group("/courses")
 	{
 		courses.GET("", courseHandler.GetCourses)
+		courses.GET("/paths", problemListHandler.GetOfficialPaths) // официальные учебные траектории
 		courses.GET("/:id", courseHandler.GetCourse)
 		courses.GET("/:id/sections", courseHandler.GetCourseSections)
 	}
@@ -105,6 +125,8 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	{
 		lessons.GET("/:id", courseHandler.GetLesson)
 		lessons.POST("/:id/complete", progressHandler.CompleteLesson)
+		lessons.GET("/:id/discussions", discussionHandler.GetLessonThreads)
+		lessons.POST("/:id/discussions", discussionHandler.CreateLessonThread)
 	}
 
 	// Практические задачи
@@ -115,6 +137,87 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		problems.POST("/:id/submit", problemHandler.SubmitSolution)
 		problems.GET("/submissions/:id", problemHandler.GetSubmission)
 		problems.GET("/my-submissions", problemHandler.GetUserSubmissions)
+		problems.GET("/:id/hints", problemHandler.GetHints)
+		problems.POST("/:id/hints/:hintId/unlock", problemHandler.UnlockHint)
+		problems.GET("/:id/editorial", problemHandler.GetEditorial)
+		problems.POST("/:id/editorial/unlock", problemHandler.UnlockEditorial)
+		problems.GET("/:id/draft", problemHandler.GetDraft)
+		problems.PUT("/:id/draft", problemHandler.SaveDraft)
+		problems.DELETE("/:id/draft", problemHandler.DeleteDraft)
+		problems.GET("/:id/submissions/diff", problemHandler.DiffSubmissions)
+		problems.GET("/:id/discussions", discussionHandler.GetProblemThreads)
+		problems.POST("/:id/discussions", discussionHandler.CreateProblemThread)
+	}
+
+	// Подборки задач
+	lists := protected.Group("/lists")
+	{
+		lists.GET("", problemListHandler.GetUserLists)
+		lists.POST("", problemListHandler.CreateList)
+		lists.GET("/shared/:token", problemListHandler.GetSharedList)
+		lists.GET("/:id", problemListHandler.GetList)
+		lists.PUT("/:id", problemListHandler.UpdateList)
+		lists.DELETE("/:id", problemListHandler.DeleteList)
+		lists.POST("/:id/share", problemListHandler.EnableSharing)
+		lists.DELETE("/:id/share", problemListHandler.DisableSharing)
+	}
+
+	// Соревнования
+	contests := protected.Group("/contests")
+	{
+		contests.GET("", contestHandler.GetContests)
+		contests.GET("/:id", contestHandler.GetContest)
+		contests.POST("/:id/register", contestHandler.Register)
+		contests.POST("/:id/problems/:label/submit", contestHandler.SubmitSolution)
+		contests.GET("/:id/submissions", contestHandler.GetUserSubmissions)
+		contests.GET("/:id/scoreboard", contestHandler.GetScoreboard)
+	}
+
+	// Задачи дня и недели
+	challenges := protected.Group("/challenges")
+	{
+		challenges.GET("/daily", challengeHandler.GetDailyChallenge)
+		challenges.GET("/weekly", challengeHandler.GetWeeklyChallenge)
+		challenges.GET("/streak", challengeHandler.GetStreak)
+	}
+
+	// Ревью кода
+	reviews := protected.Group("/reviews")
+	{
+		reviews.POST("", reviewHandler.RequestReview)
+		reviews.GET("/my", reviewHandler.GetUserReviews)
+		reviews.GET("/:id", reviewHandler.GetReview)
+		reviews.POST("/:id/comments", reviewHandler.AddComment)
+		reviews.POST("/:id/resubmit", reviewHandler.Resubmit)
+	}
+
+	// Действия ревьюеров
+	reviewer := protected.Group("/reviews")
+	reviewer.Use(middleware.ReviewerMiddleware())
+	{
+		reviewer.GET("/queue", reviewHandler.GetReviewQueue)
+		reviewer.POST("/:id/approve", reviewHandler.Approve)
+		reviewer.POST("/:id/request-changes", reviewHandler.RequestChanges)
+	}
+
+	// Обсуждения задач и уроков
+	discussions := protected.Group("/discussions")
+	{
+		discussions.GET("/:id", discussionHandler.GetThread)
+		discussions.PUT("/:id", discussionHandler.UpdateThread)
+		discussions.POST("/:id/posts", discussionHandler.AddPost)
+		discussions.POST("/:id/accept", discussionHandler.AcceptAnswer)
+		discussions.PUT("/posts/:postId", discussionHandler.UpdatePost)
+		discussions.DELETE("/posts/:postId", discussionHandler.DeletePost)
+		discussions.POST("/posts/:postId/vote", discussionHandler.Vote)
+	}
+
+	// Уведомления
+	notifications := protected.Group("/notifications")
+	{
+		notifications.GET("", notificationHandler.GetNotifications)
+		notifications.POST("/:id/read", notificationHandler.MarkRead)
+		notifications.POST("/read-all", notificationHandler.MarkAllRead)
 	}
 
 	// Тесты
@@ -123,11 +226,21 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		tests.GET("", testHandler.GetTests)
 		tests.GET("/:id", testHandler.GetTest)
 		tests.POST("/:id/start", testHandler.StartTest)
+		tests.PUT("/:id/answers", testHandler.SaveTestAnswers) // автосохранение ответов попытки
 		tests.POST("/:id/submit", testHandler.SubmitTest)
 		tests.GET("/:id/results", testHandler.GetTestResults)
 		tests.GET("/my-results", testHandler.GetUserTestResults)
 	}
 
+	// Адаптивный вступительный тест для определения начального уровня
+	placement := protected.Group("/placement")
+	{
+		placement.GET("", placementHandler.GetPlacement)
+		placement.POST("/start", placementHandler.StartPlacement)
+		placement.POST("/answer", placementHandler.AnswerPlacement)
+		placement.POST("/apply", placementHandler.ApplyPlacement)
+	}
+
 	// Прогресс
 	progress := protected.Group("/progress")
 	{
@@ -182,16 +295,69 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 			adminProblems.POST("", problemHandler.CreateProblem)
 			adminProblems.PUT("/:id", problemHandler.UpdateProblem)
 			adminProblems.DELETE("/:id", problemHandler.DeleteProblem)
+			adminProblems.POST("/import", problemHandler.ImportProblemPackage)
+			adminProblems.GET("/:id/export", problemHandler.ExportProblemPackage)
+			adminProblems.POST("/:id/hints", problemHandler.CreateHint)
+		}
+
+		// Управление подсказками
+		adminHints := admin.Group("/hints")
+		{
+			adminHints.PUT("/:id", problemHandler.UpdateHint)
+			adminHints.DELETE("/:id", problemHandler.DeleteHint)
+		}
+
+		// Управление соревнованиями
+		adminContests := admin.Group("/contests")
+		{
+			adminContests.POST("", contestHandler.CreateContest)
+			adminContests.PUT("/:id", contestHandler.UpdateContest)
+			adminContests.DELETE("/:id", contestHandler.DeleteContest)
+			adminContests.POST("/:id/unfreeze", contestHandler.UnfreezeScoreboard)
+		}
+
+		// Расписание задач дня и недели
+		adminChallenges := admin.Group("/challenges")
+		{
+			adminChallenges.GET("", challengeHandler.GetChallenges)
+			adminChallenges.POST("", challengeHandler.ScheduleChallenge)
+			adminChallenges.DELETE("/:id", challengeHandler.DeleteChallenge)
+		}
+
+		// Состояние проверяющей системы
+		admin.GET("/judge/health", judgeHandler.GetHealth)
+
+		// Модерация обсуждений
+		adminDiscussions := admin.Group("/discussions")
+		{
+			adminDiscussions.PUT("/:id", discussionHandler.ModerateThread)
+			adminDiscussions.DELETE("/:id", discussionHandler.DeleteThread)
 		}
 
 		// Управление тестами
 		adminTests := admin.Group("/tests")
 		{
+			adminTests.GET("", testHandler.GetTestsForAdmin)
+			adminTests.GET("/:id", testHandler.GetTestForAdmin)
+			adminTests.GET("/:id/analysis", testHandler.GetTestItemAnalysis) // статистика по вопросам
+			adminTests.GET("/:id/export", testHandler.ExportTest) // GIFT, Moodle XML или CSV
 			adminTests.POST("", testHandler.CreateTest)
+			adminTests.POST("/import", testHandler.ImportTest)
 			adminTests.PUT("/:id", testHandler.UpdateTest)
+			adminTests.PUT("/:id/order", testHandler.ReorderQuestions)
 			adminTests.DELETE("/:id", testHandler.DeleteTest)
 		}
 
+		// Банки вопросов для тестов с правилами выбора
+		adminBanks := admin.Group("/question-banks")
+		{
+			adminBanks.GET("", testHandler.GetQuestionBanks)
+			adminBanks.GET("/:id", testHandler.GetQuestionBank)
+			adminBanks.POST("", testHandler.CreateQuestionBank)
+			adminBanks.PUT("/:id", testHandler.UpdateQuestionBank)
+			adminBanks.DELETE("/:id", testHandler.DeleteQuestionBank)
+		}
+
 		// Управление пользователями
 		adminUsers := admin.Group("/users")
 		{
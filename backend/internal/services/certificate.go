@@ -7,8 +7,15 @@ import (
 
 	"go-education-platform/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrCertificateNotEligible возвращается, если требования для сертификата уровня не выполнены
+var ErrCertificateNotEligible = errors.New("требования для получения сертификата не выполнены")
+
+// ErrCertificateIssued возвращается при повторном запросе сертификата уровня
+var ErrCertificateIssued = errors.New("сертификат уже получен")
+
 type CertificateService struct {
 	db *gorm.DB
 }
@@ -33,8 +40,30 @@ func (s *CertificateService) GetUserCertificates(userID uint) ([]models.Certific
 
 // GenerateCertificate генерирует новый сертификат
 func (s *CertificateService) GenerateCertificate(userID uint, req *GenerateCertificateRequest) (*models.Certificate, error) {
-	// TODO: Implement certificate generation logic
-	// This is a placeholder implementation
+	// Сертификаты уровней выдаются только при выполненных требованиях и один раз
+	certificateType := models.CertificateType(req.Type)
+	_, isLevel := findCertificateRequirement(certificateType)
+	if isLevel {
+		eligibility, err := s.CheckEligibility(userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, cert := range eligibility.EligibleCertificates {
+			if cert.Type == req.Type && !cert.RequirementsMet {
+				return nil, ErrCertificateNotEligible
+			}
+		}
+
+		var issued int64
+		if err := s.db.Model(&models.Certificate{}).
+			Where("user_id = ? AND type = ?", userID, req.Type).
+			Count(&issued).Error; err != nil {
+			return nil, fmt.Errorf("ошибка проверки сертификатов: %w", err)
+		}
+		if issued > 0 {
+			return nil, ErrCertificateIssued
+		}
+	}
 	
 	certificate := &models.Certificate{
 		UserID:            userID,
@@ -45,8 +74,21 @@ func (s *CertificateService) GenerateCertificate(userID uint, req *GenerateCerti
 		IssuedAt:          time.Now(),
 	}
 	
-	if err := s.db.Create(certificate).Error; err != nil {
-		return nil, fmt.Errorf("ошибка создания сертификата: %w", err)
+	query := s.db
+	if isLevel {
+		// Параллельный запрос мог выдать тот же сертификат уровня после проверки выше
+		query = query.Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "user_id"}, {Name: "type"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: models.LevelCertificateCondition}}},
+			DoNothing:   true,
+		})
+	}
+	result := query.Create(certificate)
+	if result.Error != nil {
+		return nil, fmt.Errorf("ошибка создания сертификата: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCertificateIssued
 	}
 	
 	return certificate, nil
@@ -107,32 +149,77 @@ func (s *CertificateService) GetVerificationUrl(certificateID uint) (*Verificati
 	return response, nil
 }
 
-// CheckEligibility проверяет возможность получения сертификатов пользователем
+// certificateRequirement пороги для сертификата уровня
+type certificateRequirement struct {
+	Type             models.CertificateType
+	Title            string
+	Description      string
+	Points           int
+	CompletedLessons int
+	PassedTests      int
+}
+
+var certificateRequirements = []certificateRequirement{
+	{models.CertificateTypeJunior, "Junior Developer", "Для пользователей, набравших 500+ очков", 500, 10, 0},
+	{models.CertificateTypeMiddle, "Middle Developer", "Для пользователей, набравших 1500+ очков", 1500, 30, 5},
+	{models.CertificateTypeSenior, "Senior Developer", "Для пользователей, набравших 3000+ очков", 3000, 60, 10},
+}
+
+// findCertificateRequirement возвращает требования сертификата уровня.
+// false — сертификат не уровневый
+func findCertificateRequirement(certificateType models.CertificateType) (certificateRequirement, bool) {
+	for _, requirement := range certificateRequirements {
+		if requirement.Type == certificateType {
+			return requirement, true
+		}
+	}
+	return certificateRequirement{}, false
+}
+
+// CheckEligibility проверяет возможность получения сертификатов пользователем.
+// Тест считается пройденным по политике зачёта попыток этого теста
 func (s *CertificateService) CheckEligibility(userID uint) (*EligibilityResponse, error) {
-	// TODO: Implement actual eligibility checking logic
-	// This is a placeholder implementation
-	
-	eligibleCertificates := []EligibleCertificate{
-		{
-			Type:             "junior",
-			Title:            "Junior Developer",
-			Description:      "Для пользователей, набравших 500+ очков",
-			RequirementsMet:  true,
-			Requirements:     []string{"Набрать 500+ очков", "Завершить 10 уроков"},
-		},
-		{
-			Type:             "middle",
-			Title:            "Middle Developer",
-			Description:      "Для пользователей, набравших 1500+ очков",
-			RequirementsMet:  false,
-			Requirements:     []string{"Набрать 1500+ очков", "Завершить 30 уроков", "Пройти 5 тестов"},
-		},
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, errors.New("пользователь не найден")
 	}
-	
+
+	var completedLessons int64
+	if err := s.db.Model(&models.UserProgress{}).
+		Where("user_id = ? AND is_completed = ?", userID, true).
+		Count(&completedLessons).Error; err != nil {
+		return nil, fmt.Errorf("ошибка подсчёта уроков: %w", err)
+	}
+
+	passedTests, err := countPassedTests(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	eligibleCertificates := make([]EligibleCertificate, 0, len(certificateRequirements))
+	for _, req := range certificateRequirements {
+		requirements := []string{
+			fmt.Sprintf("Набрать %d+ очков", req.Points),
+			fmt.Sprintf("Завершить %d уроков", req.CompletedLessons),
+		}
+		if req.PassedTests > 0 {
+			requirements = append(requirements, fmt.Sprintf("Пройти %d тестов", req.PassedTests))
+		}
+		eligibleCertificates = append(eligibleCertificates, EligibleCertificate{
+			Type:        string(req.Type),
+			Title:       req.Title,
+			Description: req.Description,
+			RequirementsMet: user.Points >= req.Points &&
+				int(completedLessons) >= req.CompletedLessons &&
+				passedTests >= req.PassedTests,
+			Requirements: requirements,
+		})
+	}
+
 	response := &EligibilityResponse{
 		EligibleCertificates: eligibleCertificates,
 	}
-	
+
 	return response, nil
 }
 
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Бонусы по умолчанию за решение задачи-вызова в срок
+const (
+	DefaultDailyChallengeBonus  = 10
+	DefaultWeeklyChallengeBonus = 30
+)
+
+// challengeCooldownDays задача не выбирается автоматически повторно в течение этого срока
+const challengeCooldownDays = 60
+
+// Ротация сложности: задача дня меняет сложность каждый день, задача недели — каждую неделю
+var (
+	dailyDifficultyRotation  = []models.ProblemLevel{models.ProblemLevelEasy, models.ProblemLevelMedium, models.ProblemLevelHard}
+	weeklyDifficultyRotation = []models.ProblemLevel{models.ProblemLevelMedium, models.ProblemLevelHard}
+)
+
+const dateLayout = "2006-01-02"
+
+type ChallengeService struct {
+	db *gorm.DB
+}
+
+func NewChallengeService(db *gorm.DB) *ChallengeService {
+	return &ChallengeService{db: db}
+}
+
+// RunScheduler заранее выбирает задачи дня и недели, проверяя их наличие с интервалом interval
+func (s *ChallengeService) RunScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, kind := range []models.ChallengeKind{models.ChallengeKindDaily, models.ChallengeKindWeekly} {
+			if _, err := s.ensureChallenge(kind, time.Now()); err != nil {
+				log.Printf("Ошибка выбора задачи-вызова (%s): %v", kind, err)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// GetCurrentChallenge возвращает текущую задачу дня или недели, выбирая её при отсутствии
+func (s *ChallengeService) GetCurrentChallenge(userID uint, kind models.ChallengeKind) (*ChallengeView, error) {
+	challenge, err := s.ensureChallenge(kind, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	view := &ChallengeView{
+		Challenge: challenge,
+		Deadline:  challengePeriodEnd(challenge),
+	}
+
+	var completion models.ChallengeCompletion
+	err = s.db.Where("challenge_id = ? AND user_id = ?", challenge.ID, userID).First(&completion).Error
+	if err == nil {
+		view.IsCompleted = true
+		view.CompletedAt = &completion.CompletedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("ошибка проверки решения: %w", err)
+	}
+
+	return view, nil
+}
+
+// GetStreak считает серию дней подряд с решённой задачей дня. Серия не прерывается,
+// пока не закончился текущий день
+func (s *ChallengeService) GetStreak(userID uint) (*ChallengeStreak, error) {
+	var dates []time.Time
+	if err := s.db.Model(&models.ChallengeCompletion{}).
+		Joins("JOIN challenges ON challenges.id = challenge_completions.challenge_id").
+		Where("challenge_completions.user_id = ? AND challenges.kind = ?", userID, models.ChallengeKindDaily).
+		Order("challenges.date DESC").
+		Pluck("challenges.date", &dates).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения решённых задач дня: %w", err)
+	}
+
+	streak := &ChallengeStreak{TotalCompleted: len(dates)}
+	if len(dates) == 0 {
+		return streak, nil
+	}
+
+	today := challengeDay(time.Now())
+	last := challengeDay(dates[0])
+	streak.LastCompletedDate = last.Format(dateLayout)
+	streak.CompletedToday = last.Equal(today)
+
+	run := 1
+	for i := 1; i <= len(dates); i++ {
+		if i < len(dates) && challengeDay(dates[i]).Equal(challengeDay(dates[i-1]).AddDate(0, 0, -1)) {
+			run++
+			continue
+		}
+		if run > streak.LongestStreak {
+			streak.LongestStreak = run
+		}
+		// Первая серия актуальна, если последняя решённая задача — сегодняшняя или вчерашняя
+		if streak.CurrentStreak == 0 && i == run && !last.Before(today.AddDate(0, 0, -1)) {
+			streak.CurrentStreak = run
+		}
+		run = 1
+	}
+
+	return streak, nil
+}
+
+// ensureChallenge возвращает задачу-вызов периода, к которому относится now,
+// выбирая задачу автоматически, если администратор её не назначил
+func (s *ChallengeService) ensureChallenge(kind models.ChallengeKind, now time.Time) (*models.Challenge, error) {
+	date := challengePeriodStart(kind, now)
+
+	challenge, err := s.findChallenge(kind, date)
+	if err != nil || challenge != nil {
+		return challenge, err
+	}
+
+	problemID, err := s.pickProblem(kind, date)
+	if err != nil {
+		return nil, err
+	}
+
+	bonus := DefaultDailyChallengeBonus
+	if kind == models.ChallengeKindWeekly {
+		bonus = DefaultWeeklyChallengeBonus
+	}
+	challenge = &models.Challenge{
+		Kind:        kind,
+		Date:        date,
+		ProblemID:   problemID,
+		BonusPoints: bonus,
+	}
+	// Задачу мог одновременно выбрать другой запрос или планировщик
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(challenge).Error; err != nil {
+		return nil, fmt.Errorf("ошибка сохранения задачи-вызова: %w", err)
+	}
+
+	challenge, err = s.findChallenge(kind, date)
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil {
+		return nil, errors.New("задача-вызов не найдена")
+	}
+	return challenge, nil
+}
+
+func (s *ChallengeService) findChallenge(kind models.ChallengeKind, date time.Time) (*models.Challenge, error) {
+	var challenge models.Challenge
+	err := s.db.Preload("Problem").
+		Where("kind = ? AND date = ?", kind, date.Format(dateLayout)).
+		First(&challenge).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ошибка получения задачи-вызова: %w", err)
+	}
+	return &challenge, nil
+}
+
+// pickProblem выбирает случайную активную задачу по ротации сложности, не
+// использовавшуюся недавно. Если таких нет, ограничения ослабляются по очереди
+func (s *ChallengeService) pickProblem(kind models.ChallengeKind, date time.Time) (uint, error) {
+	rotation := dailyDifficultyRotation
+	period := int(date.Unix() / int64(24*time.Hour/time.Second))
+	if kind == models.ChallengeKindWeekly {
+		rotation = weeklyDifficultyRotation
+		period /= 7
+	}
+	difficulty := rotation[period%len(rotation)]
+
+	recent := s.db.Model(&models.Challenge{}).
+		Select("problem_id").
+		Where("date > ?", date.AddDate(0, 0, -challengeCooldownDays).Format(dateLayout))
+
+	attempts := []func(*gorm.DB) *gorm.DB{
+		func(q *gorm.DB) *gorm.DB { return q.Where("difficulty = ? AND id NOT IN (?)", difficulty, recent) },
+		func(q *gorm.DB) *gorm.DB { return q.Where("id NOT IN (?)", recent) },
+		func(q *gorm.DB) *gorm.DB { return q.Where("difficulty = ?", difficulty) },
+		func(q *gorm.DB) *gorm.DB { return q },
+	}
+
+	for _, scope := range attempts {
+		var ids []uint
+		if err := scope(s.db.Model(&models.Problem{}).Where("is_active = ?", true)).
+			Order("RANDOM()").
+			Limit(1).
+			Pluck("id", &ids).Error; err != nil {
+			return 0, fmt.Errorf("ошибка выбора задачи: %w", err)
+		}
+		if len(ids) > 0 {
+			return ids[0], nil
+		}
+	}
+
+	return 0, errors.New("нет доступных задач для выбора")
+}
+
+// challengeDay возвращает календарную дату t в виде полуночи UTC, как её хранит столбец date
+func challengeDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// challengePeriodStart возвращает дату задачи-вызова: сам день или понедельник недели
+func challengePeriodStart(kind models.ChallengeKind, t time.Time) time.Time {
+	day := challengeDay(t)
+	if kind == models.ChallengeKindWeekly {
+		offset := (int(day.Weekday()) + 6) % 7
+		day = day.AddDate(0, 0, -offset)
+	}
+	return day
+}
+
+// challengePeriodEnd возвращает момент окончания периода задачи-вызова в локальном времени сервера
+func challengePeriodEnd(challenge *models.Challenge) time.Time {
+	y, m, d := challenge.Date.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	if challenge.Kind == models.ChallengeKindWeekly {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// awardChallengeBonus начисляет бонус за решение задачи дня или недели, если
+// принятая отправка сделана в период задачи-вызова. Бонус начисляется один раз
+func (s *ProblemService) awardChallengeBonus(submissionID uint) error {
+	var submission models.UserSubmission
+	if err := s.db.First(&submission, submissionID).Error; err != nil {
+		return err
+	}
+
+	var challenges []models.Challenge
+	if err := s.db.Where("problem_id = ? AND ((kind = ? AND date = ?) OR (kind = ? AND date = ?))",
+		submission.ProblemID,
+		models.ChallengeKindDaily, challengePeriodStart(models.ChallengeKindDaily, submission.CreatedAt).Format(dateLayout),
+		models.ChallengeKindWeekly, challengePeriodStart(models.ChallengeKindWeekly, submission.CreatedAt).Format(dateLayout)).
+		Find(&challenges).Error; err != nil {
+		return err
+	}
+
+	for _, challenge := range challenges {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			completion := &models.ChallengeCompletion{
+				ChallengeID:  challenge.ID,
+				UserID:       submission.UserID,
+				SubmissionID: submission.ID,
+				BonusAwarded: challenge.BonusPoints,
+				CompletedAt:  submission.CreatedAt,
+			}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(completion)
+			if result.Error != nil || result.RowsAffected == 0 || challenge.BonusPoints == 0 {
+				return result.Error
+			}
+			return tx.Model(&models.User{}).
+				Where("id = ?", submission.UserID).
+				Update("points", gorm.Expr("points + ?", challenge.BonusPoints)).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Admin methods
+
+// ScheduleChallenge назначает задачу на день или неделю, заменяя выбранную автоматически
+func (s *ChallengeService) ScheduleChallenge(req *ScheduleChallengeRequest) (*models.Challenge, error) {
+	date, err := time.Parse(dateLayout, req.Date)
+	if err != nil {
+		return nil, errors.New("неверный формат даты, ожидается ГГГГ-ММ-ДД")
+	}
+	date = challengePeriodStart(req.Kind, date)
+	if date.Before(challengePeriodStart(req.Kind, time.Now())) {
+		return nil, errors.New("нельзя назначить задачу на прошедший период")
+	}
+
+	var problem models.Problem
+	if err := s.db.Where("is_active = ?", true).First(&problem, req.ProblemID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("задача не найдена")
+		}
+		return nil, fmt.Errorf("ошибка получения задачи: %w", err)
+	}
+
+	bonus := DefaultDailyChallengeBonus
+	if req.Kind == models.ChallengeKindWeekly {
+		bonus = DefaultWeeklyChallengeBonus
+	}
+	if req.BonusPoints != nil {
+		bonus = *req.BonusPoints
+	}
+
+	challenge := &models.Challenge{
+		Kind:        req.Kind,
+		Date:        date,
+		ProblemID:   req.ProblemID,
+		BonusPoints: bonus,
+		IsScheduled: true,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kind"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"problem_id", "bonus_points", "is_scheduled", "updated_at"}),
+	}).Create(challenge).Error; err != nil {
+		return nil, fmt.Errorf("ошибка назначения задачи-вызова: %w", err)
+	}
+
+	return s.findChallenge(req.Kind, date)
+}
+
+// GetChallenges возвращает задачи-вызовы за период включительно
+func (s *ChallengeService) GetChallenges(from, to time.Time) ([]*models.Challenge, error) {
+	var challenges []*models.Challenge
+	if err := s.db.Preload("Problem").
+		Where("date >= ? AND date <= ?", from.Format(dateLayout), to.Format(dateLayout)).
+		Order("date ASC, kind ASC").
+		Find(&challenges).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения задач-вызовов: %w", err)
+	}
+	return challenges, nil
+}
+
+// ErrChallengeCompleted возвращается при удалении задачи-вызова, которую уже
+// решили: удаление отняло бы начисленные бонусы и разорвало серии
+var ErrChallengeCompleted = errors.New("задачу-вызов уже решили, удалить её нельзя")
+
+// DeleteChallenge удаляет назначение, которое ещё никто не решил. Для текущего
+// периода задача будет выбрана заново
+func (s *ChallengeService) DeleteChallenge(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var completions int64
+		if err := tx.Model(&models.ChallengeCompletion{}).Where("challenge_id = ?", id).Count(&completions).Error; err != nil {
+			return fmt.Errorf("ошибка проверки решений задачи-вызова: %w", err)
+		}
+		if completions > 0 {
+			return ErrChallengeCompleted
+		}
+		// Решение, засчитанное после проверки, не даст удалить запись по внешнему ключу
+		if err := tx.Delete(&models.Challenge{}, id).Error; err != nil {
+			return fmt.Errorf("ошибка удаления задачи-вызова: %w", err)
+		}
+		return nil
+	})
+}
+
+// ChallengeView задача-вызов с отметкой о решении пользователем
+type ChallengeView struct {
+	*models.Challenge
+	Deadline    time.Time  `json:"deadline"`
+	IsCompleted bool       `json:"is_completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// ChallengeStreak серия решённых подряд задач дня
+type ChallengeStreak struct {
+	CurrentStreak     int    `json:"current_streak"`
+	LongestStreak     int    `json:"longest_streak"`
+	TotalCompleted    int    `json:"total_completed"`
+	LastCompletedDate string `json:"last_completed_date,omitempty"`
+	CompletedToday    bool   `json:"completed_today"`
+}
+
+type ScheduleChallengeRequest struct {
+	Kind        models.ChallengeKind `json:"kind" binding:"required,oneof=daily weekly"`
+	Date        string               `json:"date" binding:"required"`
+	ProblemID   uint                 `json:"problem_id" binding:"required"`
+	BonusPoints *int                 `json:"bonus_points" binding:"omitempty,min=0"`
+}
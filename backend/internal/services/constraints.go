@@ -0,0 +1,219 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// SolutionConstraints ограничения на код решения, проверяемые по синтаксическому
+// дереву до компиляции
+type SolutionConstraints struct {
+	ForbiddenImports        []string `json:"forbidden_imports,omitempty" yaml:"forbidden_imports,omitempty"`
+	AllowedImports          []string `json:"allowed_imports,omitempty" yaml:"allowed_imports,omitempty"` // если задан, разрешены только эти пакеты
+	RequireGenerics         bool     `json:"require_generics,omitempty" yaml:"require_generics,omitempty"`
+	RequireGoroutines       bool     `json:"require_goroutines,omitempty" yaml:"require_goroutines,omitempty"`
+	RequireChannels         bool     `json:"require_channels,omitempty" yaml:"require_channels,omitempty"`
+	MaxCyclomaticComplexity int      `json:"max_cyclomatic_complexity,omitempty" yaml:"max_cyclomatic_complexity,omitempty"` // для каждой функции, 0 — без ограничения
+}
+
+// ConstraintViolation нарушение ограничения с указанием строки кода, если она известна
+type ConstraintViolation struct {
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// ParseSolutionConstraints парсит JSON строку с ограничениями. Пустая строка означает отсутствие ограничений
+func ParseSolutionConstraints(constraintsJSON string) (*SolutionConstraints, error) {
+	if strings.TrimSpace(constraintsJSON) == "" {
+		return nil, nil
+	}
+
+	var constraints SolutionConstraints
+	decoder := json.NewDecoder(strings.NewReader(constraintsJSON))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&constraints); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ограничений решения: %w", err)
+	}
+	if constraints.MaxCyclomaticComplexity < 0 {
+		return nil, errors.New("максимальная цикломатическая сложность не может быть отрицательной")
+	}
+	if len(constraints.ForbiddenImports) > 0 && len(constraints.AllowedImports) > 0 {
+		return nil, errors.New("нельзя одновременно задать запрещённые и разрешённые импорты")
+	}
+	return &constraints, nil
+}
+
+// CheckSolutionConstraints проверяет код решения на соответствие ограничениям.
+// Код с синтаксическими ошибками не проверяется: их сообщит компилятор
+func CheckSolutionConstraints(code string, constraints *SolutionConstraints) []ConstraintViolation {
+	if constraints == nil {
+		return nil
+	}
+
+	fset, file, lineOffset, ok := parseSolution(code)
+	if !ok {
+		return nil
+	}
+	line := func(pos token.Pos) int {
+		if l := fset.Position(pos).Line - lineOffset; l > 0 {
+			return l
+		}
+		return 0
+	}
+
+	var violations []ConstraintViolation
+
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if len(constraints.ForbiddenImports) > 0 && matchesImport(path, constraints.ForbiddenImports) {
+			violations = append(violations, ConstraintViolation{
+				Line:    line(spec.Pos()),
+				Message: fmt.Sprintf("импорт пакета %q запрещён", path),
+			})
+		}
+		if len(constraints.AllowedImports) > 0 && !matchesImport(path, constraints.AllowedImports) {
+			violations = append(violations, ConstraintViolation{
+				Line:    line(spec.Pos()),
+				Message: fmt.Sprintf("импорт пакета %q не разрешён, допустимы: %s", path, strings.Join(constraints.AllowedImports, ", ")),
+			})
+		}
+	}
+
+	var usesGenerics, usesGoroutines, usesChannels bool
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.FuncType:
+			if node.TypeParams != nil && len(node.TypeParams.List) > 0 {
+				usesGenerics = true
+			}
+		case *ast.TypeSpec:
+			if node.TypeParams != nil && len(node.TypeParams.List) > 0 {
+				usesGenerics = true
+			}
+		case *ast.GoStmt:
+			usesGoroutines = true
+		case *ast.ChanType, *ast.SendStmt:
+			usesChannels = true
+		case *ast.UnaryExpr:
+			if node.Op == token.ARROW {
+				usesChannels = true
+			}
+		}
+		return true
+	})
+
+	if constraints.RequireGenerics && !usesGenerics {
+		violations = append(violations, ConstraintViolation{Message: "решение должно использовать обобщённые типы или функции (generics)"})
+	}
+	if constraints.RequireGoroutines && !usesGoroutines {
+		violations = append(violations, ConstraintViolation{Message: "решение должно запускать горутины (оператор go)"})
+	}
+	if constraints.RequireChannels && !usesChannels {
+		violations = append(violations, ConstraintViolation{Message: "решение должно использовать каналы"})
+	}
+
+	if constraints.MaxCyclomaticComplexity > 0 {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			if complexity := cyclomaticComplexity(fn.Body); complexity > constraints.MaxCyclomaticComplexity {
+				violations = append(violations, ConstraintViolation{
+					Line: line(fn.Pos()),
+					Message: fmt.Sprintf("цикломатическая сложность функции %s равна %d, допустимо не больше %d",
+						fn.Name.Name, complexity, constraints.MaxCyclomaticComplexity),
+				})
+			}
+		}
+	}
+
+	return violations
+}
+
+// FormatConstraintViolations формирует пояснение к вердикту о нарушении ограничений
+func FormatConstraintViolations(violations []ConstraintViolation) string {
+	var sb strings.Builder
+	sb.WriteString("Решение нарушает ограничения задачи:")
+	for _, v := range violations {
+		sb.WriteString("\n- ")
+		if v.Line > 0 {
+			fmt.Fprintf(&sb, "строка %d: ", v.Line)
+		}
+		sb.WriteString(v.Message)
+	}
+	return sb.String()
+}
+
+// parseSolution разбирает код так же, как его дополняет prepareCode: без
+// объявления пакета и, для фрагмента без функций, как тело main.
+// lineOffset — число добавленных перед кодом строк
+func parseSolution(code string) (*token.FileSet, *ast.File, int, bool) {
+	type candidate struct {
+		source string
+		offset int
+	}
+	candidates := []candidate{
+		{code, 0},
+		{"package main\n" + code, 1},
+	}
+	if !strings.Contains(code, "func ") {
+		candidates = append(candidates, candidate{"package main\nfunc main() {\n" + code + "\n}", 2})
+	}
+
+	for _, c := range candidates {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "main.go", c.source, parser.SkipObjectResolution)
+		if err == nil {
+			return fset, file, c.offset, true
+		}
+	}
+	return nil, nil, 0, false
+}
+
+// matchesImport проверяет путь импорта по списку: элемент совпадает с самим
+// пакетом и с его подпакетами
+func matchesImport(path string, list []string) bool {
+	for _, item := range list {
+		if path == item || strings.HasPrefix(path, item+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// cyclomaticComplexity считает сложность по Маккейбу: 1 плюс число ветвлений
+// и логических операторов. Вложенные функциональные литералы учитываются в
+// сложности объемлющей функции
+func cyclomaticComplexity(body *ast.BlockStmt) int {
+	complexity := 1
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			complexity++
+		case *ast.CaseClause:
+			if node.List != nil {
+				complexity++
+			}
+		case *ast.CommClause:
+			if node.Comm != nil {
+				complexity++
+			}
+		case *ast.BinaryExpr:
+			if node.Op == token.LAND || node.Op == token.LOR {
+				complexity++
+			}
+		}
+		return true
+	})
+	return complexity
+}
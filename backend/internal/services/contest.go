@@ -0,0 +1,649 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Состояния соревнования относительно текущего времени
+const (
+	ContestStatusUpcoming = "upcoming"
+	ContestStatusRunning  = "running"
+	ContestStatusFinished = "finished"
+)
+
+type ContestService struct {
+	db *gorm.DB
+}
+
+func NewContestService(db *gorm.DB) *ContestService {
+	return &ContestService{db: db}
+}
+
+// GetContests получает список соревнований, начиная с ближайших
+func (s *ContestService) GetContests(page, limit int) ([]*models.Contest, int64, error) {
+	var contests []*models.Contest
+	var total int64
+
+	query := s.db.Model(&models.Contest{}).Where("is_active = ?", true)
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	if err := query.Order("start_time DESC").Offset(offset).Limit(limit).Find(&contests).Error; err != nil {
+		return nil, 0, fmt.Errorf("ошибка получения соревнований: %w", err)
+	}
+
+	return contests, total, nil
+}
+
+// GetContest возвращает соревнование для участника. Условия задач видны только
+// после начала соревнования или администратору
+func (s *ContestService) GetContest(id, userID uint, isAdmin bool) (*ContestView, error) {
+	contest, err := s.getContest(id, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	registered, err := s.isRegistered(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &ContestView{
+		Contest:      contest,
+		Status:       contestStatus(contest, time.Now()),
+		IsRegistered: registered,
+		Problems:     []ContestProblemView{},
+	}
+
+	if view.Status == ContestStatusUpcoming && !isAdmin {
+		return view, nil
+	}
+
+	problems, err := s.contestProblems(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, cp := range problems {
+		view.Problems = append(view.Problems, ContestProblemView{
+			Label:       cp.Label,
+			ProblemID:   cp.ProblemID,
+			Title:       cp.Problem.Title,
+			Description: cp.Problem.Description,
+			Difficulty:  cp.Problem.Difficulty,
+			InitialCode: cp.Problem.InitialCode,
+			TimeLimit:   cp.Problem.TimeLimit,
+			MemoryLimit: cp.Problem.MemoryLimit,
+			Points:      contestProblemPoints(cp),
+		})
+	}
+
+	return view, nil
+}
+
+// Register регистрирует пользователя на соревнование. Регистрация открыта до окончания соревнования
+func (s *ContestService) Register(contestID, userID uint) error {
+	contest, err := s.getContest(contestID, false)
+	if err != nil {
+		return err
+	}
+	if contestStatus(contest, time.Now()) == ContestStatusFinished {
+		return errors.New("соревнование уже завершено")
+	}
+
+	registration := &models.ContestRegistration{
+		ContestID:    contestID,
+		UserID:       userID,
+		RegisteredAt: time.Now(),
+	}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(registration).Error; err != nil {
+		return fmt.Errorf("ошибка регистрации на соревнование: %w", err)
+	}
+	return nil
+}
+
+// ContestProblemForSubmission проверяет, что пользователь может отправить решение
+// задачи с меткой label, и возвращает задачу соревнования
+func (s *ContestService) ContestProblemForSubmission(contestID, userID uint, label string) (*models.ContestProblem, error) {
+	contest, err := s.getContest(contestID, false)
+	if err != nil {
+		return nil, err
+	}
+	switch contestStatus(contest, time.Now()) {
+	case ContestStatusUpcoming:
+		return nil, errors.New("соревнование ещё не началось")
+	case ContestStatusFinished:
+		return nil, errors.New("соревнование завершено")
+	}
+
+	registered, err := s.isRegistered(contestID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !registered {
+		return nil, errors.New("вы не зарегистрированы на соревнование")
+	}
+
+	var cp models.ContestProblem
+	if err := s.db.Preload("Problem").
+		Where("contest_id = ? AND label = ?", contestID, strings.ToUpper(label)).
+		First(&cp).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("задача не найдена")
+		}
+		return nil, fmt.Errorf("ошибка получения задачи: %w", err)
+	}
+
+	return &cp, nil
+}
+
+// GetUserSubmissions возвращает отправки пользователя в рамках соревнования
+func (s *ContestService) GetUserSubmissions(contestID, userID uint) ([]*models.UserSubmission, error) {
+	var submissions []*models.UserSubmission
+	if err := s.db.Where("contest_id = ? AND user_id = ?", contestID, userID).
+		Order("created_at DESC").
+		Find(&submissions).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения отправок: %w", err)
+	}
+	return submissions, nil
+}
+
+// GetScoreboard строит таблицу результатов по отправкам соревнования. Во время
+// заморозки результаты отправок после её начала скрыты от всех, кроме администраторов,
+// пока таблица не будет разморожена
+func (s *ContestService) GetScoreboard(contestID uint, isAdmin bool) (*Scoreboard, error) {
+	contest, err := s.getContest(contestID, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	problems, err := s.contestProblems(contestID)
+	if err != nil {
+		return nil, err
+	}
+
+	var registrations []models.ContestRegistration
+	if err := s.db.Preload("User").
+		Where("contest_id = ?", contestID).
+		Find(&registrations).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения участников: %w", err)
+	}
+
+	var submissions []models.UserSubmission
+	if err := s.db.Select("id, user_id, problem_id, status, score, created_at").
+		Where("contest_id = ? AND created_at >= ? AND created_at <= ?", contestID, contest.StartTime, contest.EndTime).
+		Order("created_at ASC, id ASC").
+		Find(&submissions).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения отправок: %w", err)
+	}
+
+	board := &Scoreboard{
+		ContestID:   contest.ID,
+		ScoringType: contest.ScoringType,
+		Problems:    make([]string, 0, len(problems)),
+		Rows:        make([]ScoreboardRow, 0, len(registrations)),
+	}
+
+	freezeStart := contest.EndTime.Add(-time.Duration(contest.FreezeMinutes) * time.Minute)
+	if contest.FreezeMinutes > 0 && !contest.ScoreboardUnfrozen && !isAdmin && !time.Now().Before(freezeStart) {
+		board.IsFrozen = true
+		board.FrozenAt = &freezeStart
+	}
+
+	labelIndex := make(map[uint]int, len(problems))
+	for i, cp := range problems {
+		labelIndex[cp.ProblemID] = i
+		board.Problems = append(board.Problems, cp.Label)
+	}
+
+	rowIndex := make(map[uint]int, len(registrations))
+	for _, registration := range registrations {
+		row := ScoreboardRow{
+			UserID:   registration.UserID,
+			UserName: registration.User.Name,
+			Cells:    make([]ScoreboardCell, len(problems)),
+		}
+		for i, cp := range problems {
+			row.Cells[i].Label = cp.Label
+		}
+		rowIndex[registration.UserID] = len(board.Rows)
+		board.Rows = append(board.Rows, row)
+	}
+
+	// Число уже учтённых проверенных попыток по каждой ячейке
+	tries := make(map[[2]int]int)
+
+	for _, submission := range submissions {
+		r, ok := rowIndex[submission.UserID]
+		if !ok {
+			continue
+		}
+		p, ok := labelIndex[submission.ProblemID]
+		if !ok {
+			continue
+		}
+		cell := &board.Rows[r].Cells[p]
+		if cell.IsSolved {
+			continue
+		}
+
+		// Сбой проверяющей системы не учитывается вовсе
+		if submission.Status == models.SubmissionStatusSystemError {
+			continue
+		}
+
+		hidden := board.IsFrozen && !submission.CreatedAt.Before(freezeStart)
+		if hidden || submission.Status == models.SubmissionStatusPending || submission.Status == models.SubmissionStatusRunning {
+			cell.Pending++
+			continue
+		}
+		// Ошибка компиляции не считается попыткой
+		if submission.Status == models.SubmissionStatusCompileError {
+			continue
+		}
+
+		key := [2]int{r, p}
+		minutes := int(submission.CreatedAt.Sub(contest.StartTime) / time.Minute)
+
+		if contest.ScoringType == models.ContestScoringPoints {
+			score := contestProblemPoints(problems[p]) * submission.Score / 100
+			if submission.Status == models.SubmissionStatusAccepted {
+				score = contestProblemPoints(problems[p])
+			}
+			if score > cell.Score {
+				cell.Score = score
+				cell.Time = minutes
+				cell.Attempts = tries[key]
+				cell.IsSolved = submission.Status == models.SubmissionStatusAccepted
+			}
+		} else if submission.Status == models.SubmissionStatusAccepted {
+			cell.IsSolved = true
+			cell.Time = minutes
+			cell.Attempts = tries[key]
+		}
+		tries[key]++
+		if cell.Score == 0 && !cell.IsSolved {
+			cell.Attempts = tries[key]
+		}
+	}
+
+	for i := range board.Rows {
+		row := &board.Rows[i]
+		for _, cell := range row.Cells {
+			if cell.IsSolved {
+				row.Solved++
+			}
+			if cell.IsSolved || cell.Score > 0 {
+				row.Score += cell.Score
+				row.Penalty += cell.Time + cell.Attempts*contest.PenaltyMinutes
+			}
+		}
+	}
+
+	rankScoreboard(board)
+	return board, nil
+}
+
+// rankScoreboard сортирует строки таблицы и проставляет места. Участники с
+// одинаковым результатом делят место
+func rankScoreboard(board *Scoreboard) {
+	primary := func(row ScoreboardRow) int {
+		if board.ScoringType == models.ContestScoringPoints {
+			return row.Score
+		}
+		return row.Solved
+	}
+
+	sort.SliceStable(board.Rows, func(i, j int) bool {
+		a, b := board.Rows[i], board.Rows[j]
+		if primary(a) != primary(b) {
+			return primary(a) > primary(b)
+		}
+		if a.Penalty != b.Penalty {
+			return a.Penalty < b.Penalty
+		}
+		return a.UserName < b.UserName
+	})
+
+	for i := range board.Rows {
+		if i > 0 && primary(board.Rows[i]) == primary(board.Rows[i-1]) && board.Rows[i].Penalty == board.Rows[i-1].Penalty {
+			board.Rows[i].Rank = board.Rows[i-1].Rank
+		} else {
+			board.Rows[i].Rank = i + 1
+		}
+	}
+}
+
+func (s *ContestService) getContest(id uint, includeInactive bool) (*models.Contest, error) {
+	query := s.db
+	if !includeInactive {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var contest models.Contest
+	if err := query.First(&contest, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("соревнование не найдено")
+		}
+		return nil, fmt.Errorf("ошибка получения соревнования: %w", err)
+	}
+	return &contest, nil
+}
+
+func (s *ContestService) contestProblems(contestID uint) ([]models.ContestProblem, error) {
+	var problems []models.ContestProblem
+	if err := s.db.Preload("Problem").
+		Where("contest_id = ?", contestID).
+		Order("label ASC").
+		Find(&problems).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения задач соревнования: %w", err)
+	}
+	return problems, nil
+}
+
+func (s *ContestService) isRegistered(contestID, userID uint) (bool, error) {
+	var count int64
+	if err := s.db.Model(&models.ContestRegistration{}).
+		Where("contest_id = ? AND user_id = ?", contestID, userID).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("ошибка проверки регистрации: %w", err)
+	}
+	return count > 0, nil
+}
+
+func contestStatus(contest *models.Contest, now time.Time) string {
+	switch {
+	case now.Before(contest.StartTime):
+		return ContestStatusUpcoming
+	case now.After(contest.EndTime):
+		return ContestStatusFinished
+	default:
+		return ContestStatusRunning
+	}
+}
+
+func contestProblemPoints(cp models.ContestProblem) int {
+	if cp.Points > 0 {
+		return cp.Points
+	}
+	return cp.Problem.Points
+}
+
+// Admin methods
+
+// CreateContest создает соревнование вместе со списком задач
+func (s *ContestService) CreateContest(req *CreateContestRequest) (*models.Contest, error) {
+	if err := validateContestRequest(req.StartTime, req.EndTime, req.FreezeMinutes, req.Problems); err != nil {
+		return nil, err
+	}
+
+	contest := &models.Contest{
+		Title:          req.Title,
+		Description:    req.Description,
+		ScoringType:    req.ScoringType,
+		StartTime:      req.StartTime,
+		EndTime:        req.EndTime,
+		FreezeMinutes:  req.FreezeMinutes,
+		PenaltyMinutes: 20,
+	}
+	if contest.ScoringType == "" {
+		contest.ScoringType = models.ContestScoringICPC
+	}
+	if req.PenaltyMinutes != nil {
+		contest.PenaltyMinutes = *req.PenaltyMinutes
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(contest).Error; err != nil {
+			return fmt.Errorf("ошибка создания соревнования: %w", err)
+		}
+		// Явно сохраняем нулевой штраф, который иначе заменяется значением по умолчанию
+		if contest.PenaltyMinutes == 0 {
+			if err := tx.Model(contest).Update("penalty_minutes", 0).Error; err != nil {
+				return fmt.Errorf("ошибка создания соревнования: %w", err)
+			}
+		}
+		return replaceContestProblems(tx, contest.ID, req.Problems)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getContestWithProblems(contest.ID)
+}
+
+// UpdateContest обновляет соревнование. Если передан список задач, он заменяет текущий
+func (s *ContestService) UpdateContest(id uint, req *UpdateContestRequest) (*models.Contest, error) {
+	contest, err := s.getContest(id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Title != "" {
+		contest.Title = req.Title
+	}
+	if req.Description != "" {
+		contest.Description = req.Description
+	}
+	if req.ScoringType != "" {
+		contest.ScoringType = req.ScoringType
+	}
+	if req.StartTime != nil {
+		contest.StartTime = *req.StartTime
+	}
+	if req.EndTime != nil {
+		contest.EndTime = *req.EndTime
+	}
+	if req.FreezeMinutes != nil {
+		contest.FreezeMinutes = *req.FreezeMinutes
+	}
+	if req.PenaltyMinutes != nil {
+		contest.PenaltyMinutes = *req.PenaltyMinutes
+	}
+	if req.IsActive != nil {
+		contest.IsActive = *req.IsActive
+	}
+
+	if err := validateContestRequest(contest.StartTime, contest.EndTime, contest.FreezeMinutes, req.Problems); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(contest).Error; err != nil {
+			return fmt.Errorf("ошибка обновления соревнования: %w", err)
+		}
+		if req.Problems == nil {
+			return nil
+		}
+		return replaceContestProblems(tx, contest.ID, req.Problems)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getContestWithProblems(contest.ID)
+}
+
+// DeleteContest удаляет соревнование. Отправки участников сохраняются как обычные решения
+func (s *ContestService) DeleteContest(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("contest_id = ?", id).Delete(&models.ContestProblem{}).Error; err != nil {
+			return fmt.Errorf("ошибка удаления задач соревнования: %w", err)
+		}
+		if err := tx.Where("contest_id = ?", id).Delete(&models.ContestRegistration{}).Error; err != nil {
+			return fmt.Errorf("ошибка удаления регистраций: %w", err)
+		}
+		if err := tx.Model(&models.UserSubmission{}).
+			Where("contest_id = ?", id).
+			Update("contest_id", nil).Error; err != nil {
+			return fmt.Errorf("ошибка отвязки отправок: %w", err)
+		}
+		if err := tx.Delete(&models.Contest{}, id).Error; err != nil {
+			return fmt.Errorf("ошибка удаления соревнования: %w", err)
+		}
+		return nil
+	})
+}
+
+// UnfreezeScoreboard открывает участникам результаты периода заморозки
+func (s *ContestService) UnfreezeScoreboard(id uint) error {
+	if _, err := s.getContest(id, true); err != nil {
+		return err
+	}
+	if err := s.db.Model(&models.Contest{}).
+		Where("id = ?", id).
+		Update("scoreboard_unfrozen", true).Error; err != nil {
+		return fmt.Errorf("ошибка разморозки таблицы: %w", err)
+	}
+	return nil
+}
+
+func (s *ContestService) getContestWithProblems(id uint) (*models.Contest, error) {
+	var contest models.Contest
+	if err := s.db.Preload("Problems", func(db *gorm.DB) *gorm.DB {
+		return db.Order("label ASC")
+	}).First(&contest, id).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения соревнования: %w", err)
+	}
+	return &contest, nil
+}
+
+func validateContestRequest(start, end time.Time, freezeMinutes int, problems []ContestProblemRequest) error {
+	if !end.After(start) {
+		return errors.New("время окончания должно быть позже времени начала")
+	}
+	if time.Duration(freezeMinutes)*time.Minute > end.Sub(start) {
+		return errors.New("период заморозки длиннее соревнования")
+	}
+
+	labels := make(map[string]bool, len(problems))
+	ids := make(map[uint]bool, len(problems))
+	for _, p := range problems {
+		label := strings.ToUpper(p.Label)
+		if labels[label] {
+			return fmt.Errorf("метка задачи %q используется дважды", label)
+		}
+		if ids[p.ProblemID] {
+			return fmt.Errorf("задача %d добавлена дважды", p.ProblemID)
+		}
+		labels[label] = true
+		ids[p.ProblemID] = true
+	}
+	return nil
+}
+
+func replaceContestProblems(tx *gorm.DB, contestID uint, problems []ContestProblemRequest) error {
+	if err := tx.Where("contest_id = ?", contestID).Delete(&models.ContestProblem{}).Error; err != nil {
+		return fmt.Errorf("ошибка обновления задач соревнования: %w", err)
+	}
+
+	for _, p := range problems {
+		var count int64
+		if err := tx.Model(&models.Problem{}).Where("id = ?", p.ProblemID).Count(&count).Error; err != nil {
+			return fmt.Errorf("ошибка проверки задачи: %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("задача %d не найдена", p.ProblemID)
+		}
+
+		cp := &models.ContestProblem{
+			ContestID: contestID,
+			ProblemID: p.ProblemID,
+			Label:     strings.ToUpper(p.Label),
+			Points:    p.Points,
+		}
+		if err := tx.Create(cp).Error; err != nil {
+			return fmt.Errorf("ошибка добавления задачи в соревнование: %w", err)
+		}
+	}
+	return nil
+}
+
+// ContestView представление соревнования для участника
+type ContestView struct {
+	*models.Contest
+	Status       string               `json:"status"`
+	IsRegistered bool                 `json:"is_registered"`
+	Problems     []ContestProblemView `json:"problems"`
+}
+
+// ContestProblemView условие задачи соревнования без тестов
+type ContestProblemView struct {
+	Label       string              `json:"label"`
+	ProblemID   uint                `json:"problem_id"`
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Difficulty  models.ProblemLevel `json:"difficulty"`
+	InitialCode string              `json:"initial_code"`
+	TimeLimit   int                 `json:"time_limit"`
+	MemoryLimit int                 `json:"memory_limit"`
+	Points      int                 `json:"points"`
+}
+
+// Scoreboard таблица результатов соревнования
+type Scoreboard struct {
+	ContestID   uint                      `json:"contest_id"`
+	ScoringType models.ContestScoringType `json:"scoring_type"`
+	IsFrozen    bool                      `json:"is_frozen"`
+	FrozenAt    *time.Time                `json:"frozen_at,omitempty"`
+	Problems    []string                  `json:"problems"`
+	Rows        []ScoreboardRow           `json:"rows"`
+}
+
+// ScoreboardRow строка таблицы результатов
+type ScoreboardRow struct {
+	Rank     int              `json:"rank"`
+	UserID   uint             `json:"user_id"`
+	UserName string           `json:"user_name"`
+	Solved   int              `json:"solved"`
+	Score    int              `json:"score"`
+	Penalty  int              `json:"penalty"` // в минутах
+	Cells    []ScoreboardCell `json:"cells"`
+}
+
+// ScoreboardCell результат участника по одной задаче
+type ScoreboardCell struct {
+	Label    string `json:"label"`
+	IsSolved bool   `json:"is_solved"`
+	Score    int    `json:"score"`
+	Attempts int    `json:"attempts"` // неверные попытки до засчитанной
+	Pending  int    `json:"pending"`  // непроверенные или скрытые заморозкой попытки
+	Time     int    `json:"time"`     // минута засчитанной попытки от начала соревнования
+}
+
+type CreateContestRequest struct {
+	Title          string                    `json:"title" binding:"required"`
+	Description    string                    `json:"description"`
+	ScoringType    models.ContestScoringType `json:"scoring_type" binding:"omitempty,oneof=icpc points"`
+	StartTime      time.Time                 `json:"start_time" binding:"required"`
+	EndTime        time.Time                 `json:"end_time" binding:"required"`
+	FreezeMinutes  int                       `json:"freeze_minutes" binding:"omitempty,min=0"`
+	PenaltyMinutes *int                      `json:"penalty_minutes" binding:"omitempty,min=0"`
+	Problems       []ContestProblemRequest   `json:"problems" binding:"dive"`
+}
+
+type UpdateContestRequest struct {
+	Title          string                    `json:"title" binding:"omitempty"`
+	Description    string                    `json:"description" binding:"omitempty"`
+	ScoringType    models.ContestScoringType `json:"scoring_type" binding:"omitempty,oneof=icpc points"`
+	StartTime      *time.Time                `json:"start_time" binding:"omitempty"`
+	EndTime        *time.Time                `json:"end_time" binding:"omitempty"`
+	FreezeMinutes  *int                      `json:"freeze_minutes" binding:"omitempty,min=0"`
+	PenaltyMinutes *int                      `json:"penalty_minutes" binding:"omitempty,min=0"`
+	IsActive       *bool                     `json:"is_active" binding:"omitempty"`
+	Problems       []ContestProblemRequest   `json:"problems" binding:"omitempty,dive"`
+}
+
+type ContestProblemRequest struct {
+	ProblemID uint   `json:"problem_id" binding:"required"`
+	Label     string `json:"label" binding:"required,max=10"`
+	Points    int    `json:"points" binding:"omitempty,min=0"`
+}
@@ -9,6 +9,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrCourseLocked возвращается при открытии уроков курса, закрытого по уровню выше уровня пользователя
+var ErrCourseLocked = errors.New("курс откроется на более высоком уровне")
+
 type CourseService struct {
 	db *gorm.DB
 }
@@ -42,7 +45,11 @@ func (s *CourseService) GetCourseByID(id uint) (*models.Course, error) {
 }
 
 // GetCourseSections получает разделы курса
-func (s *CourseService) GetCourseSections(courseID uint) ([]*models.Section, error) {
+func (s *CourseService) GetCourseSections(courseID, userID uint) ([]*models.Section, error) {
+	if err := s.checkCourseAccess(courseID, userID); err != nil {
+		return nil, err
+	}
+
 	var sections []*models.Section
 	if err := s.db.Where("course_id = ? AND is_active = ?", courseID, true).
 		Order("\"order\" ASC").
@@ -56,7 +63,7 @@ func (s *CourseService) GetCourseSections(courseID uint) ([]*models.Section, err
 }
 
 // GetLessonByID получает урок по ID
-func (s *CourseService) GetLessonByID(id uint) (*models.Lesson, error) {
+func (s *CourseService) GetLessonByID(id, userID uint) (*models.Lesson, error) {
 	var lesson models.Lesson
 	if err := s.db.Where("is_active = ?", true).
 		Preload("Section").
@@ -66,9 +73,36 @@ func (s *CourseService) GetLessonByID(id uint) (*models.Lesson, error) {
 		}
 		return nil, fmt.Errorf("ошибка получения урока: %w", err)
 	}
+	if err := s.checkCourseAccess(lesson.Section.CourseID, userID); err != nil {
+		return nil, err
+	}
 	return &lesson, nil
 }
 
+// checkCourseAccess проверяет, что уровень пользователя не ниже уровня курса,
+// если курс закрыт по уровню. Администраторам доступны все курсы
+func (s *CourseService) checkCourseAccess(courseID, userID uint) error {
+	var course models.Course
+	if err := s.db.Select("id", "level", "level_locked").First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("курс не найден")
+		}
+		return fmt.Errorf("ошибка получения курса: %w", err)
+	}
+	if !course.LevelLocked {
+		return nil
+	}
+
+	var user models.User
+	if err := s.db.Select("id", "role", "level").First(&user, userID).Error; err != nil {
+		return errors.New("пользователь не найден")
+	}
+	if user.Role != models.UserRoleAdmin && user.Level.Rank() < course.Level.Rank() {
+		return ErrCourseLocked
+	}
+	return nil
+}
+
 // Admin methods
 
 // CreateCourse создает новый курс
@@ -78,8 +112,13 @@ func (s *CourseService) CreateCourse(req *CreateCourseRequest) (*models.Course,
 		Description: req.Description,
 		ImageURL:    req.ImageURL,
 		Order:       req.Order,
+		Level:       models.UserLevelJunior,
+		LevelLocked: req.LevelLocked,
 		IsActive:    true,
 	}
+	if req.Level != "" {
+		course.Level = models.UserLevel(req.Level)
+	}
 
 	if err := s.db.Create(course).Error; err != nil {
 		return nil, fmt.Errorf("ошибка создания курса: %w", err)
@@ -114,6 +153,12 @@ func (s *CourseService) UpdateCourse(id uint, req *UpdateCourseRequest) (*models
 	if req.IsActive != nil {
 		course.IsActive = *req.IsActive
 	}
+	if req.Level != "" {
+		course.Level = models.UserLevel(req.Level)
+	}
+	if req.LevelLocked != nil {
+		course.LevelLocked = *req.LevelLocked
+	}
 
 	if err := s.db.Save(&course).Error; err != nil {
 		return nil, fmt.Errorf("ошибка обновления курса: %w", err)
@@ -256,6 +301,8 @@ type CreateCourseRequest struct {
 	Description string `json:"description" binding:"omitempty,max=500"`
 	ImageURL    string `json:"image_url" binding:"omitempty,url"`
 	Order       int    `json:"order" binding:"omitempty,min=0"`
+	Level       string `json:"level" binding:"omitempty,oneof=junior middle senior"`
+	LevelLocked bool   `json:"level_locked"` // закрыть уроки для пользователей ниже уровня Level
 }
 
 type UpdateCourseRequest struct {
@@ -263,6 +310,8 @@ type UpdateCourseRequest struct {
 	Description string `json:"description" binding:"omitempty,max=500"`
 	ImageURL    string `json:"image_url" binding:"omitempty,url"`
 	Order       int    `json:"order" binding:"omitempty,min=0"`
+	Level       string `json:"level" binding:"omitempty,oneof=junior middle senior"`
+	LevelLocked *bool  `json:"level_locked"`
 	IsActive    *bool  `json:"is_active"`
 }
 
@@ -0,0 +1,645 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrSpoilerLocked возвращается при обращении к теме с разбором решения до того,
+// как пользователь решил задачу
+var ErrSpoilerLocked = errors.New("обсуждение решения откроется после принятого решения задачи")
+
+var errThreadNotFound = errors.New("тема не найдена")
+
+type DiscussionService struct {
+	db *gorm.DB
+}
+
+func NewDiscussionService(db *gorm.DB) *DiscussionService {
+	return &DiscussionService{db: db}
+}
+
+// DiscussionAuthor публичные данные автора темы или сообщения
+type DiscussionAuthor struct {
+	ID     uint             `json:"id"`
+	Name   string           `json:"name"`
+	Avatar string           `json:"avatar"`
+	Level  models.UserLevel `json:"level"`
+}
+
+// DiscussionThreadView тема в списке обсуждений. У закрытой спойлером темы
+// текст скрыт и Hidden выставлен в true
+type DiscussionThreadView struct {
+	models.DiscussionThread
+	Author *DiscussionAuthor `json:"author"`
+	Hidden bool              `json:"hidden"`
+}
+
+// DiscussionPostView сообщение темы с голосом текущего пользователя
+type DiscussionPostView struct {
+	models.DiscussionPost
+	Author     *DiscussionAuthor `json:"author"`
+	MyVote     int               `json:"my_vote"`
+	IsAccepted bool              `json:"is_accepted"`
+}
+
+// DiscussionThreadDetails тема со всеми сообщениями. Сообщения отсортированы по
+// времени создания, дерево ответов строится по ParentID
+type DiscussionThreadDetails struct {
+	Thread DiscussionThreadView `json:"thread"`
+	Posts  []DiscussionPostView `json:"posts"`
+}
+
+// GetThreads возвращает темы обсуждения задачи или урока. Закреплённые темы идут
+// первыми, затем по последней активности или, для sort=top, по числу сообщений
+func (s *DiscussionService) GetThreads(entityType models.DiscussionEntityType, entityID, userID uint, role, sort string, page, limit int) ([]DiscussionThreadView, int64, error) {
+	if err := s.checkEntity(entityType, entityID); err != nil {
+		return nil, 0, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := s.db.Model(&models.DiscussionThread{}).
+		Where("entity_type = ? AND entity_id = ?", entityType, entityID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("ошибка подсчёта тем: %w", err)
+	}
+
+	order := "is_pinned DESC, last_activity_at DESC"
+	if sort == "top" {
+		order = "is_pinned DESC, posts_count DESC, last_activity_at DESC"
+	}
+
+	var threads []models.DiscussionThread
+	if err := query.Order(order).
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&threads).Error; err != nil {
+		return nil, 0, fmt.Errorf("ошибка получения тем: %w", err)
+	}
+
+	spoilersOpen, err := s.spoilersOpen(entityType, entityID, userID, role)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	authorIDs := make([]uint, 0, len(threads))
+	for _, thread := range threads {
+		authorIDs = append(authorIDs, thread.AuthorID)
+	}
+	authors, err := s.loadAuthors(authorIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	views := make([]DiscussionThreadView, 0, len(threads))
+	for _, thread := range threads {
+		views = append(views, threadView(thread, authors, spoilersOpen))
+	}
+	return views, total, nil
+}
+
+// GetThread возвращает тему с сообщениями
+func (s *DiscussionService) GetThread(id, userID uint, role string) (*DiscussionThreadDetails, error) {
+	thread, err := s.accessibleThread(id, userID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []models.DiscussionPost
+	if err := s.db.Where("thread_id = ?", thread.ID).
+		Order("created_at ASC, id ASC").
+		Find(&posts).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения сообщений: %w", err)
+	}
+
+	authorIDs := []uint{thread.AuthorID}
+	postIDs := make([]uint, 0, len(posts))
+	for _, post := range posts {
+		authorIDs = append(authorIDs, post.AuthorID)
+		postIDs = append(postIDs, post.ID)
+	}
+	authors, err := s.loadAuthors(authorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	myVotes := make(map[uint]int)
+	if len(postIDs) > 0 {
+		var votes []models.DiscussionVote
+		if err := s.db.Where("user_id = ? AND post_id IN ?", userID, postIDs).Find(&votes).Error; err != nil {
+			return nil, fmt.Errorf("ошибка получения голосов: %w", err)
+		}
+		for _, vote := range votes {
+			myVotes[vote.PostID] = vote.Value
+		}
+	}
+
+	details := &DiscussionThreadDetails{
+		Thread: threadView(*thread, authors, true),
+		Posts:  make([]DiscussionPostView, 0, len(posts)),
+	}
+	for _, post := range posts {
+		view := DiscussionPostView{
+			DiscussionPost: post,
+			MyVote:         myVotes[post.ID],
+			IsAccepted:     thread.AcceptedPostID != nil && *thread.AcceptedPostID == post.ID,
+		}
+		if !post.IsDeleted {
+			view.Author = authors[post.AuthorID]
+		}
+		details.Posts = append(details.Posts, view)
+	}
+	return details, nil
+}
+
+// CreateThread создаёт тему. Тему с разбором решения может открыть только решивший задачу
+func (s *DiscussionService) CreateThread(entityType models.DiscussionEntityType, entityID, userID uint, role string, req *CreateThreadRequest) (*models.DiscussionThread, error) {
+	if err := s.checkEntity(entityType, entityID); err != nil {
+		return nil, err
+	}
+	if req.IsSpoiler {
+		if entityType != models.DiscussionEntityProblem {
+			return nil, errors.New("разбор решения можно обсуждать только у задач")
+		}
+		open, err := s.spoilersOpen(entityType, entityID, userID, role)
+		if err != nil {
+			return nil, err
+		}
+		if !open {
+			return nil, errors.New("обсуждать решение могут только решившие задачу")
+		}
+	}
+
+	thread := &models.DiscussionThread{
+		EntityType:     entityType,
+		EntityID:       entityID,
+		AuthorID:       userID,
+		Title:          req.Title,
+		Body:           req.Body,
+		IsSpoiler:      req.IsSpoiler,
+		LastActivityAt: time.Now(),
+	}
+	if err := s.db.Create(thread).Error; err != nil {
+		return nil, fmt.Errorf("ошибка создания темы: %w", err)
+	}
+	return thread, nil
+}
+
+// UpdateThread изменяет заголовок и текст темы. Доступно автору и администратору
+func (s *DiscussionService) UpdateThread(id, userID uint, role string, req *UpdateThreadRequest) (*models.DiscussionThread, error) {
+	thread, err := s.getThread(id)
+	if err != nil {
+		return nil, err
+	}
+	if thread.AuthorID != userID && role != string(models.UserRoleAdmin) {
+		return nil, errors.New("изменять тему может только её автор")
+	}
+	if thread.IsLocked && role != string(models.UserRoleAdmin) {
+		return nil, errors.New("тема закрыта модератором")
+	}
+
+	if req.Title != "" {
+		thread.Title = req.Title
+	}
+	if req.Body != "" {
+		thread.Body = req.Body
+	}
+	if err := s.db.Save(thread).Error; err != nil {
+		return nil, fmt.Errorf("ошибка обновления темы: %w", err)
+	}
+	return thread, nil
+}
+
+// AddPost добавляет ответ в тему и уведомляет автора темы и автора сообщения, на которое отвечают
+func (s *DiscussionService) AddPost(threadID, userID uint, role string, req *CreatePostRequest) (*models.DiscussionPost, error) {
+	thread, err := s.accessibleThread(threadID, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	if thread.IsLocked {
+		return nil, errors.New("тема закрыта модератором")
+	}
+
+	recipients := make(map[uint]bool)
+	if thread.AuthorID != userID {
+		recipients[thread.AuthorID] = true
+	}
+	if req.ParentID != nil {
+		var parent models.DiscussionPost
+		if err := s.db.Where("id = ? AND thread_id = ?", *req.ParentID, thread.ID).First(&parent).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("сообщение, на которое вы отвечаете, не найдено")
+			}
+			return nil, fmt.Errorf("ошибка получения сообщения: %w", err)
+		}
+		if parent.IsDeleted {
+			return nil, errors.New("нельзя ответить на удалённое сообщение")
+		}
+		if parent.AuthorID != userID {
+			recipients[parent.AuthorID] = true
+		}
+	}
+
+	post := &models.DiscussionPost{
+		ThreadID: thread.ID,
+		ParentID: req.ParentID,
+		AuthorID: userID,
+		Body:     req.Body,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(post).Error; err != nil {
+			return fmt.Errorf("ошибка создания сообщения: %w", err)
+		}
+		if err := tx.Model(thread).Updates(map[string]interface{}{
+			"posts_count":      gorm.Expr("posts_count + 1"),
+			"last_activity_at": post.CreatedAt,
+		}).Error; err != nil {
+			return fmt.Errorf("ошибка обновления темы: %w", err)
+		}
+
+		userIDs := make([]uint, 0, len(recipients))
+		for id := range recipients {
+			userIDs = append(userIDs, id)
+		}
+		return notify(tx, userIDs, models.NotificationDiscussionReply,
+			"Новый ответ в обсуждении «"+truncateText(thread.Title, 80)+"»", truncateText(req.Body, 200), "discussion", thread.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+// UpdatePost изменяет текст сообщения. Доступно только автору
+func (s *DiscussionService) UpdatePost(postID, userID uint, req *UpdatePostRequest) (*models.DiscussionPost, error) {
+	post, err := s.getPost(postID)
+	if err != nil {
+		return nil, err
+	}
+	if post.AuthorID != userID {
+		return nil, errors.New("изменять сообщение может только его автор")
+	}
+	if post.IsDeleted {
+		return nil, errors.New("сообщение удалено")
+	}
+	thread, err := s.getThread(post.ThreadID)
+	if err != nil {
+		return nil, err
+	}
+	if thread.IsLocked {
+		return nil, errors.New("тема закрыта модератором")
+	}
+
+	post.Body = req.Body
+	if err := s.db.Save(post).Error; err != nil {
+		return nil, fmt.Errorf("ошибка обновления сообщения: %w", err)
+	}
+	return post, nil
+}
+
+// DeletePost удаляет сообщение, оставляя его место в дереве ответов.
+// Доступно автору и администратору. Удалённое сообщение не входит в счётчик сообщений темы
+func (s *DiscussionService) DeletePost(postID, userID uint, role string) error {
+	post, err := s.getPost(postID)
+	if err != nil {
+		return err
+	}
+	if post.AuthorID != userID && role != string(models.UserRoleAdmin) {
+		return errors.New("удалять сообщение может только его автор или администратор")
+	}
+	if post.IsDeleted {
+		return nil
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Условие на is_deleted не даёт параллельному удалению уменьшить счётчик дважды
+		result := tx.Model(post).Where("is_deleted = ?", false).
+			Updates(map[string]interface{}{"is_deleted": true, "body": ""})
+		if result.Error != nil {
+			return fmt.Errorf("ошибка удаления сообщения: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if err := tx.Model(&models.DiscussionThread{}).
+			Where("id = ? AND accepted_post_id = ?", post.ThreadID, post.ID).
+			Update("accepted_post_id", nil).Error; err != nil {
+			return fmt.Errorf("ошибка обновления темы: %w", err)
+		}
+		if err := tx.Model(&models.DiscussionThread{}).
+			Where("id = ? AND posts_count > 0", post.ThreadID).
+			Update("posts_count", gorm.Expr("posts_count - 1")).Error; err != nil {
+			return fmt.Errorf("ошибка обновления темы: %w", err)
+		}
+		return nil
+	})
+}
+
+// Vote учитывает голос пользователя за сообщение: 1, -1 или 0 для отмены голоса
+func (s *DiscussionService) Vote(postID, userID uint, role string, value int) (int, error) {
+	post, err := s.getPost(postID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.accessibleThread(post.ThreadID, userID, role); err != nil {
+		return 0, err
+	}
+	if post.AuthorID == userID {
+		return 0, errors.New("нельзя голосовать за своё сообщение")
+	}
+	if post.IsDeleted {
+		return 0, errors.New("сообщение удалено")
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Строка голоса создаётся заранее, чтобы её можно было заблокировать:
+		// иначе два параллельных первых голоса оба увидят previous = 0 и
+		// дважды изменят рейтинг
+		placeholder := models.DiscussionVote{PostID: post.ID, UserID: userID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&placeholder).Error; err != nil {
+			return fmt.Errorf("ошибка сохранения голоса: %w", err)
+		}
+
+		var existing models.DiscussionVote
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("post_id = ? AND user_id = ?", post.ID, userID).
+			First(&existing).Error; err != nil {
+			return fmt.Errorf("ошибка получения голоса: %w", err)
+		}
+		previous := existing.Value
+
+		if value == 0 {
+			if err := tx.Delete(&existing).Error; err != nil {
+				return fmt.Errorf("ошибка отмены голоса: %w", err)
+			}
+		} else if value != previous {
+			if err := tx.Model(&existing).Update("value", value).Error; err != nil {
+				return fmt.Errorf("ошибка сохранения голоса: %w", err)
+			}
+		}
+		if previous == value {
+			return tx.Select("score").First(post, post.ID).Error
+		}
+
+		if err := tx.Model(post).Update("score", gorm.Expr("score + ?", value-previous)).Error; err != nil {
+			return fmt.Errorf("ошибка обновления рейтинга: %w", err)
+		}
+		return tx.Select("score").First(post, post.ID).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return post.Score, nil
+}
+
+// AcceptAnswer отмечает сообщение как принятый ответ; nil снимает отметку.
+// Доступно автору темы и администратору
+func (s *DiscussionService) AcceptAnswer(threadID, userID uint, role string, postID *uint) error {
+	thread, err := s.getThread(threadID)
+	if err != nil {
+		return err
+	}
+	if thread.AuthorID != userID && role != string(models.UserRoleAdmin) {
+		return errors.New("принять ответ может только автор темы")
+	}
+
+	if postID == nil {
+		if err := s.db.Model(thread).Update("accepted_post_id", nil).Error; err != nil {
+			return fmt.Errorf("ошибка обновления темы: %w", err)
+		}
+		return nil
+	}
+
+	post, err := s.getPost(*postID)
+	if err != nil {
+		return err
+	}
+	if post.ThreadID != thread.ID {
+		return errors.New("сообщение не относится к этой теме")
+	}
+	if post.IsDeleted {
+		return errors.New("сообщение удалено")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(thread).Update("accepted_post_id", post.ID).Error; err != nil {
+			return fmt.Errorf("ошибка обновления темы: %w", err)
+		}
+		if post.AuthorID == userID {
+			return nil
+		}
+		return notify(tx, []uint{post.AuthorID}, models.NotificationAnswerAccepted,
+			"Ваш ответ принят", truncateText(thread.Title, 200), "discussion", thread.ID)
+	})
+}
+
+// Admin methods
+
+// ModerateThread закрепляет, закрывает тему или меняет отметку о спойлере
+func (s *DiscussionService) ModerateThread(id uint, req *ModerateThreadRequest) (*models.DiscussionThread, error) {
+	thread, err := s.getThread(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.IsPinned != nil {
+		thread.IsPinned = *req.IsPinned
+	}
+	if req.IsLocked != nil {
+		thread.IsLocked = *req.IsLocked
+	}
+	if req.IsSpoiler != nil {
+		if *req.IsSpoiler && thread.EntityType != models.DiscussionEntityProblem {
+			return nil, errors.New("разбор решения можно обсуждать только у задач")
+		}
+		thread.IsSpoiler = *req.IsSpoiler
+	}
+
+	if err := s.db.Save(thread).Error; err != nil {
+		return nil, fmt.Errorf("ошибка обновления темы: %w", err)
+	}
+	return thread, nil
+}
+
+// DeleteThread удаляет тему вместе с сообщениями и голосами
+func (s *DiscussionService) DeleteThread(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		postIDs := tx.Model(&models.DiscussionPost{}).Select("id").Where("thread_id = ?", id)
+		if err := tx.Where("post_id IN (?)", postIDs).Delete(&models.DiscussionVote{}).Error; err != nil {
+			return fmt.Errorf("ошибка удаления голосов: %w", err)
+		}
+		if err := tx.Where("thread_id = ?", id).Delete(&models.DiscussionPost{}).Error; err != nil {
+			return fmt.Errorf("ошибка удаления сообщений: %w", err)
+		}
+		result := tx.Delete(&models.DiscussionThread{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("ошибка удаления темы: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errThreadNotFound
+		}
+		return nil
+	})
+}
+
+// accessibleThread загружает тему и проверяет, что пользователь может её читать
+func (s *DiscussionService) accessibleThread(id, userID uint, role string) (*models.DiscussionThread, error) {
+	thread, err := s.getThread(id)
+	if err != nil {
+		return nil, err
+	}
+	if thread.IsSpoiler && thread.AuthorID != userID {
+		open, err := s.spoilersOpen(thread.EntityType, thread.EntityID, userID, role)
+		if err != nil {
+			return nil, err
+		}
+		if !open {
+			return nil, ErrSpoilerLocked
+		}
+	}
+	return thread, nil
+}
+
+func (s *DiscussionService) getThread(id uint) (*models.DiscussionThread, error) {
+	var thread models.DiscussionThread
+	if err := s.db.First(&thread, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errThreadNotFound
+		}
+		return nil, fmt.Errorf("ошибка получения темы: %w", err)
+	}
+	return &thread, nil
+}
+
+func (s *DiscussionService) getPost(id uint) (*models.DiscussionPost, error) {
+	var post models.DiscussionPost
+	if err := s.db.First(&post, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("сообщение не найдено")
+		}
+		return nil, fmt.Errorf("ошибка получения сообщения: %w", err)
+	}
+	return &post, nil
+}
+
+// spoilersOpen проверяет, открыты ли пользователю разборы решения задачи:
+// после принятого решения, а ревьюерам и администраторам — всегда
+func (s *DiscussionService) spoilersOpen(entityType models.DiscussionEntityType, entityID, userID uint, role string) (bool, error) {
+	if entityType != models.DiscussionEntityProblem || isReviewerRole(role) {
+		return true, nil
+	}
+
+	var count int64
+	if err := s.db.Model(&models.UserSubmission{}).
+		Where("user_id = ? AND problem_id = ? AND status = ?", userID, entityID, models.SubmissionStatusAccepted).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("ошибка проверки решения: %w", err)
+	}
+	return count > 0, nil
+}
+
+// checkEntity проверяет, что задача или урок существует и активен
+func (s *DiscussionService) checkEntity(entityType models.DiscussionEntityType, entityID uint) error {
+	var model interface{}
+	switch entityType {
+	case models.DiscussionEntityProblem:
+		model = &models.Problem{}
+	case models.DiscussionEntityLesson:
+		model = &models.Lesson{}
+	default:
+		return fmt.Errorf("неизвестный тип обсуждения: %s", entityType)
+	}
+
+	var count int64
+	if err := s.db.Model(model).Where("id = ? AND is_active = ?", entityID, true).Count(&count).Error; err != nil {
+		return fmt.Errorf("ошибка проверки объекта обсуждения: %w", err)
+	}
+	if count == 0 {
+		if entityType == models.DiscussionEntityProblem {
+			return errors.New("задача не найдена")
+		}
+		return errors.New("урок не найден")
+	}
+	return nil
+}
+
+// loadAuthors загружает публичные данные авторов. Удалённых пользователей в результате нет
+func (s *DiscussionService) loadAuthors(ids []uint) (map[uint]*DiscussionAuthor, error) {
+	authors := make(map[uint]*DiscussionAuthor)
+	if len(ids) == 0 {
+		return authors, nil
+	}
+
+	var users []models.User
+	if err := s.db.Select("id", "name", "avatar", "level").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения авторов: %w", err)
+	}
+	for _, user := range users {
+		authors[user.ID] = &DiscussionAuthor{ID: user.ID, Name: user.Name, Avatar: user.Avatar, Level: user.Level}
+	}
+	return authors, nil
+}
+
+func threadView(thread models.DiscussionThread, authors map[uint]*DiscussionAuthor, spoilersOpen bool) DiscussionThreadView {
+	view := DiscussionThreadView{
+		DiscussionThread: thread,
+		Author:           authors[thread.AuthorID],
+	}
+	if thread.IsSpoiler && !spoilersOpen {
+		view.Body = ""
+		view.Hidden = true
+	}
+	return view
+}
+
+// Request structures
+
+type CreateThreadRequest struct {
+	Title     string `json:"title" binding:"required,min=3,max=200"`
+	Body      string `json:"body" binding:"required,max=20000"`
+	IsSpoiler bool   `json:"is_spoiler"`
+}
+
+type UpdateThreadRequest struct {
+	Title string `json:"title" binding:"omitempty,min=3,max=200"`
+	Body  string `json:"body" binding:"omitempty,max=20000"`
+}
+
+type CreatePostRequest struct {
+	Body     string `json:"body" binding:"required,max=20000"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+type UpdatePostRequest struct {
+	Body string `json:"body" binding:"required,max=20000"`
+}
+
+type VoteRequest struct {
+	Value *int `json:"value" binding:"required,oneof=-1 0 1"`
+}
+
+type AcceptAnswerRequest struct {
+	PostID *uint `json:"post_id"` // nil снимает отметку
+}
+
+type ModerateThreadRequest struct {
+	IsPinned  *bool `json:"is_pinned"`
+	IsLocked  *bool `json:"is_locked"`
+	IsSpoiler *bool `json:"is_spoiler"`
+}
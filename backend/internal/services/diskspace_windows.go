@@ -0,0 +1,30 @@
+//go:build windows
+
+package services
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// freeDiskSpace возвращает число байт, доступных текущему пользователю
+func freeDiskSpace(path string) (uint64, error) {
+	kernel32, err := syscall.LoadDLL("kernel32.dll")
+	if err != nil {
+		return 0, err
+	}
+	proc, err := kernel32.FindProc("GetDiskFreeSpaceExW")
+	if err != nil {
+		return 0, err
+	}
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var freeBytes uint64
+	if r, _, err := proc.Call(uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(&freeBytes)), 0, 0); r == 0 {
+		return 0, err
+	}
+	return freeBytes, nil
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Коды завершения интерактора по соглашению testlib
+const (
+	interactorExitOK = 0
+	interactorExitWA = 1
+	interactorExitPE = 2
+)
+
+// runInteractiveTest выполняет тест интерактивной задачи: stdout решения
+// подаётся на stdin интерактора, а stdout интерактора — на stdin решения.
+// Интерактор запускается как `interactor input.txt output.txt answer.txt`,
+// читает тест из input.txt и выносит вердикт кодом завершения. Файлы теста
+// передаются интерактору открытыми дескрипторами (/dev/fd/3, 4 и 5) и удаляются
+// с диска до запуска решения, так что решение не найдёт их по пути. Решение
+// ограничено временем теста, интерактор — временем теста плюс время по умолчанию
+func (s *SandboxService) runInteractiveTest(execDir string, tools judgeTools, testCase TestCase, timeout time.Duration) (*TestResult, error) {
+	if err := tools.verify(tools.interactorPath); err != nil {
+		return nil, err
+	}
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, content := range []string{testCase.Input, "", testCase.Expected} {
+		f, err := s.unlinkedFile(content)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	outputFile := files[1]
+
+	// Каналы решение -> интерактор и интерактор -> решение
+	fromSolution, toInteractor, err := os.Pipe()
+	if err != nil {
+		return nil, judgeSystemError("ошибка создания канала: %v", err)
+	}
+	fromInteractor, toSolution, err := os.Pipe()
+	if err != nil {
+		fromSolution.Close()
+		toInteractor.Close()
+		return nil, judgeSystemError("ошибка создания канала: %v", err)
+	}
+	pipes := []*os.File{fromSolution, toInteractor, fromInteractor, toSolution}
+	closePipes := func() {
+		for _, p := range pipes {
+			p.Close()
+		}
+	}
+
+	interactorCtx, cancelInteractor := context.WithTimeout(context.Background(), timeout+s.defaultTimeout)
+	defer cancelInteractor()
+
+	interactor := exec.CommandContext(interactorCtx, tools.interactorPath, "/dev/fd/3", "/dev/fd/4", "/dev/fd/5")
+	interactor.Dir = filepath.Dir(tools.interactorPath)
+	interactor.ExtraFiles = files
+	interactor.Stdin = fromSolution
+	interactor.Stdout = toSolution
+	var interactorStderr bytes.Buffer
+	interactor.Stderr = &interactorStderr
+
+	if err := interactor.Start(); err != nil {
+		closePipes()
+		return nil, judgeSystemError("ошибка запуска интерактора: %v", err)
+	}
+
+	solutionCtx, cancelSolution := context.WithTimeout(context.Background(), timeout)
+	defer cancelSolution()
+
+	solution := exec.CommandContext(solutionCtx, s.programPath(execDir))
+	solution.Dir = execDir
+	solution.Stdin = fromInteractor
+	solution.Stdout = toInteractor
+	var solutionStderr bytes.Buffer
+	solution.Stderr = &solutionStderr
+
+	start := time.Now()
+	if err := solution.Start(); err != nil {
+		closePipes()
+		cancelInteractor()
+		interactor.Wait()
+		return nil, judgeSystemError("не удалось запустить решение: %v", err)
+	}
+
+	// Концы каналов теперь принадлежат дочерним процессам: без закрытия в
+	// родителе ни один из них не увидит EOF после завершения другого
+	closePipes()
+
+	solutionErr := solution.Wait()
+	executionTime := int(time.Since(start).Milliseconds())
+	interactorErr := interactor.Wait()
+
+	if solutionCtx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("превышено время выполнения")
+	}
+	if interactorCtx.Err() == context.DeadlineExceeded {
+		return nil, judgeSystemError("превышено время работы интерактора")
+	}
+
+	var output []byte
+	if _, err := outputFile.Seek(0, io.SeekStart); err == nil {
+		output, _ = io.ReadAll(outputFile)
+	}
+	result := &TestResult{
+		ExecutionTime: executionTime,
+		Output:        strings.TrimSpace(string(output)),
+		ErrorOutput:   solutionStderr.String(),
+	}
+	message := strings.TrimSpace(interactorStderr.String())
+
+	exitCode := interactorExitOK
+	if interactorErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(interactorErr, &exitErr) {
+			return nil, judgeSystemError("ошибка выполнения интерактора: %v", interactorErr)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	// Вердикт интерактора о неверном ответе важнее падения решения: решение
+	// могло завершиться из-за того, что интерактор закрыл канал
+	if exitCode == interactorExitWA || exitCode == interactorExitPE {
+		if message != "" {
+			result.ErrorOutput = message
+		}
+		return result, nil
+	}
+	if solutionErr != nil {
+		return result, nil // Возвращаем результат с ошибкой, но не прерываем
+	}
+	if exitCode != interactorExitOK {
+		return nil, judgeSystemError("интерактор завершился с кодом %d: %s", exitCode, message)
+	}
+
+	// Протокол, записанный интерактором, дополнительно проверяется чекером
+	if tools.checkerPath != "" {
+		success, checkerMessage, err := s.runChecker(tools, testCase, string(output))
+		if err != nil {
+			return nil, err
+		}
+		result.Success = success
+		if !success && checkerMessage != "" {
+			result.ErrorOutput = checkerMessage
+		}
+		return result, nil
+	}
+
+	result.Success = true
+	return result, nil
+}
+
+// unlinkedFile создаёт временный файл с содержимым и сразу удаляет его с диска.
+// Открытый файл остаётся доступен через дескриптор до закрытия
+func (s *SandboxService) unlinkedFile(content string) (*os.File, error) {
+	f, err := os.CreateTemp(s.tempDir, "fd_*")
+	if err != nil {
+		return nil, judgeSystemError("ошибка создания файла интерактора: %v", err)
+	}
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, judgeSystemError("ошибка удаления файла интерактора: %v", err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return nil, judgeSystemError("ошибка записи файла интерактора: %v", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, judgeSystemError("ошибка записи файла интерактора: %v", err)
+	}
+	return f, nil
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// systemErrorMessage пояснение к вердикту system_error для студента.
+// Подробности сбоя получают администраторы в уведомлении
+const systemErrorMessage = "Внутренняя ошибка проверяющей системы. Решение не засчитано как ошибочное, " +
+	"администраторы уведомлены — отправьте его повторно позже"
+
+// judgeRetryDelays задержки перед повторными попытками проверки после сбоя системы
+var judgeRetryDelays = []time.Duration{5 * time.Second, 30 * time.Second}
+
+// judgeAlertInterval не чаще одного оповещения о сбоях проверки по одной задаче,
+// чтобы сломанный чекер или отсутствие компилятора не завалили администраторов уведомлениями
+const judgeAlertInterval = 15 * time.Minute
+
+// minFreeDiskBytes минимальный запас свободного места во временной директории sandbox
+const minFreeDiskBytes = 512 << 20
+
+// canaryProgram и canaryTest проверяют весь путь проверки: компиляцию, запуск и сравнение вывода
+const canaryProgram = `package main
+
+import "fmt"
+
+func main() {
+	var a, b int
+	fmt.Scan(&a, &b)
+	fmt.Println(a + b)
+}`
+
+var canaryTest = TestCase{Name: "canary", Input: "2 3", Expected: "5"}
+
+// alertJudgeFailure оповещает администраторов о сбое проверки отправки
+func (s *ProblemService) alertJudgeFailure(problemID, submissionID uint, cause error) {
+	log.Printf("Сбой проверки отправки %d по задаче %d: %v", submissionID, problemID, cause)
+
+	now := time.Now()
+	if last, ok := s.lastAlerts.Load(problemID); ok && now.Sub(last.(time.Time)) < judgeAlertInterval {
+		return
+	}
+	s.lastAlerts.Store(problemID, now)
+
+	admins, err := usersWithRole(s.db, models.UserRoleAdmin)
+	if err != nil {
+		log.Printf("Ошибка оповещения администраторов: %v", err)
+		return
+	}
+	message := fmt.Sprintf("Задача %d, отправка %d: %s", problemID, submissionID, truncateText(cause.Error(), 500))
+	if err := notify(s.db, admins, models.NotificationJudgeFailure,
+		"Сбой проверяющей системы", message, "submission", submissionID); err != nil {
+		log.Printf("Ошибка оповещения администраторов: %v", err)
+	}
+}
+
+type JudgeHealthService struct {
+	db      *gorm.DB
+	sandbox *SandboxService
+}
+
+func NewJudgeHealthService(db *gorm.DB, sandbox *SandboxService) *JudgeHealthService {
+	return &JudgeHealthService{db: db, sandbox: sandbox}
+}
+
+// JudgeHealth состояние проверяющей системы
+type JudgeHealth struct {
+	Healthy   bool               `json:"healthy"`
+	Checks    []JudgeHealthCheck `json:"checks"`
+	Queue     JudgeQueueStats    `json:"queue"`
+	CheckedAt time.Time          `json:"checked_at"`
+}
+
+// JudgeHealthCheck результат отдельной проверки
+type JudgeHealthCheck struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Message  string `json:"message"`
+	Duration int    `json:"duration_ms"`
+}
+
+// JudgeQueueStats состояние очереди проверки
+type JudgeQueueStats struct {
+	Pending              int64 `json:"pending"`
+	Running              int64 `json:"running"`
+	SystemErrorsLastHour int64 `json:"system_errors_last_hour"`
+}
+
+// Check проверяет наличие компилятора Go, свободное место во временной
+// директории sandbox и прогоняет эталонную программу через весь путь проверки
+func (s *JudgeHealthService) Check() (*JudgeHealth, error) {
+	health := &JudgeHealth{Healthy: true, CheckedAt: time.Now()}
+
+	for _, check := range []struct {
+		name string
+		run  func() (string, error)
+	}{
+		{"toolchain", s.checkToolchain},
+		{"disk", s.checkDisk},
+		{"canary", s.checkCanary},
+	} {
+		start := time.Now()
+		message, err := check.run()
+		result := JudgeHealthCheck{
+			Name:     check.name,
+			OK:       err == nil,
+			Message:  message,
+			Duration: int(time.Since(start).Milliseconds()),
+		}
+		if err != nil {
+			result.Message = err.Error()
+			health.Healthy = false
+		}
+		health.Checks = append(health.Checks, result)
+	}
+
+	counts := []struct {
+		target *int64
+		query  *gorm.DB
+	}{
+		{&health.Queue.Pending, s.db.Model(&models.UserSubmission{}).Where("status = ?", models.SubmissionStatusPending)},
+		{&health.Queue.Running, s.db.Model(&models.UserSubmission{}).Where("status = ?", models.SubmissionStatusRunning)},
+		{&health.Queue.SystemErrorsLastHour, s.db.Model(&models.UserSubmission{}).
+			Where("status = ? AND updated_at >= ?", models.SubmissionStatusSystemError, time.Now().Add(-time.Hour))},
+	}
+	for _, count := range counts {
+		if err := count.query.Count(count.target).Error; err != nil {
+			return nil, fmt.Errorf("ошибка получения состояния очереди: %w", err)
+		}
+	}
+
+	return health, nil
+}
+
+func (s *JudgeHealthService) checkToolchain() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.sandbox.defaultTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "go", "version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("компилятор Go недоступен: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (s *JudgeHealthService) checkDisk() (string, error) {
+	free, err := freeDiskSpace(s.sandbox.tempDir)
+	if err != nil {
+		return "", fmt.Errorf("не удалось определить свободное место: %v", err)
+	}
+	message := fmt.Sprintf("свободно %d МБ в %s", free>>20, s.sandbox.tempDir)
+	if free < minFreeDiskBytes {
+		return "", fmt.Errorf("мало свободного места: %s, требуется не меньше %d МБ", message, minFreeDiskBytes>>20)
+	}
+	return message, nil
+}
+
+func (s *JudgeHealthService) checkCanary() (string, error) {
+	result, err := s.sandbox.ExecuteTests(canaryProgram, []TestCase{canaryTest}, ExecutionOptions{TimeLimit: 5})
+	if err != nil {
+		return "", fmt.Errorf("эталонная программа не проверена: %v", err)
+	}
+	if result.Status != models.SubmissionStatusAccepted {
+		return "", fmt.Errorf("эталонная программа получила вердикт %s: %s", result.Status, truncateText(result.ErrorOutput, 300))
+	}
+	return fmt.Sprintf("вердикт accepted за %d мс", result.ExecutionTime), nil
+}
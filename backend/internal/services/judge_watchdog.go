@@ -0,0 +1,162 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go-education-platform/internal/config"
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// watchdogBatchSize ограничивает число отправок, повторно запускаемых за один проход,
+// чтобы после перезапуска сервера не запустить сразу всю накопившуюся очередь
+const watchdogBatchSize = 20
+
+// JudgeWatchdog находит отправки, зависшие в pending/running после падения
+// сервера или сбоя проверки, и запускает их проверку заново. После MaxAttempts
+// запусков отправка получает вердикт system_error. Заодно удаляются брошенные
+// временные директории sandbox
+type JudgeWatchdog struct {
+	db          *gorm.DB
+	problems    *ProblemService
+	sandbox     *SandboxService
+	stuckAfter  time.Duration
+	interval    time.Duration
+	maxAttempts int
+	startedAt   time.Time
+}
+
+func NewJudgeWatchdog(db *gorm.DB, problems *ProblemService, sandbox *SandboxService, cfg config.JudgeConfig) *JudgeWatchdog {
+	stuckAfter, err := time.ParseDuration(cfg.StuckAfter)
+	if err != nil || stuckAfter <= 0 {
+		stuckAfter = 10 * time.Minute
+	}
+	interval, err := time.ParseDuration(cfg.WatchdogInterval)
+	if err != nil || interval <= 0 {
+		interval = time.Minute
+	}
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 3
+	}
+
+	return &JudgeWatchdog{
+		db:          db,
+		problems:    problems,
+		sandbox:     sandbox,
+		stuckAfter:  stuckAfter,
+		interval:    interval,
+		maxAttempts: maxAttempts,
+		startedAt:   time.Now(),
+	}
+}
+
+// Run периодически проверяет очередь. Первый проход выполняется сразу после
+// запуска и подбирает отправки, брошенные предыдущим процессом
+func (w *JudgeWatchdog) Run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		requeued, failed, err := w.RecoverStuckSubmissions()
+		if err != nil {
+			log.Printf("Ошибка восстановления зависших проверок: %v", err)
+		} else if requeued > 0 || failed > 0 {
+			log.Printf("Зависшие проверки: перезапущено %d, system_error %d", requeued, failed)
+		}
+
+		if removed, err := w.sandbox.CleanOrphanedExecDirs(w.stuckAfter); err != nil {
+			log.Printf("Ошибка очистки временных директорий sandbox: %v", err)
+		} else if removed > 0 {
+			log.Printf("Удалено брошенных директорий sandbox: %d", removed)
+		}
+
+		<-ticker.C
+	}
+}
+
+// RecoverStuckSubmissions перезапускает или завершает зависшие проверки.
+// Зависшей считается отправка в pending/running, которая не обновлялась дольше
+// stuckAfter или не обновлялась с момента запуска процесса: проверки прошлого
+// процесса прерваны его завершением. Отправки, которые проверяются сейчас, не трогаются
+func (w *JudgeWatchdog) RecoverStuckSubmissions() (requeued, failed int, err error) {
+	cutoff := time.Now().Add(-w.stuckAfter)
+	if w.startedAt.After(cutoff) {
+		cutoff = w.startedAt
+	}
+
+	var submissions []models.UserSubmission
+	if err := w.db.Where("status IN ? AND updated_at < ?",
+		[]models.SubmissionStatus{models.SubmissionStatusPending, models.SubmissionStatusRunning}, cutoff).
+		Order("id ASC").
+		Limit(watchdogBatchSize).
+		Find(&submissions).Error; err != nil {
+		return 0, 0, fmt.Errorf("ошибка поиска зависших отправок: %w", err)
+	}
+
+	for i := range submissions {
+		submission := &submissions[i]
+		if _, judging := w.problems.inFlight.Load(submission.ID); judging {
+			continue
+		}
+
+		if submission.JudgeAttempts >= w.maxAttempts {
+			failedNow, err := w.failSubmission(submission)
+			if err != nil {
+				return requeued, failed, err
+			}
+			if failedNow {
+				failed++
+			}
+			continue
+		}
+
+		var problem models.Problem
+		if err := w.db.First(&problem, submission.ProblemID).Error; err != nil {
+			// Задача удалена: проверить отправку уже невозможно
+			failedNow, err := w.failSubmission(submission)
+			if err != nil {
+				return requeued, failed, err
+			}
+			if failedNow {
+				failed++
+			}
+			continue
+		}
+
+		// Отмечаем отправку, чтобы следующий проход не запустил её повторно
+		// до того, как проверка обновит статус
+		if err := w.db.Model(submission).Update("status", models.SubmissionStatusPending).Error; err != nil {
+			return requeued, failed, fmt.Errorf("ошибка обновления отправки: %w", err)
+		}
+		go w.problems.JudgeSubmission(submission, &problem)
+		requeued++
+	}
+
+	return requeued, failed, nil
+}
+
+// failSubmission завершает отправку вердиктом system_error. Вердикт сохраняется
+// условным обновлением, чтобы не затереть результат проверки, завершившейся тем временем.
+// Возвращает false, если отправка уже завершена: тогда оповещение не отправляется
+func (w *JudgeWatchdog) failSubmission(submission *models.UserSubmission) (bool, error) {
+	result := w.db.Model(&models.UserSubmission{}).
+		Where("id = ? AND status IN ?", submission.ID,
+			[]models.SubmissionStatus{models.SubmissionStatusPending, models.SubmissionStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":       models.SubmissionStatusSystemError,
+			"error_output": systemErrorMessage,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("ошибка обновления отправки: %w", result.Error)
+	}
+	if result.RowsAffected != 1 {
+		return false, nil
+	}
+	w.problems.alertJudgeFailure(submission.ProblemID, submission.ID,
+		fmt.Errorf("проверка не завершилась после %d запусков", submission.JudgeAttempts))
+	return true, nil
+}
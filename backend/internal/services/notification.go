@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type NotificationService struct {
+	db *gorm.DB
+}
+
+func NewNotificationService(db *gorm.DB) *NotificationService {
+	return &NotificationService{db: db}
+}
+
+// GetNotifications получает уведомления пользователя, начиная с новых
+func (s *NotificationService) GetNotifications(userID uint, unreadOnly bool, page, limit int) ([]*models.Notification, int64, int64, error) {
+	var notifications []*models.Notification
+	var total, unread int64
+
+	query := s.db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("is_read = ?", false)
+	}
+	query.Count(&total)
+
+	if err := s.db.Model(&models.Notification{}).
+		Where("user_id = ? AND is_read = ?", userID, false).
+		Count(&unread).Error; err != nil {
+		return nil, 0, 0, fmt.Errorf("ошибка подсчёта уведомлений: %w", err)
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&notifications).Error; err != nil {
+		return nil, 0, 0, fmt.Errorf("ошибка получения уведомлений: %w", err)
+	}
+
+	return notifications, total, unread, nil
+}
+
+// MarkRead отмечает уведомление пользователя прочитанным
+func (s *NotificationService) MarkRead(userID, id uint) error {
+	result := s.db.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("is_read", true)
+	if result.Error != nil {
+		return fmt.Errorf("ошибка обновления уведомления: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("уведомление не найдено")
+	}
+	return nil
+}
+
+// MarkAllRead отмечает все уведомления пользователя прочитанными
+func (s *NotificationService) MarkAllRead(userID uint) error {
+	if err := s.db.Model(&models.Notification{}).
+		Where("user_id = ? AND is_read = ?", userID, false).
+		Update("is_read", true).Error; err != nil {
+		return fmt.Errorf("ошибка обновления уведомлений: %w", err)
+	}
+	return nil
+}
+
+// notify создаёт уведомление для каждого из пользователей
+func notify(db *gorm.DB, userIDs []uint, notificationType, title, message, entityType string, entityID uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	notifications := make([]models.Notification, 0, len(userIDs))
+	for _, userID := range userIDs {
+		notifications = append(notifications, models.Notification{
+			UserID:     userID,
+			Type:       notificationType,
+			Title:      title,
+			Message:    message,
+			EntityType: entityType,
+			EntityID:   entityID,
+		})
+	}
+	if err := db.Create(&notifications).Error; err != nil {
+		return fmt.Errorf("ошибка создания уведомлений: %w", err)
+	}
+	return nil
+}
+
+// usersWithRole возвращает ID пользователей с указанной ролью
+func usersWithRole(db *gorm.DB, role models.UserRole) ([]uint, error) {
+	var ids []uint
+	if err := db.Model(&models.User{}).Where("role = ?", role).Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения пользователей: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,485 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Параметры адаптивного вступительного теста. Тест завершается, когда
+// погрешность оценки опускается ниже placementTargetError, но не раньше
+// placementMinQuestions вопросов и не позже placementMaxQuestions. Ответ
+// на вопрос даёт не больше 0.25 информации, поэтому погрешность после n
+// вопросов не меньше 1/sqrt(1+n/4): оценка точнее 0.5 возможна с 13 вопросов
+const (
+	placementMinQuestions = 6
+	placementMaxQuestions = 20
+	placementTargetError  = 0.5
+
+	// Сложность вопроса уточняется по ответам завершённых попыток. Начальная
+	// сложность по уровню весит как placementCalibrationPrior ответов
+	placementCalibrationPrior = 10
+	placementMaxDifficulty    = 3
+
+	// Границы способности для уровней: ниже middle — junior, от senior — senior
+	placementMiddleAbility = -0.5
+	placementSeniorAbility = 0.75
+)
+
+// placementDifficulty начальная сложность вопроса по шкале способности. Вопросы
+// без сложности считаются средними
+var placementDifficulty = map[models.ProblemLevel]float64{
+	models.ProblemLevelEasy:   -1,
+	models.ProblemLevelMedium: 0,
+	models.ProblemLevelHard:   1,
+}
+
+// ErrPlacementCompleted возвращается при попытке пройти вступительный тест повторно
+var ErrPlacementCompleted = errors.New("вступительный тест уже пройден")
+
+var errPlacementNotStarted = errors.New("вступительный тест не начат")
+
+type PlacementService struct {
+	db    *gorm.DB
+	tests *TestService
+}
+
+func NewPlacementService(db *gorm.DB, tests *TestService) *PlacementService {
+	return &PlacementService{db: db, tests: tests}
+}
+
+// placementResponse ответ на вопрос вступительного теста, хранится в PlacementAttempt.Answers
+type placementResponse struct {
+	QuestionID uint    `json:"question_id"`
+	Difficulty float64 `json:"difficulty"`
+	Fraction   float64 `json:"fraction"` // доля балла за ответ
+}
+
+// PlacementView состояние вступительного теста. Пока тест идёт, в Question
+// текущий вопрос; после завершения — оценка уровня
+type PlacementView struct {
+	ID            uint              `json:"id"`
+	Answered      int               `json:"answered"`
+	MaxQuestions  int               `json:"max_questions"`
+	Question      *TestQuestionView `json:"question,omitempty"`
+	Completed     bool              `json:"completed"`
+	Ability       *float64          `json:"ability,omitempty"`
+	StandardError *float64          `json:"standard_error,omitempty"`
+	Level         models.UserLevel  `json:"level,omitempty"`
+	Applied       bool              `json:"applied"`
+	StartedAt     time.Time         `json:"started_at"`
+	CompletedAt   *time.Time        `json:"completed_at"`
+}
+
+// GetPlacement возвращает состояние вступительного теста пользователя
+func (s *PlacementService) GetPlacement(userID uint) (*PlacementView, error) {
+	attempt, err := s.findAttempt(userID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == nil {
+		return nil, errPlacementNotStarted
+	}
+	return s.newPlacementView(attempt)
+}
+
+// StartPlacement начинает вступительный тест или продолжает начатый
+func (s *PlacementService) StartPlacement(userID uint) (*PlacementView, error) {
+	attempt, err := s.findAttempt(userID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt != nil {
+		if attempt.CompletedAt != nil {
+			return nil, ErrPlacementCompleted
+		}
+		return s.newPlacementView(attempt)
+	}
+
+	questionID, err := s.nextQuestion(0, nil)
+	if err != nil {
+		return nil, err
+	}
+	if questionID == nil {
+		return nil, errors.New("вступительный тест недоступен: нет вопросов")
+	}
+
+	attempt = &models.PlacementAttempt{
+		UserID:            userID,
+		StandardError:     1,
+		Answers:           "[]",
+		CurrentQuestionID: questionID,
+		StartedAt:         time.Now(),
+	}
+	// Параллельный запрос мог уже начать тест: берём созданную им попытку
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(attempt)
+	if result.Error != nil {
+		return nil, fmt.Errorf("ошибка начала вступительного теста: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return s.StartPlacement(userID)
+	}
+	return s.newPlacementView(attempt)
+}
+
+// AnswerPlacement проверяет ответ на текущий вопрос, уточняет оценку
+// способности и выбирает следующий вопрос или завершает тест
+func (s *PlacementService) AnswerPlacement(userID uint, req SubmittedTestAnswer) (*PlacementView, error) {
+	attempt, err := s.findAttempt(userID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == nil {
+		return nil, errPlacementNotStarted
+	}
+	if attempt.CompletedAt != nil {
+		return nil, ErrPlacementCompleted
+	}
+	question, err := s.currentQuestion(attempt)
+	if err != nil {
+		return nil, err
+	}
+	if question == nil {
+		return nil, ErrPlacementCompleted
+	}
+	if question.ID != req.QuestionID {
+		return nil, errors.New("ответ не на текущий вопрос")
+	}
+
+	req = resolveOptionIDs(question, req, int64(attempt.ID))
+	fraction, _, err := s.tests.gradeQuestion(question, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []placementResponse
+	_ = json.Unmarshal([]byte(attempt.Answers), &responses)
+	responses = append(responses, placementResponse{
+		QuestionID: question.ID,
+		Difficulty: questionDifficulty(question.Difficulty, question.PlacementDifficulty),
+		Fraction:   fraction,
+	})
+	ability, standardError := estimateAbility(responses)
+
+	var next *uint
+	done := len(responses) >= placementMaxQuestions ||
+		(len(responses) >= placementMinQuestions && standardError < placementTargetError)
+	if !done {
+		if next, err = s.nextQuestion(ability, responses); err != nil {
+			return nil, err
+		}
+		done = next == nil
+	}
+
+	answersJSON, err := json.Marshal(responses)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сохранения ответа: %w", err)
+	}
+	updates := map[string]interface{}{
+		"ability":             ability,
+		"standard_error":      standardError,
+		"answers":             string(answersJSON),
+		"current_question_id": next,
+	}
+	if done {
+		updates["level"] = placementLevel(ability)
+		updates["completed_at"] = time.Now()
+	}
+
+	// Условие на текущий вопрос не даёт засчитать один ответ дважды
+	result := s.db.Model(&models.PlacementAttempt{}).
+		Where("id = ? AND current_question_id = ? AND completed_at IS NULL", attempt.ID, question.ID).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("ошибка сохранения ответа: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("ответ на этот вопрос уже принят")
+	}
+	if done {
+		s.calibrateQuestions(ability, responses)
+	}
+
+	if err := s.db.First(attempt, attempt.ID).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения вступительного теста: %w", err)
+	}
+	return s.newPlacementView(attempt)
+}
+
+// ApplyPlacement устанавливает уровень пользователя по результату
+// вступительного теста и тем открывает курсы этого уровня. Уровень не
+// понижается, результат применяется один раз
+func (s *PlacementService) ApplyPlacement(userID uint) (*PlacementView, error) {
+	attempt, err := s.findAttempt(userID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == nil || attempt.CompletedAt == nil {
+		return nil, errors.New("вступительный тест не завершён")
+	}
+	if attempt.Applied {
+		return nil, errors.New("результат вступительного теста уже применён")
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PlacementAttempt{}).
+			Where("id = ? AND applied = ?", attempt.ID, false).
+			Update("applied", true)
+		if result.Error != nil {
+			return fmt.Errorf("ошибка применения результата: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("результат вступительного теста уже применён")
+		}
+
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return errors.New("пользователь не найден")
+		}
+		if attempt.Level.Rank() <= user.Level.Rank() {
+			return nil
+		}
+		if err := tx.Model(&user).Update("level", attempt.Level).Error; err != nil {
+			return fmt.Errorf("ошибка обновления уровня: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attempt.Applied = true
+	return s.newPlacementView(attempt)
+}
+
+func (s *PlacementService) findAttempt(userID uint) (*models.PlacementAttempt, error) {
+	var attempt models.PlacementAttempt
+	err := s.db.Where("user_id = ?", userID).First(&attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения вступительного теста: %w", err)
+	}
+	return &attempt, nil
+}
+
+// nextQuestion выбирает ещё не заданный вопрос, сложность которого ближе всего
+// к текущей оценке способности: по модели Раша он даёт больше всего информации.
+// Среди равных по сложности вопрос выбирается случайно. nil — вопросы кончились
+func (s *PlacementService) nextQuestion(ability float64, responses []placementResponse) (*uint, error) {
+	asked := make([]uint, 0, len(responses)+1)
+	for _, response := range responses {
+		asked = append(asked, response.QuestionID)
+	}
+
+	var candidates []struct {
+		ID                  uint
+		Difficulty          models.ProblemLevel
+		PlacementDifficulty *float64
+	}
+	// Код проверяется в sandbox долго, такие вопросы во вступительный тест не берутся
+	query := s.db.Model(&models.TestQuestion{}).
+		Select("id", "difficulty", "placement_difficulty").
+		Where("bank_id IN (?)", s.db.Model(&models.QuestionBank{}).Select("id").Where("is_placement = ?", true)).
+		Where("type <> ?", models.QuestionTypeCode)
+	if len(asked) > 0 {
+		query = query.Where("id NOT IN ?", asked)
+	}
+	if err := query.Scan(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("ошибка выбора вопроса: %w", err)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	best := math.Inf(1)
+	var closest []uint
+	for _, candidate := range candidates {
+		distance := math.Abs(questionDifficulty(candidate.Difficulty, candidate.PlacementDifficulty) - ability)
+		switch {
+		case distance < best:
+			best = distance
+			closest = []uint{candidate.ID}
+		case distance == best:
+			closest = append(closest, candidate.ID)
+		}
+	}
+	id := closest[rand.Intn(len(closest))]
+	return &id, nil
+}
+
+func (s *PlacementService) newPlacementView(attempt *models.PlacementAttempt) (*PlacementView, error) {
+	var question *models.TestQuestion
+	if attempt.CompletedAt == nil {
+		var err error
+		if question, err = s.currentQuestion(attempt); err != nil {
+			return nil, err
+		}
+	}
+
+	var responses []placementResponse
+	_ = json.Unmarshal([]byte(attempt.Answers), &responses)
+
+	view := &PlacementView{
+		ID:           attempt.ID,
+		Answered:     len(responses),
+		MaxQuestions: placementMaxQuestions,
+		Completed:    attempt.CompletedAt != nil,
+		Applied:      attempt.Applied,
+		StartedAt:    attempt.StartedAt,
+		CompletedAt:  attempt.CompletedAt,
+	}
+	if view.Completed {
+		view.Ability = &attempt.Ability
+		view.StandardError = &attempt.StandardError
+		view.Level = attempt.Level
+		return view, nil
+	}
+
+	if question != nil {
+		views := newTestQuestionViews([]models.TestQuestion{*question}, int64(attempt.ID), true)
+		view.Question = &views[0]
+	}
+	return view, nil
+}
+
+// currentQuestion загружает текущий вопрос незавершённой попытки. Если вопрос
+// удалили из банка, выбирается и сохраняется новый; если вопросов не осталось,
+// тест завершается по уже данным ответам и возвращается nil
+func (s *PlacementService) currentQuestion(attempt *models.PlacementAttempt) (*models.TestQuestion, error) {
+	if attempt.CurrentQuestionID != nil {
+		question, err := s.loadQuestion(*attempt.CurrentQuestionID)
+		if err == nil {
+			return question, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("ошибка получения вопроса: %w", err)
+		}
+	}
+
+	var responses []placementResponse
+	_ = json.Unmarshal([]byte(attempt.Answers), &responses)
+	next, err := s.nextQuestion(attempt.Ability, responses)
+	if err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{"current_question_id": next}
+	if next == nil {
+		updates["level"] = placementLevel(attempt.Ability)
+		updates["completed_at"] = time.Now()
+	}
+	if err := s.db.Model(&models.PlacementAttempt{}).
+		Where("id = ? AND completed_at IS NULL", attempt.ID).
+		Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("ошибка замены вопроса: %w", err)
+	}
+
+	// Параллельный запрос мог выбрать другой вопрос или завершить тест
+	if err := s.db.First(attempt, attempt.ID).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения вступительного теста: %w", err)
+	}
+	if attempt.CompletedAt != nil || attempt.CurrentQuestionID == nil {
+		return nil, nil
+	}
+	question, err := s.loadQuestion(*attempt.CurrentQuestionID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения вопроса: %w", err)
+	}
+	return question, nil
+}
+
+// loadQuestion загружает вопрос с вариантами ответа в порядке, заданном автором
+func (s *PlacementService) loadQuestion(id uint) (*models.TestQuestion, error) {
+	var question models.TestQuestion
+	if err := s.db.Preload("Answers", func(db *gorm.DB) *gorm.DB {
+		return db.Order(`"order" ASC, id ASC`)
+	}).
+		First(&question, id).Error; err != nil {
+		return nil, err
+	}
+	return &question, nil
+}
+
+// estimateAbility оценивает способность по модели Раша методом EAP:
+// апостериорное среднее и стандартное отклонение на сетке при нормальном
+// априорном распределении
+func estimateAbility(responses []placementResponse) (float64, float64) {
+	sum, mean := 0.0, 0.0
+	weights := make([]float64, 0, 81)
+	for i := 0; i <= 80; i++ {
+		theta := -4 + float64(i)*0.1
+		logWeight := -theta * theta / 2
+		for _, response := range responses {
+			p := 1 / (1 + math.Exp(response.Difficulty-theta))
+			logWeight += response.Fraction*math.Log(p) + (1-response.Fraction)*math.Log(1-p)
+		}
+		weight := math.Exp(logWeight)
+		weights = append(weights, weight)
+		sum += weight
+		mean += theta * weight
+	}
+	mean /= sum
+
+	variance := 0.0
+	for i, weight := range weights {
+		theta := -4 + float64(i)*0.1
+		variance += (theta - mean) * (theta - mean) * weight
+	}
+	return roundTo(mean, 3), roundTo(math.Sqrt(variance/sum), 3)
+}
+
+// placementLevel переводит оценку способности в уровень пользователя
+func placementLevel(ability float64) models.UserLevel {
+	switch {
+	case ability >= placementSeniorAbility:
+		return models.UserLevelSenior
+	case ability >= placementMiddleAbility:
+		return models.UserLevelMiddle
+	default:
+		return models.UserLevelJunior
+	}
+}
+
+// questionDifficulty сложность вопроса: уточнённая по ответам, если есть, иначе по уровню
+func questionDifficulty(level models.ProblemLevel, calibrated *float64) float64 {
+	if calibrated != nil {
+		return *calibrated
+	}
+	return placementDifficulty[level]
+}
+
+// calibrateQuestions уточняет сложность вопросов завершённой попытки по модели
+// Раша: шаг в сторону ошибки предсказания (вероятность верного ответа при
+// итоговой способности минус полученная доля балла), уменьшающийся с числом
+// учтённых ответов. Так сложности со временем расходятся из трёх начальных
+// значений. Обновление одним запросом, чтобы параллельные попытки не
+// затирали шаги друг друга. Ошибка не мешает завершению теста
+func (s *PlacementService) calibrateQuestions(ability float64, responses []placementResponse) {
+	for _, response := range responses {
+		var question models.TestQuestion
+		if err := s.db.Select("id", "difficulty").First(&question, response.QuestionID).Error; err != nil {
+			continue
+		}
+		current := fmt.Sprintf("COALESCE(placement_difficulty, %v)", placementDifficulty[question.Difficulty])
+		step := fmt.Sprintf("4.0 * (1.0 / (1.0 + EXP(%s - ?)) - ?) / (placement_answers + %d)", current, placementCalibrationPrior)
+		if err := s.db.Model(&models.TestQuestion{}).
+			Where("id = ?", question.ID).
+			Updates(map[string]interface{}{
+				"placement_difficulty": gorm.Expr(fmt.Sprintf("LEAST(%d, GREATEST(-%d, %s + %s))",
+					placementMaxDifficulty, placementMaxDifficulty, current, step), ability, response.Fraction),
+				"placement_answers": gorm.Expr("placement_answers + 1"),
+			}).Error; err != nil {
+			log.Printf("Ошибка уточнения сложности вопроса %d: %v", question.ID, err)
+		}
+	}
+}
@@ -1,8 +1,15 @@
 package services
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
 
 	"go-education-platform/internal/models"
 
@@ -10,11 +17,15 @@ import (
 )
 
 type ProblemService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	sandbox  *SandboxService
+	inFlight sync.Map // ID отправок, которые сейчас проверяются этим процессом
+	// Время последнего оповещения администраторов о сбое проверки по задаче
+	lastAlerts sync.Map
 }
 
-func NewProblemService(db *gorm.DB) *ProblemService {
-	return &ProblemService{db: db}
+func NewProblemService(db *gorm.DB, sandbox *SandboxService) *ProblemService {
+	return &ProblemService{db: db, sandbox: sandbox}
 }
 
 // GetProblems получает список задач с фильтрацией
@@ -54,12 +65,15 @@ func (s *ProblemService) GetProblemByID(id uint) (*models.Problem, error) {
 	return &problem, nil
 }
 
-// CreateSubmission создает новую отправку решения
-func (s *ProblemService) CreateSubmission(userID, problemID uint, code string) (*models.UserSubmission, error) {
+// CreateSubmission создает новую отправку решения. contestID задаётся для
+// отправок в рамках соревнования
+func (s *ProblemService) CreateSubmission(userID, problemID uint, contestID *uint, code string) (*models.UserSubmission, error) {
 	submission := &models.UserSubmission{
 		UserID:    userID,
 		ProblemID: problemID,
+		ContestID: contestID,
 		Code:      code,
+		CodeHash:  hashSubmissionCode(code),
 		Language:  "go",
 		Status:    models.SubmissionStatusPending,
 	}
@@ -71,6 +85,49 @@ func (s *ProblemService) CreateSubmission(userID, problemID uint, code string) (
 	return submission, nil
 }
 
+// FindDuplicateSubmission ищет отправку пользователем того же кода по задаче
+// не раньше window назад в том же соревновании (или вне соревнований при
+// contestID == nil). Отправки с вердиктом system_error не учитываются, их
+// можно отправить повторно. Возвращает nil, если повтора нет
+func (s *ProblemService) FindDuplicateSubmission(userID, problemID uint, contestID *uint, code string, window time.Duration) (*models.UserSubmission, error) {
+	if window <= 0 {
+		return nil, nil
+	}
+
+	query := s.db.Where("user_id = ? AND problem_id = ? AND code_hash = ? AND created_at >= ? AND status <> ?",
+		userID, problemID, hashSubmissionCode(code), time.Now().Add(-window), models.SubmissionStatusSystemError)
+	if contestID != nil {
+		query = query.Where("contest_id = ?", *contestID)
+	} else {
+		query = query.Where("contest_id IS NULL")
+	}
+
+	var submission models.UserSubmission
+	err := query.Order("created_at DESC").
+		First(&submission).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ошибка поиска повторной отправки: %w", err)
+	}
+
+	return &submission, nil
+}
+
+// hashSubmissionCode хеширует код без учёта концевых пробелов и переводов строк,
+// чтобы незначимые правки не обходили поиск повторов
+func hashSubmissionCode(code string) string {
+	lines := strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	normalized := strings.TrimRight(strings.Join(lines, "\n"), "\n")
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
 // GetSubmissionByID получает отправку по ID
 func (s *ProblemService) GetSubmissionByID(id uint) (*models.UserSubmission, error) {
 	var submission models.UserSubmission
@@ -112,6 +169,55 @@ func (s *ProblemService) GetUserSubmissions(userID uint, page, limit int) ([]*mo
 	return submissions, total, nil
 }
 
+// JudgeSubmission проверяет отправку в sandbox и сохраняет результат.
+// Вызывается в отдельной горутине после создания отправки, а также сторожем
+// зависших проверок при повторной постановке в очередь
+func (s *ProblemService) JudgeSubmission(submission *models.UserSubmission, problem *models.Problem) {
+	s.inFlight.Store(submission.ID, struct{}{})
+	defer s.inFlight.Delete(submission.ID)
+
+	if err := s.db.Model(&models.UserSubmission{}).
+		Where("id = ?", submission.ID).
+		Updates(map[string]interface{}{
+			"status":         models.SubmissionStatusRunning,
+			"judge_attempts": gorm.Expr("judge_attempts + 1"),
+		}).Error; err != nil {
+		log.Printf("Ошибка обновления статуса отправки: %v", err)
+	}
+
+	execResult, err := s.sandbox.ExecuteSubmission(submission, problem)
+	// Сбой проверяющей системы может быть временным: повторяем с задержкой.
+	// Прочие ошибки, например неверные тесты задачи, повтор не исправит
+	for attempt := 0; errors.Is(err, errJudgeSystem) && attempt < len(judgeRetryDelays); attempt++ {
+		log.Printf("Сбой проверки отправки %d, повтор через %s: %v", submission.ID, judgeRetryDelays[attempt], err)
+		time.Sleep(judgeRetryDelays[attempt])
+		execResult, err = s.sandbox.ExecuteSubmission(submission, problem)
+	}
+	if err != nil {
+		// Решение не виновато в сбое: отмечаем отправку и оповещаем администраторов
+		s.UpdateSubmissionResult(submission.ID, &SubmissionResult{
+			Status:      models.SubmissionStatusSystemError,
+			ErrorOutput: systemErrorMessage,
+		})
+		s.alertJudgeFailure(problem.ID, submission.ID, err)
+		return
+	}
+
+	// Обновляем результат
+	result := &SubmissionResult{
+		Status:        execResult.Status,
+		Score:         execResult.Score,
+		TestsPassed:   execResult.TestsPassed,
+		TestsTotal:    execResult.TestsTotal,
+		ExecutionTime: execResult.ExecutionTime,
+		MemoryUsed:    execResult.MemoryUsed,
+		ErrorOutput:   execResult.ErrorOutput,
+		Groups:        execResult.Groups,
+	}
+
+	s.UpdateSubmissionResult(submission.ID, result)
+}
+
 // UpdateSubmissionResult обновляет результат выполнения отправки
 func (s *ProblemService) UpdateSubmissionResult(submissionID uint, result *SubmissionResult) error {
 	updates := map[string]interface{}{
@@ -123,6 +229,13 @@ func (s *ProblemService) UpdateSubmissionResult(submissionID uint, result *Submi
 		"memory_used":    result.MemoryUsed,
 		"error_output":   result.ErrorOutput,
 	}
+	if len(result.Groups) > 0 {
+		groupResults, err := json.Marshal(result.Groups)
+		if err != nil {
+			return fmt.Errorf("ошибка сериализации результатов групп: %w", err)
+		}
+		updates["group_results"] = string(groupResults)
+	}
 
 	if err := s.db.Model(&models.UserSubmission{}).
 		Where("id = ?", submissionID).
@@ -130,18 +243,31 @@ func (s *ProblemService) UpdateSubmissionResult(submissionID uint, result *Submi
 		return fmt.Errorf("ошибка обновления результата отправки: %w", err)
 	}
 
-	// Если задача решена успешно, начисляем баллы пользователю
-	if result.Status == models.SubmissionStatusAccepted {
+	// Начисляем баллы за решение или за прогресс по подзадачам
+	if result.Status == models.SubmissionStatusAccepted || len(result.Groups) > 0 {
 		if err := s.updateUserPoints(submissionID); err != nil {
 			// Логируем ошибку, но не прерываем выполнение
 			fmt.Printf("Ошибка начисления баллов: %v\n", err)
 		}
 	}
 
+	if result.Status == models.SubmissionStatusAccepted {
+		// Бонус за решение задачи дня или недели
+		if err := s.awardChallengeBonus(submissionID); err != nil {
+			fmt.Printf("Ошибка начисления бонуса за задачу-вызов: %v\n", err)
+		}
+		// Принятая исправленная отправка возвращает ревью на проверку
+		if err := s.advanceReview(submissionID); err != nil {
+			fmt.Printf("Ошибка обновления ревью: %v\n", err)
+		}
+	}
+
 	return nil
 }
 
-// updateUserPoints начисляет баллы пользователю за решение задачи
+// updateUserPoints начисляет баллы пользователю за решение задачи.
+// Баллы пропорциональны лучшему результату пользователя по задаче: за каждую
+// отправку начисляется только разница с уже полученными баллами
 func (s *ProblemService) updateUserPoints(submissionID uint) error {
 	var submission models.UserSubmission
 	if err := s.db.Preload("Problem").
@@ -150,39 +276,145 @@ func (s *ProblemService) updateUserPoints(submissionID uint) error {
 		return err
 	}
 
-	// Проверяем, первое ли это успешное решение пользователем этой задачи
-	var count int64
-	s.db.Model(&models.UserSubmission{}).
-		Where("user_id = ? AND problem_id = ? AND status = ? AND id < ?",
-			submission.UserID, submission.ProblemID, models.SubmissionStatusAccepted, submissionID).
-		Count(&count)
+	// Лучший результат пользователя по задаче. Для задач без подзадач
+	// частичный результат не учитывается: баллы только за полное решение
+	bestScore, err := s.bestScore(submission.UserID, submission.Problem)
+	if err != nil {
+		return err
+	}
 
-	// Начисляем баллы только за первое успешное решение
-	if count == 0 {
-		if err := s.db.Model(&models.User{}).
-			Where("id = ?", submission.UserID).
-			Update("points", gorm.Expr("points + ?", submission.Problem.Points)).Error; err != nil {
+	// Вычитаем штрафы за открытые подсказки и разбор
+	penalty, err := s.unlockPenalty(submission.UserID, submission.ProblemID)
+	if err != nil {
+		return err
+	}
+	earned := submission.Problem.Points*bestScore/100 - penalty
+	if earned < 0 {
+		earned = 0
+	}
+
+	var awarded int64
+	if err := s.db.Model(&models.UserSubmission{}).
+		Where("user_id = ? AND problem_id = ?", submission.UserID, submission.ProblemID).
+		Select("COALESCE(SUM(points_awarded), 0)").
+		Scan(&awarded).Error; err != nil {
+		return err
+	}
+
+	// Отправки, принятые до учёта баллов по отправкам, уже принесли баллы
+	if awarded == 0 {
+		var earlierAccepted int64
+		if err := s.db.Model(&models.UserSubmission{}).
+			Where("user_id = ? AND problem_id = ? AND status = ? AND id < ?",
+				submission.UserID, submission.ProblemID, models.SubmissionStatusAccepted, submission.ID).
+			Count(&earlierAccepted).Error; err != nil {
+			return err
+		}
+		if earlierAccepted > 0 {
+			awarded = int64(earned)
+		}
+	}
+
+	if delta := earned - int(awarded); delta > 0 {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.User{}).
+				Where("id = ?", submission.UserID).
+				Update("points", gorm.Expr("points + ?", delta)).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.UserSubmission{}).
+				Where("id = ?", submission.ID).
+				Update("points_awarded", gorm.Expr("points_awarded + ?", delta)).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// После решения разбор открывается автоматически
+	if submission.Status == models.SubmissionStatusAccepted && submission.Problem.Editorial != "" {
+		unlocked, err := s.isEditorialUnlocked(submission.UserID, submission.ProblemID)
+		if err != nil {
 			return err
 		}
+		if !unlocked {
+			if err := s.createUnlock(submission.UserID, submission.ProblemID, nil, true, 0); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// bestScore возвращает лучший результат пользователя по задаче в процентах
+func (s *ProblemService) bestScore(userID uint, problem models.Problem) (int, error) {
+	solved, err := s.hasAcceptedSubmission(userID, problem.ID)
+	if err != nil {
+		return 0, err
+	}
+	if solved {
+		return 100, nil
+	}
+	if strings.TrimSpace(problem.TestGroups) == "" {
+		return 0, nil
+	}
+
+	var best int64
+	if err := s.db.Model(&models.UserSubmission{}).
+		Where("user_id = ? AND problem_id = ?", userID, problem.ID).
+		Select("COALESCE(MAX(score), 0)").
+		Scan(&best).Error; err != nil {
+		return 0, err
+	}
+	return int(best), nil
+}
+
 // Admin methods
 
 // CreateProblem создает новую задачу
 func (s *ProblemService) CreateProblem(req *CreateProblemRequest) (*models.Problem, error) {
 	problem := &models.Problem{
-		Title:       req.Title,
-		Description: req.Description,
-		Difficulty:  models.ProblemLevel(req.Difficulty),
-		InitialCode: req.InitialCode,
-		TestCases:   req.TestCases,
-		Points:      req.Points,
-		TimeLimit:   req.TimeLimit,
-		MemoryLimit: req.MemoryLimit,
-		IsActive:    true,
+		Title:             req.Title,
+		Description:       req.Description,
+		Difficulty:        models.ProblemLevel(req.Difficulty),
+		InitialCode:       req.InitialCode,
+		TestCases:         req.TestCases,
+		TestGroups:        req.TestGroups,
+		Constraints:       req.Constraints,
+		Points:            req.Points,
+		TimeLimit:         req.TimeLimit,
+		MemoryLimit:       req.MemoryLimit,
+		ReferenceSolution: req.ReferenceSolution,
+		Checker:           req.Checker,
+		Interactor:        req.Interactor,
+		Generator:         req.Generator,
+		Editorial:         req.Editorial,
+		EditorialPenalty:  req.EditorialPenalty,
+		IsActive:          true,
+	}
+
+	if len(req.GeneratorArgs) > 0 {
+		generatorArgs, err := json.Marshal(req.GeneratorArgs)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сериализации аргументов генератора: %w", err)
+		}
+		problem.GeneratorArgs = string(generatorArgs)
+	}
+
+	if err := checkConstraints(problem); err != nil {
+		return nil, err
+	}
+
+	// Генерируем тесты и проверяем их эталонным решением
+	testCases, err := s.prepareTestCases(req.TestCases, req.ReferenceSolution, req.Generator, req.GeneratorArgs, req.Checker, req.Interactor, req.TimeLimit)
+	if err != nil {
+		return nil, err
+	}
+	problem.TestCases = testCases
+
+	if err := s.checkTestGroups(problem); err != nil {
+		return nil, err
 	}
 
 	if err := s.db.Create(problem).Error; err != nil {
@@ -215,9 +447,6 @@ func (s *ProblemService) UpdateProblem(id uint, req *UpdateProblemRequest) (*mod
 	if req.InitialCode != "" {
 		problem.InitialCode = req.InitialCode
 	}
-	if req.TestCases != "" {
-		problem.TestCases = req.TestCases
-	}
 	if req.Points > 0 {
 		problem.Points = req.Points
 	}
@@ -230,6 +459,67 @@ func (s *ProblemService) UpdateProblem(id uint, req *UpdateProblemRequest) (*mod
 	if req.IsActive != nil {
 		problem.IsActive = *req.IsActive
 	}
+	if req.Editorial != "" {
+		problem.Editorial = req.Editorial
+	}
+	if req.EditorialPenalty != nil {
+		problem.EditorialPenalty = *req.EditorialPenalty
+	}
+
+	// Изменение тестов, решения, чекера, интерактора, генератора или лимита времени требует повторной проверки
+	regenerate := req.TestCases != "" || req.ReferenceSolution != "" || req.Checker != "" || req.Interactor != "" ||
+		req.Generator != "" || req.GeneratorArgs != nil || req.TimeLimit > 0
+	if req.TestCases != "" {
+		problem.TestCases = req.TestCases
+	}
+	if req.ReferenceSolution != "" {
+		problem.ReferenceSolution = req.ReferenceSolution
+	}
+	if req.Checker != "" {
+		problem.Checker = req.Checker
+	}
+	if req.Interactor != "" {
+		problem.Interactor = req.Interactor
+	}
+	if req.Generator != "" {
+		problem.Generator = req.Generator
+	}
+	if req.GeneratorArgs != nil {
+		generatorArgs, err := json.Marshal(req.GeneratorArgs)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сериализации аргументов генератора: %w", err)
+		}
+		problem.GeneratorArgs = string(generatorArgs)
+	}
+
+	if req.Constraints != "" {
+		problem.Constraints = req.Constraints
+	}
+	if err := checkConstraints(&problem); err != nil {
+		return nil, err
+	}
+
+	if regenerate {
+		var generatorArgs []string
+		if problem.GeneratorArgs != "" {
+			if err := json.Unmarshal([]byte(problem.GeneratorArgs), &generatorArgs); err != nil {
+				return nil, fmt.Errorf("ошибка чтения аргументов генератора: %w", err)
+			}
+		}
+
+		testCases, err := s.prepareTestCases(problem.TestCases, problem.ReferenceSolution, problem.Generator, generatorArgs, problem.Checker, problem.Interactor, problem.TimeLimit)
+		if err != nil {
+			return nil, err
+		}
+		problem.TestCases = testCases
+	}
+
+	if req.TestGroups != "" {
+		problem.TestGroups = req.TestGroups
+	}
+	if err := s.checkTestGroups(&problem); err != nil {
+		return nil, err
+	}
 
 	if err := s.db.Save(&problem).Error; err != nil {
 		return nil, fmt.Errorf("ошибка обновления задачи: %w", err)
@@ -238,6 +528,34 @@ func (s *ProblemService) UpdateProblem(id uint, req *UpdateProblemRequest) (*mod
 	return &problem, nil
 }
 
+// checkTestGroups проверяет согласованность групп тестов с тест-кейсами задачи
+func (s *ProblemService) checkTestGroups(problem *models.Problem) error {
+	groups, err := ParseTestGroups(problem.TestGroups)
+	if err != nil {
+		return err
+	}
+	testCases, err := s.sandbox.ParseTestCases(problem.TestCases)
+	if err != nil {
+		return err
+	}
+	return validateTestGroups(groups, testCases)
+}
+
+// checkConstraints проверяет ограничения задачи и то, что эталонное решение им удовлетворяет
+func checkConstraints(problem *models.Problem) error {
+	constraints, err := ParseSolutionConstraints(problem.Constraints)
+	if err != nil {
+		return err
+	}
+	if constraints == nil || strings.TrimSpace(problem.ReferenceSolution) == "" {
+		return nil
+	}
+	if violations := CheckSolutionConstraints(problem.ReferenceSolution, constraints); len(violations) > 0 {
+		return fmt.Errorf("эталонное решение не удовлетворяет ограничениям: %s", FormatConstraintViolations(violations))
+	}
+	return nil
+}
+
 // DeleteProblem удаляет задачу
 func (s *ProblemService) DeleteProblem(id uint) error {
 	if err := s.db.Delete(&models.Problem{}, id).Error; err != nil {
@@ -252,10 +570,23 @@ type CreateProblemRequest struct {
 	Description string `json:"description" binding:"required"`
 	Difficulty  string `json:"difficulty" binding:"required,oneof=easy medium hard"`
 	InitialCode string `json:"initial_code" binding:"omitempty"`
-	TestCases   string `json:"test_cases" binding:"required"`
+	TestCases   string `json:"test_cases" binding:"required_without=Generator"`
+	TestGroups  string `json:"test_groups" binding:"omitempty"`
+	Constraints string `json:"constraints" binding:"omitempty"`
 	Points      int    `json:"points" binding:"required,min=1"`
 	TimeLimit   int    `json:"time_limit" binding:"omitempty,min=1"`
 	MemoryLimit int    `json:"memory_limit" binding:"omitempty,min=1"`
+
+	// Эталонное решение, чекер, интерактор и генератор тестов автора
+	ReferenceSolution string   `json:"reference_solution" binding:"required_with=Generator"`
+	Checker           string   `json:"checker" binding:"omitempty"`
+	Interactor        string   `json:"interactor" binding:"omitempty"`
+	Generator         string   `json:"generator" binding:"omitempty"`
+	GeneratorArgs     []string `json:"generator_args" binding:"required_with=Generator"`
+
+	// Разбор и штраф за его открытие до решения
+	Editorial        string `json:"editorial" binding:"omitempty"`
+	EditorialPenalty int    `json:"editorial_penalty" binding:"omitempty,min=0"`
 }
 
 type UpdateProblemRequest struct {
@@ -264,10 +595,21 @@ type UpdateProblemRequest struct {
 	Difficulty  string `json:"difficulty" binding:"omitempty,oneof=easy medium hard"`
 	InitialCode string `json:"initial_code" binding:"omitempty"`
 	TestCases   string `json:"test_cases" binding:"omitempty"`
+	TestGroups  string `json:"test_groups" binding:"omitempty"`
+	Constraints string `json:"constraints" binding:"omitempty"`
 	Points      int    `json:"points" binding:"omitempty,min=1"`
 	TimeLimit   int    `json:"time_limit" binding:"omitempty,min=1"`
 	MemoryLimit int    `json:"memory_limit" binding:"omitempty,min=1"`
 	IsActive    *bool  `json:"is_active"`
+
+	ReferenceSolution string   `json:"reference_solution" binding:"omitempty"`
+	Checker           string   `json:"checker" binding:"omitempty"`
+	Interactor        string   `json:"interactor" binding:"omitempty"`
+	Generator         string   `json:"generator" binding:"omitempty"`
+	GeneratorArgs     []string `json:"generator_args"`
+
+	Editorial        string `json:"editorial" binding:"omitempty"`
+	EditorialPenalty *int   `json:"editorial_penalty" binding:"omitempty,min=0"`
 }
 
 type SubmitSolutionRequest struct {
@@ -282,4 +624,5 @@ type SubmissionResult struct {
 	ExecutionTime int                     `json:"execution_time"`
 	MemoryUsed    int                     `json:"memory_used"`
 	ErrorOutput   string                  `json:"error_output"`
+	Groups        []GroupResult           `json:"groups,omitempty"`
 }
\ No newline at end of file
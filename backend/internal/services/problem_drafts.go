@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"go-education-platform/internal/models"
+	"go-education-platform/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxDraftSize ограничивает размер черновика в байтах
+const maxDraftSize = 256 << 10
+
+// SaveDraft сохраняет текущее содержимое редактора пользователя по задаче
+func (s *ProblemService) SaveDraft(userID, problemID uint, code string) (*models.ProblemDraft, error) {
+	if len(code) > maxDraftSize {
+		return nil, errors.New("черновик слишком большой")
+	}
+	if _, err := s.GetProblemByID(problemID); err != nil {
+		return nil, err
+	}
+
+	draft := &models.ProblemDraft{
+		UserID:    userID,
+		ProblemID: problemID,
+		Code:      code,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "problem_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"code", "updated_at"}),
+	}).Create(draft).Error; err != nil {
+		return nil, fmt.Errorf("ошибка сохранения черновика: %w", err)
+	}
+
+	return s.GetDraft(userID, problemID)
+}
+
+// GetDraft получает черновик пользователя по задаче
+func (s *ProblemService) GetDraft(userID, problemID uint) (*models.ProblemDraft, error) {
+	var draft models.ProblemDraft
+	if err := s.db.Where("user_id = ? AND problem_id = ?", userID, problemID).
+		First(&draft).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("черновик не найден")
+		}
+		return nil, fmt.Errorf("ошибка получения черновика: %w", err)
+	}
+	return &draft, nil
+}
+
+// DeleteDraft удаляет черновик пользователя по задаче
+func (s *ProblemService) DeleteDraft(userID, problemID uint) error {
+	if err := s.db.Where("user_id = ? AND problem_id = ?", userID, problemID).
+		Delete(&models.ProblemDraft{}).Error; err != nil {
+		return fmt.Errorf("ошибка удаления черновика: %w", err)
+	}
+	return nil
+}
+
+// DiffSubmissions сравнивает код двух отправок пользователя по одной задаче
+func (s *ProblemService) DiffSubmissions(userID, problemID, fromID, toID uint) (*SubmissionDiff, error) {
+	var submissions []models.UserSubmission
+	if err := s.db.Where("id IN ? AND user_id = ? AND problem_id = ?", []uint{fromID, toID}, userID, problemID).
+		Find(&submissions).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения отправок: %w", err)
+	}
+
+	byID := make(map[uint]models.UserSubmission, len(submissions))
+	for _, submission := range submissions {
+		byID[submission.ID] = submission
+	}
+	from, ok := byID[fromID]
+	if !ok {
+		return nil, errors.New("отправка не найдена")
+	}
+	to, ok := byID[toID]
+	if !ok {
+		return nil, errors.New("отправка не найдена")
+	}
+
+	lines, err := utils.DiffLines(from.Code, to.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SubmissionDiff{
+		FromID: from.ID,
+		ToID:   to.ID,
+		Lines:  lines,
+		Unified: utils.UnifiedDiff(lines,
+			fmt.Sprintf("submission-%d", from.ID),
+			fmt.Sprintf("submission-%d", to.ID), 3),
+	}
+	for _, line := range lines {
+		switch line.Op {
+		case utils.DiffInsert:
+			diff.Added++
+		case utils.DiffDelete:
+			diff.Removed++
+		}
+	}
+
+	return diff, nil
+}
+
+// SaveDraftRequest структура запроса сохранения черновика
+type SaveDraftRequest struct {
+	Code string `json:"code"`
+}
+
+// SubmissionDiff результат сравнения двух отправок
+type SubmissionDiff struct {
+	FromID  uint             `json:"from_id"`
+	ToID    uint             `json:"to_id"`
+	Added   int              `json:"added"`
+	Removed int              `json:"removed"`
+	Lines   []utils.DiffLine `json:"lines"`
+	Unified string           `json:"unified"`
+}
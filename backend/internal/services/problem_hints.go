@@ -0,0 +1,323 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetHints возвращает подсказки задачи. Текст доступен только для открытых подсказок
+func (s *ProblemService) GetHints(userID, problemID uint) ([]HintView, error) {
+	if _, err := s.GetProblemByID(problemID); err != nil {
+		return nil, err
+	}
+
+	var hints []models.ProblemHint
+	if err := s.db.Where("problem_id = ?", problemID).
+		Order("\"order\" ASC, id ASC").
+		Find(&hints).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения подсказок: %w", err)
+	}
+
+	unlocked, err := s.unlockedHintIDs(userID, problemID)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]HintView, 0, len(hints))
+	for i, hint := range hints {
+		view := HintView{
+			ID:            hint.ID,
+			Order:         i + 1,
+			PointsPenalty: hint.PointsPenalty,
+		}
+		if _, ok := unlocked[hint.ID]; ok {
+			view.IsUnlocked = true
+			view.Content = hint.Content
+		}
+		views = append(views, view)
+	}
+
+	return views, nil
+}
+
+// UnlockHint открывает подсказку. Подсказки открываются строго по порядку
+func (s *ProblemService) UnlockHint(userID, problemID, hintID uint) (*HintView, error) {
+	if _, err := s.GetProblemByID(problemID); err != nil {
+		return nil, err
+	}
+
+	var hints []models.ProblemHint
+	if err := s.db.Where("problem_id = ?", problemID).
+		Order("\"order\" ASC, id ASC").
+		Find(&hints).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения подсказок: %w", err)
+	}
+
+	unlocked, err := s.unlockedHintIDs(userID, problemID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, hint := range hints {
+		if hint.ID != hintID {
+			if _, ok := unlocked[hint.ID]; !ok {
+				return nil, errors.New("сначала откройте предыдущие подсказки")
+			}
+			continue
+		}
+
+		view := &HintView{
+			ID:            hint.ID,
+			Order:         i + 1,
+			PointsPenalty: hint.PointsPenalty,
+			IsUnlocked:    true,
+			Content:       hint.Content,
+		}
+		if _, ok := unlocked[hint.ID]; ok {
+			return view, nil
+		}
+
+		if err := s.createUnlock(userID, problemID, &hint.ID, false, hint.PointsPenalty); err != nil {
+			return nil, err
+		}
+		return view, nil
+	}
+
+	return nil, errors.New("подсказка не найдена")
+}
+
+// GetEditorial возвращает разбор задачи, если он открыт пользователем или задача уже решена
+func (s *ProblemService) GetEditorial(userID, problemID uint) (*EditorialView, error) {
+	problem, err := s.GetProblemByID(problemID)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &EditorialView{
+		ProblemID:     problem.ID,
+		PointsPenalty: problem.EditorialPenalty,
+		IsAvailable:   problem.Editorial != "",
+	}
+
+	unlocked, err := s.isEditorialUnlocked(userID, problemID)
+	if err != nil {
+		return nil, err
+	}
+	if !unlocked {
+		// Разбор открывается автоматически после принятого решения
+		solved, err := s.hasAcceptedSubmission(userID, problemID)
+		if err != nil {
+			return nil, err
+		}
+		if solved {
+			if err := s.createUnlock(userID, problemID, nil, true, 0); err != nil {
+				return nil, err
+			}
+			unlocked = true
+		}
+	}
+
+	if unlocked {
+		view.IsUnlocked = true
+		view.Content = problem.Editorial
+	}
+	return view, nil
+}
+
+// UnlockEditorial открывает разбор задачи со штрафом, если задача ещё не решена
+func (s *ProblemService) UnlockEditorial(userID, problemID uint) (*EditorialView, error) {
+	problem, err := s.GetProblemByID(problemID)
+	if err != nil {
+		return nil, err
+	}
+	if problem.Editorial == "" {
+		return nil, errors.New("разбор для задачи отсутствует")
+	}
+
+	unlocked, err := s.isEditorialUnlocked(userID, problemID)
+	if err != nil {
+		return nil, err
+	}
+	if !unlocked {
+		if err := s.createUnlock(userID, problemID, nil, true, problem.EditorialPenalty); err != nil {
+			return nil, err
+		}
+	}
+
+	return &EditorialView{
+		ProblemID:     problem.ID,
+		PointsPenalty: problem.EditorialPenalty,
+		IsAvailable:   true,
+		IsUnlocked:    true,
+		Content:       problem.Editorial,
+	}, nil
+}
+
+// unlockPenalty возвращает суммарный штраф пользователя за открытые подсказки и разбор
+func (s *ProblemService) unlockPenalty(userID, problemID uint) (int, error) {
+	var penalty int64
+	if err := s.db.Model(&models.ProblemUnlock{}).
+		Where("user_id = ? AND problem_id = ?", userID, problemID).
+		Select("COALESCE(SUM(penalty), 0)").
+		Scan(&penalty).Error; err != nil {
+		return 0, fmt.Errorf("ошибка подсчёта штрафа: %w", err)
+	}
+	return int(penalty), nil
+}
+
+// createUnlock записывает открытие подсказки или разбора. После решения задачи
+// штраф не применяется, так как баллы уже начислены. Повторное открытие из
+// параллельного запроса пропускается уникальным индексом, и штраф не удваивается
+func (s *ProblemService) createUnlock(userID, problemID uint, hintID *uint, isEditorial bool, penalty int) error {
+	solved, err := s.hasAcceptedSubmission(userID, problemID)
+	if err != nil {
+		return err
+	}
+	if solved {
+		penalty = 0
+	}
+
+	unlock := &models.ProblemUnlock{
+		UserID:      userID,
+		ProblemID:   problemID,
+		HintID:      hintID,
+		IsEditorial: isEditorial,
+		Penalty:     penalty,
+		UnlockedAt:  time.Now(),
+	}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(unlock).Error; err != nil {
+		return fmt.Errorf("ошибка сохранения открытия: %w", err)
+	}
+	return nil
+}
+
+func (s *ProblemService) unlockedHintIDs(userID, problemID uint) (map[uint]struct{}, error) {
+	var unlocks []models.ProblemUnlock
+	if err := s.db.Where("user_id = ? AND problem_id = ? AND hint_id IS NOT NULL", userID, problemID).
+		Find(&unlocks).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения открытых подсказок: %w", err)
+	}
+
+	ids := make(map[uint]struct{}, len(unlocks))
+	for _, unlock := range unlocks {
+		ids[*unlock.HintID] = struct{}{}
+	}
+	return ids, nil
+}
+
+func (s *ProblemService) isEditorialUnlocked(userID, problemID uint) (bool, error) {
+	var count int64
+	if err := s.db.Model(&models.ProblemUnlock{}).
+		Where("user_id = ? AND problem_id = ? AND is_editorial = ?", userID, problemID, true).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("ошибка проверки разбора: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *ProblemService) hasAcceptedSubmission(userID, problemID uint) (bool, error) {
+	var count int64
+	if err := s.db.Model(&models.UserSubmission{}).
+		Where("user_id = ? AND problem_id = ? AND status = ?", userID, problemID, models.SubmissionStatusAccepted).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("ошибка проверки решения: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Admin methods
+
+// CreateHint добавляет подсказку к задаче
+func (s *ProblemService) CreateHint(problemID uint, req *CreateHintRequest) (*models.ProblemHint, error) {
+	var problem models.Problem
+	if err := s.db.First(&problem, problemID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("задача не найдена")
+		}
+		return nil, fmt.Errorf("ошибка получения задачи: %w", err)
+	}
+
+	hint := &models.ProblemHint{
+		ProblemID:     problemID,
+		Content:       req.Content,
+		Order:         req.Order,
+		PointsPenalty: req.PointsPenalty,
+	}
+	if err := s.db.Create(hint).Error; err != nil {
+		return nil, fmt.Errorf("ошибка создания подсказки: %w", err)
+	}
+
+	return hint, nil
+}
+
+// UpdateHint обновляет подсказку
+func (s *ProblemService) UpdateHint(id uint, req *UpdateHintRequest) (*models.ProblemHint, error) {
+	var hint models.ProblemHint
+	if err := s.db.First(&hint, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("подсказка не найдена")
+		}
+		return nil, fmt.Errorf("ошибка получения подсказки: %w", err)
+	}
+
+	if req.Content != "" {
+		hint.Content = req.Content
+	}
+	if req.Order != nil {
+		hint.Order = *req.Order
+	}
+	if req.PointsPenalty != nil {
+		hint.PointsPenalty = *req.PointsPenalty
+	}
+
+	if err := s.db.Save(&hint).Error; err != nil {
+		return nil, fmt.Errorf("ошибка обновления подсказки: %w", err)
+	}
+
+	return &hint, nil
+}
+
+// DeleteHint скрывает подсказку мягким удалением: на неё ссылаются записи об
+// открытии, штраф по которым уже учтён в баллах
+func (s *ProblemService) DeleteHint(id uint) error {
+	if err := s.db.Delete(&models.ProblemHint{}, id).Error; err != nil {
+		return fmt.Errorf("ошибка удаления подсказки: %w", err)
+	}
+	return nil
+}
+
+// HintView представление подсказки для студента
+type HintView struct {
+	ID            uint   `json:"id"`
+	Order         int    `json:"order"`
+	PointsPenalty int    `json:"points_penalty"`
+	IsUnlocked    bool   `json:"is_unlocked"`
+	Content       string `json:"content,omitempty"`
+}
+
+// EditorialView представление разбора задачи для студента
+type EditorialView struct {
+	ProblemID     uint   `json:"problem_id"`
+	PointsPenalty int    `json:"points_penalty"`
+	IsAvailable   bool   `json:"is_available"`
+	IsUnlocked    bool   `json:"is_unlocked"`
+	Content       string `json:"content,omitempty"`
+}
+
+type CreateHintRequest struct {
+	Content       string `json:"content" binding:"required"`
+	Order         int    `json:"order" binding:"omitempty,min=0"`
+	PointsPenalty int    `json:"points_penalty" binding:"omitempty,min=0"`
+}
+
+type UpdateHintRequest struct {
+	Content       string `json:"content" binding:"omitempty"`
+	Order         *int   `json:"order" binding:"omitempty,min=0"`
+	PointsPenalty *int   `json:"points_penalty" binding:"omitempty,min=0"`
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// maxProblemListSize ограничивает число задач в одной подборке
+const maxProblemListSize = 200
+
+var errProblemListNotFound = errors.New("подборка не найдена")
+
+type ProblemListService struct {
+	db *gorm.DB
+}
+
+func NewProblemListService(db *gorm.DB) *ProblemListService {
+	return &ProblemListService{db: db}
+}
+
+// ProblemListView подборка с прогрессом просматривающего пользователя.
+// Ссылка для доступа показывается только тем, кто может редактировать подборку
+type ProblemListView struct {
+	ID          uint                `json:"id"`
+	OwnerID     uint                `json:"owner_id"`
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	IsOfficial  bool                `json:"is_official"`
+	Order       int                 `json:"order"`
+	ShareToken  *string             `json:"share_token,omitempty"`
+	CanEdit     bool                `json:"can_edit"`
+	Problems    []ProblemListEntry  `json:"problems"`
+	Progress    ProblemListProgress `json:"progress"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// ProblemListEntry задача подборки с отметкой о решении
+type ProblemListEntry struct {
+	Position   int                 `json:"position"`
+	ProblemID  uint                `json:"problem_id"`
+	Title      string              `json:"title"`
+	Difficulty models.ProblemLevel `json:"difficulty"`
+	Points     int                 `json:"points"`
+	Solved     bool                `json:"solved"`
+}
+
+// ProblemListProgress прогресс пользователя по подборке
+type ProblemListProgress struct {
+	Solved  int `json:"solved"`
+	Total   int `json:"total"`
+	Percent int `json:"percent"`
+}
+
+// GetUserLists возвращает подборки пользователя
+func (s *ProblemListService) GetUserLists(userID uint) ([]ProblemListView, error) {
+	var lists []models.ProblemList
+	if err := s.preloadItems(s.db).
+		Where("owner_id = ? AND is_official = ?", userID, false).
+		Order("updated_at DESC").
+		Find(&lists).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения подборок: %w", err)
+	}
+	return s.buildViews(lists, userID, false)
+}
+
+// GetOfficialPaths возвращает официальные учебные траектории
+func (s *ProblemListService) GetOfficialPaths(userID uint, isAdmin bool) ([]ProblemListView, error) {
+	var lists []models.ProblemList
+	if err := s.preloadItems(s.db).
+		Where("is_official = ?", true).
+		Order("\"order\" ASC, id ASC").
+		Find(&lists).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения учебных траекторий: %w", err)
+	}
+	return s.buildViews(lists, userID, isAdmin)
+}
+
+// GetList возвращает подборку владельцу или официальную траекторию любому пользователю
+func (s *ProblemListService) GetList(id, userID uint, isAdmin bool) (*ProblemListView, error) {
+	var list models.ProblemList
+	if err := s.preloadItems(s.db).First(&list, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errProblemListNotFound
+		}
+		return nil, fmt.Errorf("ошибка получения подборки: %w", err)
+	}
+	if !list.IsOfficial && list.OwnerID != userID {
+		return nil, errProblemListNotFound
+	}
+	return s.buildView(&list, userID, isAdmin)
+}
+
+// GetSharedList возвращает подборку по ссылке для доступа
+func (s *ProblemListService) GetSharedList(token string, userID uint, isAdmin bool) (*ProblemListView, error) {
+	var list models.ProblemList
+	if err := s.preloadItems(s.db).Where("share_token = ?", token).First(&list).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errProblemListNotFound
+		}
+		return nil, fmt.Errorf("ошибка получения подборки: %w", err)
+	}
+	return s.buildView(&list, userID, isAdmin)
+}
+
+// CreateList создаёт подборку. Официальные траектории создают только администраторы
+func (s *ProblemListService) CreateList(userID uint, isAdmin bool, req *CreateProblemListRequest) (*ProblemListView, error) {
+	if req.IsOfficial && !isAdmin {
+		return nil, errors.New("официальные траектории создают только администраторы")
+	}
+	if err := s.validateProblems(req.ProblemIDs); err != nil {
+		return nil, err
+	}
+
+	list := &models.ProblemList{
+		OwnerID:     userID,
+		Title:       req.Title,
+		Description: req.Description,
+		IsOfficial:  req.IsOfficial,
+		Order:       req.Order,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(list).Error; err != nil {
+			return fmt.Errorf("ошибка создания подборки: %w", err)
+		}
+		return replaceListItems(tx, list.ID, req.ProblemIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetList(list.ID, userID, isAdmin)
+}
+
+// UpdateList обновляет подборку. ProblemIDs, если передан, задаёт новый порядок задач целиком
+func (s *ProblemListService) UpdateList(id, userID uint, isAdmin bool, req *UpdateProblemListRequest) (*ProblemListView, error) {
+	list, err := s.editableList(id, userID, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Title != "" {
+		list.Title = req.Title
+	}
+	if req.Description != nil {
+		list.Description = *req.Description
+	}
+	if req.Order != nil {
+		list.Order = *req.Order
+	}
+	if req.ProblemIDs != nil {
+		if err := s.validateProblems(req.ProblemIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(list).Error; err != nil {
+			return fmt.Errorf("ошибка обновления подборки: %w", err)
+		}
+		if req.ProblemIDs != nil {
+			return replaceListItems(tx, list.ID, req.ProblemIDs)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetList(list.ID, userID, isAdmin)
+}
+
+// DeleteList удаляет подборку вместе с её задачами
+func (s *ProblemListService) DeleteList(id, userID uint, isAdmin bool) error {
+	list, err := s.editableList(id, userID, isAdmin)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("list_id = ?", list.ID).Delete(&models.ProblemListItem{}).Error; err != nil {
+			return fmt.Errorf("ошибка удаления задач подборки: %w", err)
+		}
+		if err := tx.Delete(list).Error; err != nil {
+			return fmt.Errorf("ошибка удаления подборки: %w", err)
+		}
+		return nil
+	})
+}
+
+// EnableSharing открывает доступ к подборке по ссылке и возвращает токен.
+// Повторный вызов выдаёт новый токен, старая ссылка перестаёт работать
+func (s *ProblemListService) EnableSharing(id, userID uint, isAdmin bool) (string, error) {
+	list, err := s.editableList(id, userID, isAdmin)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.db.Model(list).Update("share_token", token).Error; err != nil {
+		return "", fmt.Errorf("ошибка обновления подборки: %w", err)
+	}
+	return token, nil
+}
+
+// DisableSharing закрывает доступ к подборке по ссылке
+func (s *ProblemListService) DisableSharing(id, userID uint, isAdmin bool) error {
+	list, err := s.editableList(id, userID, isAdmin)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Model(list).Update("share_token", nil).Error; err != nil {
+		return fmt.Errorf("ошибка обновления подборки: %w", err)
+	}
+	return nil
+}
+
+// editableList загружает подборку, которую пользователь может изменять:
+// свою личную или, для администратора, официальную
+func (s *ProblemListService) editableList(id, userID uint, isAdmin bool) (*models.ProblemList, error) {
+	var list models.ProblemList
+	if err := s.db.First(&list, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errProblemListNotFound
+		}
+		return nil, fmt.Errorf("ошибка получения подборки: %w", err)
+	}
+	if !canEditList(&list, userID, isAdmin) {
+		if list.IsOfficial {
+			return nil, errors.New("официальные траектории изменяют только администраторы")
+		}
+		return nil, errProblemListNotFound
+	}
+	return &list, nil
+}
+
+func canEditList(list *models.ProblemList, userID uint, isAdmin bool) bool {
+	if list.IsOfficial {
+		return isAdmin
+	}
+	return list.OwnerID == userID
+}
+
+// validateProblems проверяет, что задачи существуют, активны и не повторяются
+func (s *ProblemListService) validateProblems(problemIDs []uint) error {
+	if len(problemIDs) > maxProblemListSize {
+		return fmt.Errorf("в подборке может быть не больше %d задач", maxProblemListSize)
+	}
+	if len(problemIDs) == 0 {
+		return nil
+	}
+
+	seen := make(map[uint]bool, len(problemIDs))
+	for _, id := range problemIDs {
+		if seen[id] {
+			return fmt.Errorf("задача %d указана в подборке дважды", id)
+		}
+		seen[id] = true
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Problem{}).
+		Where("id IN ? AND is_active = ?", problemIDs, true).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("ошибка проверки задач: %w", err)
+	}
+	if int(count) != len(problemIDs) {
+		return errors.New("подборка содержит несуществующие задачи")
+	}
+	return nil
+}
+
+// replaceListItems заменяет задачи подборки в заданном порядке
+func replaceListItems(tx *gorm.DB, listID uint, problemIDs []uint) error {
+	if err := tx.Where("list_id = ?", listID).Delete(&models.ProblemListItem{}).Error; err != nil {
+		return fmt.Errorf("ошибка обновления задач подборки: %w", err)
+	}
+	if len(problemIDs) == 0 {
+		return nil
+	}
+
+	items := make([]models.ProblemListItem, len(problemIDs))
+	for i, problemID := range problemIDs {
+		items[i] = models.ProblemListItem{ListID: listID, ProblemID: problemID, Position: i + 1}
+	}
+	if err := tx.Create(&items).Error; err != nil {
+		return fmt.Errorf("ошибка обновления задач подборки: %w", err)
+	}
+	return nil
+}
+
+func (s *ProblemListService) preloadItems(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).Preload("Items.Problem")
+}
+
+func (s *ProblemListService) buildView(list *models.ProblemList, userID uint, isAdmin bool) (*ProblemListView, error) {
+	views, err := s.buildViews([]models.ProblemList{*list}, userID, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+	return &views[0], nil
+}
+
+// buildViews формирует представления подборок, отмечая задачи, решённые пользователем.
+// Скрытые задачи в подборке не показываются и не учитываются в прогрессе
+func (s *ProblemListService) buildViews(lists []models.ProblemList, userID uint, isAdmin bool) ([]ProblemListView, error) {
+	var problemIDs []uint
+	for _, list := range lists {
+		for _, item := range list.Items {
+			problemIDs = append(problemIDs, item.ProblemID)
+		}
+	}
+
+	solved := make(map[uint]bool)
+	if len(problemIDs) > 0 {
+		var solvedIDs []uint
+		if err := s.db.Model(&models.UserSubmission{}).
+			Where("user_id = ? AND status = ? AND problem_id IN ?", userID, models.SubmissionStatusAccepted, problemIDs).
+			Distinct("problem_id").
+			Pluck("problem_id", &solvedIDs).Error; err != nil {
+			return nil, fmt.Errorf("ошибка получения решённых задач: %w", err)
+		}
+		for _, id := range solvedIDs {
+			solved[id] = true
+		}
+	}
+
+	views := make([]ProblemListView, 0, len(lists))
+	for i := range lists {
+		list := &lists[i]
+		view := ProblemListView{
+			ID:          list.ID,
+			OwnerID:     list.OwnerID,
+			Title:       list.Title,
+			Description: list.Description,
+			IsOfficial:  list.IsOfficial,
+			Order:       list.Order,
+			CanEdit:     canEditList(list, userID, isAdmin),
+			Problems:    []ProblemListEntry{},
+			UpdatedAt:   list.UpdatedAt,
+		}
+		if view.CanEdit {
+			view.ShareToken = list.ShareToken
+		}
+
+		for _, item := range list.Items {
+			if !item.Problem.IsActive {
+				continue
+			}
+			entry := ProblemListEntry{
+				Position:   len(view.Problems) + 1,
+				ProblemID:  item.ProblemID,
+				Title:      item.Problem.Title,
+				Difficulty: item.Problem.Difficulty,
+				Points:     item.Problem.Points,
+				Solved:     solved[item.ProblemID],
+			}
+			view.Problems = append(view.Problems, entry)
+			if entry.Solved {
+				view.Progress.Solved++
+			}
+		}
+		view.Progress.Total = len(view.Problems)
+		if view.Progress.Total > 0 {
+			view.Progress.Percent = view.Progress.Solved * 100 / view.Progress.Total
+		}
+
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// generateShareToken генерирует случайный токен ссылки на подборку
+func generateShareToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("ошибка генерации ссылки: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Request structures
+
+type CreateProblemListRequest struct {
+	Title       string `json:"title" binding:"required,min=2,max=100"`
+	Description string `json:"description" binding:"omitempty"`
+	IsOfficial  bool   `json:"is_official"`
+	Order       int    `json:"order" binding:"omitempty,min=0"`
+	ProblemIDs  []uint `json:"problem_ids"`
+}
+
+type UpdateProblemListRequest struct {
+	Title       string  `json:"title" binding:"omitempty,min=2,max=100"`
+	Description *string `json:"description"`
+	Order       *int    `json:"order" binding:"omitempty,min=0"`
+	ProblemIDs  []uint  `json:"problem_ids"` // nil — состав не меняется
+}
@@ -0,0 +1,674 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"go-education-platform/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Форматы пакетов задач
+const (
+	PackageFormatNative  = "native"
+	PackageFormatKattis  = "kattis"
+	PackageFormatPolygon = "polygon"
+)
+
+// Ограничения распаковки пакета: размер одного файла, число файлов и общий
+// размер распакованных файлов
+const (
+	maxPackageFileSize  = 16 << 20
+	maxPackageFiles     = 2000
+	maxPackageTotalSize = 128 << 20
+)
+
+// ProblemPackage представляет переносимый пакет задачи.
+//
+// Собственный формат (native):
+//
+//	problem.yaml        метаданные
+//	statement.md        условие
+//	initial.go          начальный код (необязательно)
+//	solution.go         эталонное решение
+//	checker.go          чекер (необязательно)
+//	interactor.go       интерактор для интерактивных задач (необязательно)
+//	generator.go        генератор тестов (необязательно)
+//	tests/<name>.in     входные данные
+//	tests/<name>.out    ожидаемый вывод
+//
+// При наличии подзадач тесты группы лежат в tests/<группа>/.
+//
+// Также поддерживаются пакеты Kattis (problem.yaml, problem_statement/,
+// data/sample, data/secret, submissions/accepted) и Polygon (problem.xml,
+// tests/NN, tests/NN.a, интерактор из assets/interactor).
+type ProblemPackage struct {
+	Format      string
+	Metadata    ProblemPackageMetadata
+	Statement   string
+	InitialCode string
+	Solution    string
+	Checker     string
+	Interactor  string
+	Generator   string
+	Tests       []TestCase
+	Warnings    []string
+}
+
+// ProblemPackageMetadata содержимое problem.yaml
+type ProblemPackageMetadata struct {
+	Title       string `yaml:"title,omitempty"`
+	Name        string `yaml:"name,omitempty"` // название в формате Kattis
+	Difficulty  string `yaml:"difficulty,omitempty"`
+	Points      int    `yaml:"points,omitempty"`
+	TimeLimit   int    `yaml:"time_limit,omitempty"`   // в секундах
+	MemoryLimit int    `yaml:"memory_limit,omitempty"` // в MB
+
+	GeneratorArgs []string             `yaml:"generator_args,omitempty"` // аргументы запусков generator.go
+	TestGroups    []TestGroup          `yaml:"test_groups,omitempty"`    // подзадачи, тесты лежат в tests/<группа>/
+	Constraints   *SolutionConstraints `yaml:"constraints,omitempty"`    // ограничения на код решения
+
+	Limits *ProblemPackageLimits `yaml:"limits,omitempty"` // ограничения в формате Kattis
+}
+
+// ProblemPackageLimits секция limits из problem.yaml формата Kattis
+type ProblemPackageLimits struct {
+	TimeLimit float64 `yaml:"time_limit,omitempty"` // в секундах
+	Memory    int     `yaml:"memory,omitempty"`     // в MB
+}
+
+// PackageImportReport результат импорта пакета задачи
+type PackageImportReport struct {
+	Format     string          `json:"format"`
+	TestsCount int             `json:"tests_count"`
+	Validated  bool            `json:"validated"`
+	Published  bool            `json:"published"`
+	Warnings   []string        `json:"warnings"`
+	Problem    *models.Problem `json:"problem"`
+}
+
+// ImportProblemPackage импортирует задачу из zip-архива. Перед публикацией
+// эталонное решение прогоняется на всех тестах в песочнице
+func (s *ProblemService) ImportProblemPackage(data []byte, publish bool) (*PackageImportReport, error) {
+	pkg, err := ParseProblemPackage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	testCasesJSON, err := encodeTestCases(pkg.Tests)
+	if err != nil {
+		return nil, err
+	}
+
+	problem := &models.Problem{
+		Title:             pkg.Metadata.title(),
+		Description:       pkg.Statement,
+		Difficulty:        models.ProblemLevel(pkg.Metadata.Difficulty),
+		InitialCode:       pkg.InitialCode,
+		TestCases:         testCasesJSON,
+		Points:            pkg.Metadata.Points,
+		TimeLimit:         pkg.Metadata.timeLimit(),
+		MemoryLimit:       pkg.Metadata.memoryLimit(),
+		ReferenceSolution: pkg.Solution,
+		Checker:           pkg.Checker,
+		Interactor:        pkg.Interactor,
+		Generator:         pkg.Generator,
+	}
+	if problem.Title == "" {
+		return nil, errors.New("в пакете не указано название задачи")
+	}
+	if len(pkg.Metadata.TestGroups) > 0 {
+		testGroups, err := json.Marshal(pkg.Metadata.TestGroups)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сериализации групп тестов: %w", err)
+		}
+		problem.TestGroups = string(testGroups)
+	}
+	if pkg.Metadata.Constraints != nil {
+		constraints, err := json.Marshal(pkg.Metadata.Constraints)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сериализации ограничений решения: %w", err)
+		}
+		problem.Constraints = string(constraints)
+		if err := checkConstraints(problem); err != nil {
+			return nil, err
+		}
+	}
+	switch problem.Difficulty {
+	case models.ProblemLevelEasy, models.ProblemLevelMedium, models.ProblemLevelHard:
+	default:
+		problem.Difficulty = models.ProblemLevelEasy
+	}
+	if problem.Points <= 0 {
+		problem.Points = 20
+	}
+
+	report := &PackageImportReport{
+		Format:     pkg.Format,
+		TestsCount: len(pkg.Tests),
+		Warnings:   pkg.Warnings,
+	}
+
+	if pkg.Solution != "" {
+		// Тесты генератора и проверка эталонного решения выполняются так же, как при создании задачи
+		if pkg.Generator != "" {
+			generatorArgs, err := json.Marshal(pkg.Metadata.GeneratorArgs)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка сериализации аргументов генератора: %w", err)
+			}
+			problem.GeneratorArgs = string(generatorArgs)
+		}
+		testCases, err := s.prepareTestCases(testCasesJSON, pkg.Solution, pkg.Generator, pkg.Metadata.GeneratorArgs, pkg.Checker, pkg.Interactor, problem.TimeLimit)
+		if err != nil {
+			return nil, err
+		}
+		problem.TestCases = testCases
+		if prepared, err := s.sandbox.ParseTestCases(testCases); err == nil {
+			report.TestsCount = len(prepared)
+		}
+		report.Validated = true
+	} else {
+		if pkg.Generator != "" {
+			return nil, errors.New("для генератора тестов необходимо эталонное решение")
+		}
+		report.Warnings = append(report.Warnings, "эталонное решение отсутствует, задача сохранена как черновик")
+	}
+
+	if err := s.checkTestGroups(problem); err != nil {
+		return nil, err
+	}
+
+	problem.IsActive = publish && report.Validated
+	report.Published = problem.IsActive
+
+	// IsActive по умолчанию true, поэтому черновик сохраняем явным обновлением
+	if err := s.db.Create(problem).Error; err != nil {
+		return nil, fmt.Errorf("ошибка создания задачи: %w", err)
+	}
+	if !problem.IsActive {
+		if err := s.db.Model(problem).Update("is_active", false).Error; err != nil {
+			return nil, fmt.Errorf("ошибка сохранения черновика задачи: %w", err)
+		}
+	}
+
+	report.Problem = problem
+	return report, nil
+}
+
+// ExportProblemPackage выгружает задачу в zip-архив указанного формата
+func (s *ProblemService) ExportProblemPackage(problemID uint, format string) ([]byte, error) {
+	var problem models.Problem
+	if err := s.db.First(&problem, problemID).Error; err != nil {
+		return nil, errors.New("задача не найдена")
+	}
+
+	tests, err := s.sandbox.ParseTestCases(problem.TestCases)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := ProblemPackageMetadata{
+		Difficulty:  string(problem.Difficulty),
+		Points:      problem.Points,
+		TimeLimit:   problem.TimeLimit,
+		MemoryLimit: problem.MemoryLimit,
+	}
+
+	files := map[string]string{}
+	switch format {
+	case "", PackageFormatNative:
+		meta.Title = problem.Title
+		files["statement.md"] = problem.Description
+		files["initial.go"] = problem.InitialCode
+		files["solution.go"] = problem.ReferenceSolution
+		files["checker.go"] = problem.Checker
+		files["interactor.go"] = problem.Interactor
+		files["generator.go"] = problem.Generator
+		if problem.GeneratorArgs != "" {
+			if err := json.Unmarshal([]byte(problem.GeneratorArgs), &meta.GeneratorArgs); err != nil {
+				return nil, fmt.Errorf("ошибка чтения аргументов генератора: %w", err)
+			}
+		}
+		groups, err := ParseTestGroups(problem.TestGroups)
+		if err != nil {
+			return nil, err
+		}
+		meta.TestGroups = groups
+		constraints, err := ParseSolutionConstraints(problem.Constraints)
+		if err != nil {
+			return nil, err
+		}
+		meta.Constraints = constraints
+		for i, tc := range tests {
+			if tc.Generated {
+				continue
+			}
+			name := testFileName(tc, i)
+			if tc.Group != "" {
+				name = tc.Group + "/" + name
+			}
+			files["tests/"+name+".in"] = tc.Input
+			files["tests/"+name+".out"] = tc.Expected
+		}
+	case PackageFormatKattis:
+		if problem.Interactor != "" {
+			return nil, errors.New("экспорт интерактивных задач в формате Kattis не поддерживается")
+		}
+		meta.Name = problem.Title
+		meta.Limits = &ProblemPackageLimits{
+			TimeLimit: float64(problem.TimeLimit),
+			Memory:    problem.MemoryLimit,
+		}
+		files["problem_statement/problem.md"] = problem.Description
+		files["submissions/accepted/solution.go"] = problem.ReferenceSolution
+		for i, tc := range tests {
+			name := testFileName(tc, i)
+			files["data/secret/"+name+".in"] = tc.Input
+			files["data/secret/"+name+".ans"] = tc.Expected
+		}
+	default:
+		return nil, fmt.Errorf("неподдерживаемый формат экспорта: %s", format)
+	}
+
+	metaYAML, err := yaml.Marshal(&meta)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка формирования problem.yaml: %w", err)
+	}
+	files["problem.yaml"] = string(metaYAML)
+
+	return writeZip(files)
+}
+
+// ParseProblemPackage разбирает zip-архив пакета задачи, определяя его формат
+func ParseProblemPackage(data []byte) (*ProblemPackage, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения архива: %w", err)
+	}
+
+	if len(reader.File) > maxPackageFiles {
+		return nil, fmt.Errorf("в пакете больше %d файлов", maxPackageFiles)
+	}
+
+	files := make(map[string]string)
+	totalSize := 0
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		content, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		totalSize += len(content)
+		if totalSize > maxPackageTotalSize {
+			return nil, fmt.Errorf("распакованный пакет больше %d МБ", maxPackageTotalSize>>20)
+		}
+		files[path.Clean(f.Name)] = content
+	}
+	files = stripCommonRoot(files)
+
+	var pkg *ProblemPackage
+	switch {
+	case hasFile(files, "problem.xml"):
+		pkg, err = parsePolygonPackage(files)
+	case hasPrefix(files, "data/"):
+		pkg, err = parseKattisPackage(files)
+	case hasFile(files, "problem.yaml"):
+		pkg, err = parseNativePackage(files)
+	default:
+		return nil, errors.New("не удалось определить формат пакета: нет problem.yaml или problem.xml")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkg.Tests) == 0 && pkg.Generator == "" {
+		return nil, errors.New("пакет не содержит тестов")
+	}
+	return pkg, nil
+}
+
+func parseNativePackage(files map[string]string) (*ProblemPackage, error) {
+	pkg := &ProblemPackage{Format: PackageFormatNative}
+	if err := yaml.Unmarshal([]byte(files["problem.yaml"]), &pkg.Metadata); err != nil {
+		return nil, fmt.Errorf("ошибка разбора problem.yaml: %w", err)
+	}
+
+	pkg.Statement = files["statement.md"]
+	pkg.InitialCode = files["initial.go"]
+	pkg.Solution = files["solution.go"]
+	pkg.Checker = files["checker.go"]
+	pkg.Interactor = files["interactor.go"]
+	pkg.Generator = files["generator.go"]
+
+	tests, err := collectTestPairs(files, "tests/", ".in", ".out")
+	if err != nil {
+		return nil, err
+	}
+	if len(pkg.Metadata.TestGroups) > 0 {
+		for i := range tests {
+			if group, name, found := strings.Cut(tests[i].Name, "/"); found {
+				tests[i].Group = group
+				tests[i].Name = name
+			}
+		}
+	}
+	pkg.Tests = tests
+	return pkg, nil
+}
+
+func parseKattisPackage(files map[string]string) (*ProblemPackage, error) {
+	pkg := &ProblemPackage{Format: PackageFormatKattis}
+	if content, ok := files["problem.yaml"]; ok {
+		if err := yaml.Unmarshal([]byte(content), &pkg.Metadata); err != nil {
+			return nil, fmt.Errorf("ошибка разбора problem.yaml: %w", err)
+		}
+	}
+
+	for _, name := range []string{"problem.md", "problem.ru.md", "problem.en.md", "problem.tex", "problem.en.tex"} {
+		if content, ok := files["problem_statement/"+name]; ok {
+			pkg.Statement = content
+			break
+		}
+	}
+	if pkg.Statement == "" {
+		pkg.Warnings = append(pkg.Warnings, "условие задачи не найдено в problem_statement/")
+	}
+
+	// Примеры идут первыми, затем секретные тесты
+	samples, err := collectTestPairs(files, "data/sample/", ".in", ".ans")
+	if err != nil {
+		return nil, err
+	}
+	secret, err := collectTestPairs(files, "data/secret/", ".in", ".ans")
+	if err != nil {
+		return nil, err
+	}
+	pkg.Tests = append(samples, secret...)
+
+	pkg.Solution = firstFileWithExt(files, "submissions/accepted/", ".go")
+	if pkg.Solution == "" && hasPrefix(files, "submissions/accepted/") {
+		pkg.Warnings = append(pkg.Warnings, "принятые решения не на Go пропущены")
+	}
+	if hasPrefix(files, "output_validators/") {
+		pkg.Warnings = append(pkg.Warnings, "output_validators не поддерживаются, используется построчное сравнение вывода")
+	}
+	return pkg, nil
+}
+
+// polygonProblem подмножество problem.xml из пакетов Polygon
+type polygonProblem struct {
+	Names []struct {
+		Language string `xml:"language,attr"`
+		Value    string `xml:"value,attr"`
+	} `xml:"names>name"`
+	Testsets []struct {
+		Name        string `xml:"name,attr"`
+		TimeLimit   int    `xml:"time-limit"`   // в миллисекундах
+		MemoryLimit int64  `xml:"memory-limit"` // в байтах
+	} `xml:"judging>testset"`
+	Checker struct {
+		Source struct {
+			Path string `xml:"path,attr"`
+		} `xml:"source"`
+	} `xml:"assets>checker"`
+	Interactor struct {
+		Source struct {
+			Path string `xml:"path,attr"`
+		} `xml:"source"`
+	} `xml:"assets>interactor"`
+	Solutions []struct {
+		Tag    string `xml:"tag,attr"`
+		Source struct {
+			Path string `xml:"path,attr"`
+		} `xml:"source"`
+	} `xml:"assets>solutions>solution"`
+}
+
+func parsePolygonPackage(files map[string]string) (*ProblemPackage, error) {
+	var desc polygonProblem
+	if err := xml.Unmarshal([]byte(files["problem.xml"]), &desc); err != nil {
+		return nil, fmt.Errorf("ошибка разбора problem.xml: %w", err)
+	}
+
+	pkg := &ProblemPackage{Format: PackageFormatPolygon}
+	for _, name := range desc.Names {
+		if pkg.Metadata.Title == "" || name.Language == "russian" {
+			pkg.Metadata.Title = name.Value
+		}
+	}
+	for _, ts := range desc.Testsets {
+		if ts.Name == "tests" {
+			pkg.Metadata.TimeLimit = int(math.Ceil(float64(ts.TimeLimit) / 1000))
+			pkg.Metadata.MemoryLimit = int(ts.MemoryLimit >> 20)
+		}
+	}
+
+	for _, lang := range []string{"russian", "english"} {
+		if legend, ok := files["statement-sections/"+lang+"/legend.tex"]; ok {
+			pkg.Statement = strings.Join([]string{
+				legend,
+				files["statement-sections/"+lang+"/input.tex"],
+				files["statement-sections/"+lang+"/output.tex"],
+			}, "\n\n")
+			break
+		}
+	}
+
+	for _, sol := range desc.Solutions {
+		if sol.Tag == "main" {
+			if strings.HasSuffix(sol.Source.Path, ".go") {
+				pkg.Solution = files[sol.Source.Path]
+			} else {
+				pkg.Warnings = append(pkg.Warnings, "основное решение не на Go пропущено: "+sol.Source.Path)
+			}
+		}
+	}
+	if checker := desc.Checker.Source.Path; checker != "" {
+		if strings.HasSuffix(checker, ".go") {
+			pkg.Checker = files[checker]
+		} else {
+			pkg.Warnings = append(pkg.Warnings, "чекер не на Go пропущен, используется построчное сравнение: "+checker)
+		}
+	}
+	if interactor := desc.Interactor.Source.Path; interactor != "" {
+		if strings.HasSuffix(interactor, ".go") {
+			pkg.Interactor = files[interactor]
+		} else {
+			return nil, errors.New("интерактор не на Go не поддерживается: " + interactor)
+		}
+	}
+
+	// Тесты Polygon: tests/01 — вход, tests/01.a — ответ
+	var names []string
+	for name := range files {
+		if strings.HasPrefix(name, "tests/") && path.Ext(name) == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		answer, ok := files[name+".a"]
+		if !ok {
+			return nil, fmt.Errorf("для теста %s нет файла ответа %s.a", name, name)
+		}
+		pkg.Tests = append(pkg.Tests, TestCase{
+			Name:     path.Base(name),
+			Input:    files[name],
+			Expected: answer,
+		})
+	}
+	return pkg, nil
+}
+
+// collectTestPairs собирает пары файлов <name><inExt>/<name><outExt> из директории dir
+func collectTestPairs(files map[string]string, dir, inExt, outExt string) ([]TestCase, error) {
+	var names []string
+	for name := range files {
+		if strings.HasPrefix(name, dir) && strings.HasSuffix(name, inExt) {
+			names = append(names, strings.TrimSuffix(name, inExt))
+		}
+	}
+	sort.Strings(names)
+
+	tests := make([]TestCase, 0, len(names))
+	for _, name := range names {
+		expected, ok := files[name+outExt]
+		if !ok {
+			return nil, fmt.Errorf("для теста %s нет файла %s", name+inExt, name+outExt)
+		}
+		tests = append(tests, TestCase{
+			Name:     strings.TrimPrefix(name, dir),
+			Input:    files[name+inExt],
+			Expected: expected,
+		})
+	}
+	return tests, nil
+}
+
+func (m ProblemPackageMetadata) title() string {
+	if m.Title != "" {
+		return m.Title
+	}
+	return m.Name
+}
+
+func (m ProblemPackageMetadata) timeLimit() int {
+	if m.TimeLimit > 0 {
+		return m.TimeLimit
+	}
+	if m.Limits != nil && m.Limits.TimeLimit > 0 {
+		return int(math.Ceil(m.Limits.TimeLimit))
+	}
+	return 5
+}
+
+func (m ProblemPackageMetadata) memoryLimit() int {
+	if m.MemoryLimit > 0 {
+		return m.MemoryLimit
+	}
+	if m.Limits != nil && m.Limits.Memory > 0 {
+		return m.Limits.Memory
+	}
+	return 128
+}
+
+func readZipFile(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("ошибка чтения %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(io.LimitReader(rc, maxPackageFileSize+1))
+	if err != nil {
+		return "", fmt.Errorf("ошибка чтения %s: %w", f.Name, err)
+	}
+	if len(content) > maxPackageFileSize {
+		return "", fmt.Errorf("файл %s слишком большой", f.Name)
+	}
+	return string(content), nil
+}
+
+func writeZip(files map[string]string) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name, content := range files {
+		if content != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка формирования архива: %w", err)
+		}
+		if _, err := io.WriteString(w, files[name]); err != nil {
+			return nil, fmt.Errorf("ошибка формирования архива: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("ошибка формирования архива: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// stripCommonRoot убирает общую корневую директорию, если архив был упакован вместе с ней
+func stripCommonRoot(files map[string]string) map[string]string {
+	root := ""
+	for name := range files {
+		first, _, found := strings.Cut(name, "/")
+		if !found || (root != "" && root != first) {
+			return files
+		}
+		root = first
+	}
+	if root == "" {
+		return files
+	}
+
+	stripped := make(map[string]string, len(files))
+	for name, content := range files {
+		stripped[strings.TrimPrefix(name, root+"/")] = content
+	}
+	return stripped
+}
+
+func hasFile(files map[string]string, name string) bool {
+	_, ok := files[name]
+	return ok
+}
+
+func hasPrefix(files map[string]string, prefix string) bool {
+	for name := range files {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func firstFileWithExt(files map[string]string, dir, ext string) string {
+	var names []string
+	for name := range files {
+		if strings.HasPrefix(name, dir) && strings.HasSuffix(name, ext) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return files[names[0]]
+}
+
+// testFileName формирует уникальное имя файла теста с сохранением порядка
+func testFileName(tc TestCase, index int) string {
+	name := fmt.Sprintf("%03d", index+1)
+	if tc.Name != "" && !strings.ContainsAny(tc.Name, "/\\. ") {
+		name += "-" + tc.Name
+	}
+	return name
+}
+
+func encodeTestCases(tests []TestCase) (string, error) {
+	data, err := json.Marshal(tests)
+	if err != nil {
+		return "", fmt.Errorf("ошибка сериализации тест-кейсов: %w", err)
+	}
+	return string(data), nil
+}
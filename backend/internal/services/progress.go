@@ -70,12 +70,12 @@ func (s *ProgressService) GetUserStatistics(userID uint) (*UserStatsResponse, er
 	s.db.Model(&models.Problem{}).Where("is_active = ?", true).Count(&totalProblems)
 	stats.TotalProblems = int(totalProblems)
 	
-	// Пройденные тесты
-	var passedTests int64
-	s.db.Model(&models.UserTestResult{}).
-		Where("user_id = ? AND is_passed = ?", userID, true).
-		Count(&passedTests)
-	stats.PassedTests = int(passedTests)
+	// Пройденные тесты по политике зачёта каждого теста
+	passedTests, err := countPassedTests(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	stats.PassedTests = passedTests
 	
 	// Общее количество тестов
 	var totalTests int64
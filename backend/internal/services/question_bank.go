@@ -0,0 +1,337 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// maxBankQuestions ограничивает размер одного банка вопросов
+const maxBankQuestions = 2000
+
+type CreateQuestionBankRequest struct {
+	Title       string                `json:"title" binding:"required,min=2,max=200"`
+	Description string                `json:"description"`
+	IsPlacement bool                  `json:"is_placement"`
+	Questions   []TestQuestionRequest `json:"questions" binding:"dive"`
+}
+
+type UpdateQuestionBankRequest struct {
+	Title       string  `json:"title" binding:"omitempty,min=2,max=200"`
+	Description *string `json:"description"`
+	IsPlacement *bool   `json:"is_placement"`
+	// Если передан, заменяет вопросы банка по тем же правилам, что и у теста
+	Questions []TestQuestionRequest `json:"questions" binding:"omitempty,dive"`
+}
+
+// QuestionBankView банк вопросов в списке
+type QuestionBankView struct {
+	models.QuestionBank
+	QuestionsCount int64 `json:"questions_count"`
+}
+
+// GetQuestionBanks возвращает банки вопросов с числом вопросов в каждом
+func (s *TestService) GetQuestionBanks() ([]QuestionBankView, error) {
+	var banks []models.QuestionBank
+	if err := s.db.Order("title ASC").Find(&banks).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения банков вопросов: %w", err)
+	}
+
+	var counts []struct {
+		BankID uint
+		Count  int64
+	}
+	if err := s.db.Model(&models.TestQuestion{}).
+		Select("bank_id, COUNT(*) AS count").
+		Where("bank_id IS NOT NULL").
+		Group("bank_id").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("ошибка подсчёта вопросов: %w", err)
+	}
+	byBank := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		byBank[count.BankID] = count.Count
+	}
+
+	views := make([]QuestionBankView, 0, len(banks))
+	for _, bank := range banks {
+		views = append(views, QuestionBankView{QuestionBank: bank, QuestionsCount: byBank[bank.ID]})
+	}
+	return views, nil
+}
+
+// GetQuestionBank возвращает банк с вопросами и верными ответами
+func (s *TestService) GetQuestionBank(id uint) (*models.QuestionBank, error) {
+	var bank models.QuestionBank
+	if err := s.db.First(&bank, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("банк вопросов не найден")
+		}
+		return nil, fmt.Errorf("ошибка получения банка вопросов: %w", err)
+	}
+
+	if err := s.db.Preload("Answers", func(db *gorm.DB) *gorm.DB {
+		return db.Order(`"order" ASC, id ASC`)
+	}).
+		Where("bank_id = ?", id).
+		Order(`"order" ASC, id ASC`).
+		Find(&bank.Questions).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения вопросов: %w", err)
+	}
+	return &bank, nil
+}
+
+// CreateQuestionBank создаёт банк вместе с вопросами
+func (s *TestService) CreateQuestionBank(req *CreateQuestionBankRequest) (*models.QuestionBank, error) {
+	if len(req.Questions) > maxBankQuestions {
+		return nil, fmt.Errorf("в банке может быть не больше %d вопросов", maxBankQuestions)
+	}
+	questions, err := s.prepareTestQuestions(req.Questions, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	bank := &models.QuestionBank{Title: req.Title, Description: req.Description, IsPlacement: req.IsPlacement}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(bank).Error; err != nil {
+			return fmt.Errorf("ошибка создания банка вопросов: %w", err)
+		}
+		return saveTestQuestions(tx, nil, &bank.ID, questions, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetQuestionBank(bank.ID)
+}
+
+// UpdateQuestionBank обновляет банк и, если переданы, его вопросы. Начатые
+// попытки продолжают работать со своими билетами
+func (s *TestService) UpdateQuestionBank(id uint, req *UpdateQuestionBankRequest) (*models.QuestionBank, error) {
+	bank, err := s.GetQuestionBank(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var questions []models.TestQuestion
+	if req.Questions != nil {
+		if len(req.Questions) > maxBankQuestions {
+			return nil, fmt.Errorf("в банке может быть не больше %d вопросов", maxBankQuestions)
+		}
+		if questions, err = s.prepareTestQuestions(req.Questions, bank.Questions); err != nil {
+			return nil, err
+		}
+	}
+
+	updates := map[string]interface{}{}
+	if req.Title != "" {
+		updates["title"] = req.Title
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.IsPlacement != nil {
+		updates["is_placement"] = *req.IsPlacement
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&models.QuestionBank{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+				return fmt.Errorf("ошибка обновления банка вопросов: %w", err)
+			}
+		}
+		if req.Questions == nil {
+			return nil
+		}
+		return saveTestQuestions(tx, nil, &id, questions, bank.Questions)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetQuestionBank(id)
+}
+
+// DeleteQuestionBank удаляет банк с вопросами. Банк, на который ссылаются
+// правила действующих тестов, удалить нельзя
+func (s *TestService) DeleteQuestionBank(id uint) error {
+	var used int64
+	if err := s.db.Model(&models.TestRule{}).
+		Joins("JOIN tests ON tests.id = test_rules.test_id AND tests.deleted_at IS NULL").
+		Where("test_rules.bank_id = ?", id).
+		Count(&used).Error; err != nil {
+		return fmt.Errorf("ошибка проверки использования банка: %w", err)
+	}
+	if used > 0 {
+		return errors.New("банк вопросов используется в тестах")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bank_id = ?", id).Delete(&models.TestRule{}).Error; err != nil {
+			return fmt.Errorf("ошибка удаления правил: %w", err)
+		}
+		if err := tx.Where("question_id IN (?)",
+			tx.Model(&models.TestQuestion{}).Select("id").Where("bank_id = ?", id)).
+			Delete(&models.TestAnswer{}).Error; err != nil {
+			return fmt.Errorf("ошибка удаления вариантов ответа: %w", err)
+		}
+		if err := tx.Where("bank_id = ?", id).Delete(&models.TestQuestion{}).Error; err != nil {
+			return fmt.Errorf("ошибка удаления вопросов: %w", err)
+		}
+		result := tx.Delete(&models.QuestionBank{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("ошибка удаления банка вопросов: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("банк вопросов не найден")
+		}
+		return nil
+	})
+}
+
+// bankQuestionsQuery выбирает вопросы банка, подходящие под тему и сложность правила
+func bankQuestionsQuery(db *gorm.DB, bankID uint, topic string, difficulty models.ProblemLevel) *gorm.DB {
+	query := db.Model(&models.TestQuestion{}).Where("bank_id = ?", bankID)
+	if topic != "" {
+		query = query.Where("topic = ?", topic)
+	}
+	if difficulty != "" {
+		query = query.Where("difficulty = ?", difficulty)
+	}
+	return query
+}
+
+// assemblePaper собирает билет попытки: собственные вопросы теста и случайные
+// вопросы банков по правилам. Выбор и порядок детерминированы зерном попытки.
+// Вопросы, которые пользователь уже видел в прошлых попытках, берутся в
+// последнюю очередь, чтобы пересдача не повторяла билет
+func (s *TestService) assemblePaper(test *models.Test, rules []models.TestRule, userID uint, seed int64) ([]uint, error) {
+	rng := rand.New(rand.NewSource(seed))
+
+	paper := make([]uint, 0, len(test.Questions))
+	picked := make(map[uint]bool)
+	for _, question := range test.Questions {
+		paper = append(paper, question.ID)
+		picked[question.ID] = true
+	}
+
+	if len(rules) > 0 {
+		seen, err := s.seenQuestions(test.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rule := range rules {
+			var candidates []uint
+			if err := bankQuestionsQuery(s.db, rule.BankID, rule.Topic, rule.Difficulty).
+				Order("id ASC").
+				Pluck("id", &candidates).Error; err != nil {
+				return nil, fmt.Errorf("ошибка выбора вопросов из банка: %w", err)
+			}
+
+			available := candidates[:0]
+			for _, id := range candidates {
+				if !picked[id] {
+					available = append(available, id)
+				}
+			}
+			rng.Shuffle(len(available), func(i, j int) {
+				available[i], available[j] = available[j], available[i]
+			})
+			sort.SliceStable(available, func(i, j int) bool {
+				return !seen[available[i]] && seen[available[j]]
+			})
+
+			if len(available) > rule.Count {
+				available = available[:rule.Count]
+			}
+			for _, id := range available {
+				paper = append(paper, id)
+				picked[id] = true
+			}
+		}
+	}
+
+	if test.ShuffleQuestions || len(rules) > 0 {
+		rng.Shuffle(len(paper), func(i, j int) {
+			paper[i], paper[j] = paper[j], paper[i]
+		})
+	}
+	return paper, nil
+}
+
+// seenQuestions возвращает вопросы из прошлых билетов пользователя по тесту
+func (s *TestService) seenQuestions(testID, userID uint) (map[uint]bool, error) {
+	var papers []string
+	if err := s.db.Model(&models.UserTestResult{}).
+		Where("test_id = ? AND user_id = ?", testID, userID).
+		Pluck("question_ids", &papers).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения прошлых попыток: %w", err)
+	}
+
+	seen := make(map[uint]bool)
+	for _, paper := range papers {
+		var ids []uint
+		_ = json.Unmarshal([]byte(paper), &ids)
+		for _, id := range ids {
+			seen[id] = true
+		}
+	}
+	return seen, nil
+}
+
+// attemptQuestions загружает вопросы билета попытки в порядке показа.
+// Для попыток без сохранённого билета берутся текущие вопросы теста
+func (s *TestService) attemptQuestions(attempt *models.UserTestResult) ([]models.TestQuestion, error) {
+	var ids []uint
+	if attempt.QuestionIDs != "" {
+		if err := json.Unmarshal([]byte(attempt.QuestionIDs), &ids); err != nil {
+			return nil, fmt.Errorf("ошибка чтения билета попытки: %w", err)
+		}
+	}
+	if len(ids) == 0 {
+		return s.loadQuestions(attempt.TestID)
+	}
+
+	// Вопросы и варианты удаляются мягко: попытка видит их такими, какими они
+	// были в момент её начала, даже если их потом удалили
+	var questions []models.TestQuestion
+	if err := s.db.Unscoped().Preload("Answers", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().
+			Where("created_at <= ? AND (deleted_at IS NULL OR deleted_at > ?)", attempt.StartedAt, attempt.StartedAt).
+			Order(`"order" ASC, id ASC`)
+	}).
+		Where("id IN ?", ids).
+		Where("deleted_at IS NULL OR deleted_at > ?", attempt.StartedAt).
+		Find(&questions).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения вопросов: %w", err)
+	}
+
+	// Удалённые до начала попытки вопросы пропускаются
+	byID := make(map[uint]models.TestQuestion, len(questions))
+	for _, question := range questions {
+		byID[question.ID] = question
+	}
+	ordered := make([]models.TestQuestion, 0, len(ids))
+	for _, id := range ids {
+		if question, ok := byID[id]; ok {
+			ordered = append(ordered, question)
+		}
+	}
+	return ordered, nil
+}
+
+// attemptSeed возвращает зерно перемешивания попытки. У попыток, начатых до
+// появления зерна, им служит ID
+func attemptSeed(attempt *models.UserTestResult) int64 {
+	if attempt.Seed != 0 {
+		return attempt.Seed
+	}
+	return int64(attempt.ID)
+}
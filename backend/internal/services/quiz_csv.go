@@ -0,0 +1,239 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"go-education-platform/internal/models"
+)
+
+// Простой CSV-формат: первая строка — заголовок, столбцы ищутся по имени.
+// answers — варианты через «|» («\|» внутри варианта), correct — номера верных
+// вариантов с единицы через «|». Для ordering варианты перечисляются в верном
+// порядке, для matching каждый вариант записывается как «левая -> правая».
+// answer_pattern и case_sensitive — шаблон ответа и учёт регистра вопросов text
+var quizCSVColumns = []string{"type", "question", "answers", "correct", "points", "explanation", "topic", "difficulty", "code", "answer_pattern", "case_sensitive"}
+
+const matchingSeparator = " -> "
+
+// parseQuizCSV разбирает тест в CSV
+func parseQuizCSV(data []byte) (*parsedQuiz, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения заголовка CSV: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["question"]; !ok {
+		return nil, errors.New("в заголовке CSV нет столбца question")
+	}
+
+	quiz := &parsedQuiz{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения CSV: %w", err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if field("question") == "" && field("answers") == "" {
+			continue
+		}
+
+		quiz.next(fmt.Sprintf("строка %d", line))
+		question, ok := csvQuestionRequest(quiz, field)
+		if ok {
+			quiz.add(question)
+		}
+	}
+	return quiz, nil
+}
+
+// csvQuestionRequest собирает вопрос теста из строки CSV
+func csvQuestionRequest(quiz *parsedQuiz, field func(string) string) (TestQuestionRequest, bool) {
+	question := TestQuestionRequest{
+		Type:        models.QuestionType(strings.ToLower(field("type"))),
+		Question:    field("question"),
+		Explanation: field("explanation"),
+		Topic:       field("topic"),
+		Difficulty:  models.ProblemLevel(strings.ToLower(field("difficulty"))),
+		Code:        field("code"),
+	}
+	if question.Type == "" {
+		question.Type = models.QuestionTypeSingleChoice
+	}
+	switch question.Difficulty {
+	case "", models.ProblemLevelEasy, models.ProblemLevelMedium, models.ProblemLevelHard:
+	default:
+		quiz.warnf("неизвестная сложность %q не переносится", question.Difficulty)
+		question.Difficulty = ""
+	}
+	if points := field("points"); points != "" {
+		value, err := strconv.ParseFloat(strings.ReplaceAll(points, ",", "."), 64)
+		if err != nil {
+			quiz.skipf("неверный балл %q", points)
+			return question, false
+		}
+		question.Points = roundPoints(value)
+	}
+
+	answers := splitCSVAnswers(field("answers"))
+	correct := map[int]bool{}
+	if value := field("correct"); value != "" {
+		for _, part := range strings.Split(value, "|") {
+			index, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || index < 1 || index > len(answers) {
+				quiz.skipf("неверный номер верного варианта %q", part)
+				return question, false
+			}
+			correct[index-1] = true
+		}
+	}
+
+	switch question.Type {
+	case models.QuestionTypeSingleChoice, models.QuestionTypeMultipleChoice:
+		for i, answer := range answers {
+			question.Answers = append(question.Answers, TestAnswerRequest{Answer: answer, IsCorrect: correct[i]})
+		}
+	case models.QuestionTypeText, models.QuestionTypeCodeOutput, models.QuestionTypeOrdering:
+		// Вывод фрагмента code_output вычисляется при сохранении
+		if question.Type == models.QuestionTypeCodeOutput && len(answers) > 0 {
+			quiz.warnf("ответы к вопросу на вывод кода не переносятся, вывод вычисляется заново")
+			answers = nil
+		}
+		for _, answer := range answers {
+			question.Answers = append(question.Answers, TestAnswerRequest{Answer: answer})
+		}
+		if question.Type == models.QuestionTypeText {
+			question.AnswerPattern = field("answer_pattern")
+			if value := field("case_sensitive"); value != "" {
+				caseSensitive, err := strconv.ParseBool(value)
+				if err != nil {
+					quiz.warnf("неверное значение case_sensitive %q не переносится", value)
+				}
+				question.CaseSensitive = caseSensitive
+			}
+		}
+	case models.QuestionTypeMatching:
+		for _, answer := range answers {
+			left, right, ok := strings.Cut(answer, strings.TrimSpace(matchingSeparator))
+			if !ok {
+				quiz.skipf("пара %q записана без «->»", answer)
+				return question, false
+			}
+			question.Answers = append(question.Answers, TestAnswerRequest{
+				Answer:    strings.TrimSpace(left),
+				MatchText: strings.TrimSpace(right),
+			})
+		}
+	case models.QuestionTypeCode:
+		quiz.skipf("вопросы с решением задачи не импортируются")
+		return question, false
+	default:
+		quiz.skipf("тип %q не поддерживается", question.Type)
+		return question, false
+	}
+	return question, true
+}
+
+// splitCSVAnswers делит список вариантов по «|», не трогая «\|»
+func splitCSVAnswers(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var answers []string
+	var current strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) && value[i+1] == '|' {
+			current.WriteByte('|')
+			i++
+			continue
+		}
+		if value[i] == '|' {
+			answers = append(answers, strings.TrimSpace(current.String()))
+			current.Reset()
+			continue
+		}
+		current.WriteByte(value[i])
+	}
+	return append(answers, strings.TrimSpace(current.String()))
+}
+
+// writeQuizCSV выгружает тест в CSV. Вопросы с решением задачи пропускаются
+func writeQuizCSV(test *models.Test) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(quizCSVColumns); err != nil {
+		return nil, err
+	}
+
+	escape := strings.NewReplacer("|", `\|`)
+	for i := range test.Questions {
+		question := &test.Questions[i]
+		if question.Type == models.QuestionTypeCode {
+			continue
+		}
+
+		answers := make([]string, 0, len(question.Answers))
+		var correct []string
+		for j, answer := range question.Answers {
+			text := answer.Answer
+			if question.Type == models.QuestionTypeMatching {
+				text += matchingSeparator + answer.MatchText
+			}
+			answers = append(answers, escape.Replace(text))
+			if answer.IsCorrect {
+				correct = append(correct, strconv.Itoa(j+1))
+			}
+		}
+		if question.Type == models.QuestionTypeCodeOutput {
+			answers = nil
+		}
+
+		record := []string{
+			string(question.Type),
+			question.Question,
+			strings.Join(answers, "|"),
+			strings.Join(correct, "|"),
+			strconv.Itoa(question.Points),
+			question.Explanation,
+			question.Topic,
+			string(question.Difficulty),
+			question.Code,
+			"",
+			"",
+		}
+		if question.Type == models.QuestionTypeText {
+			record[9] = question.AnswerPattern
+			if question.CaseSensitive {
+				record[10] = "true"
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("ошибка формирования CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
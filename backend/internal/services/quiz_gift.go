@@ -0,0 +1,450 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go-education-platform/internal/models"
+)
+
+// Формат GIFT (Moodle): вопросы разделяются пустой строкой, ответы — в
+// фигурных скобках. Поддерживаются выбор одного и нескольких вариантов,
+// «верно/неверно», короткий ответ, точный числовой ответ, сопоставление и
+// вопрос с пропуском. Эссе, числовые диапазоны и отзывы к вариантам не
+// переносятся
+
+// giftOption вариант из блока ответов GIFT
+type giftOption struct {
+	correct  bool     // вариант отмечен «=»
+	weight   *float64 // вес в процентах из %n%
+	text     string
+	match    string // пара для сопоставления после «->»
+	feedback bool   // у варианта был отзыв после «#»
+}
+
+// parseGIFT разбирает файл GIFT
+func parseGIFT(data string) *parsedQuiz {
+	quiz := &parsedQuiz{}
+	topic := ""
+	for _, block := range splitGIFTBlocks(strings.ReplaceAll(data, "\r\n", "\n")) {
+		if strings.HasPrefix(block, "$CATEGORY:") {
+			line, rest, _ := strings.Cut(block, "\n")
+			topic = categoryTopic(strings.TrimPrefix(line, "$CATEGORY:"))
+			if block = strings.TrimSpace(rest); block == "" {
+				continue
+			}
+		}
+		parseGIFTQuestion(quiz, block, topic)
+	}
+	return quiz
+}
+
+// splitGIFTBlocks делит файл на вопросы по пустым строкам вне блока ответов,
+// отбрасывая комментарии
+func splitGIFTBlocks(data string) []string {
+	var blocks, current []string
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(current, "\n")); text != "" {
+			blocks = append(blocks, text)
+		}
+		current = nil
+	}
+
+	depth := 0
+	for _, line := range strings.Split(data, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if depth == 0 && strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		if depth == 0 && trimmed == "" {
+			flush()
+			continue
+		}
+		current = append(current, line)
+		for i := 0; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '{':
+				depth++
+			case '}':
+				if depth > 0 {
+					depth--
+				}
+			}
+		}
+	}
+	flush()
+	return blocks
+}
+
+func parseGIFTQuestion(quiz *parsedQuiz, block, topic string) {
+	text, name := block, ""
+	if strings.HasPrefix(text, "::") {
+		if end := indexUnescaped(text, "::", 2); end >= 0 {
+			name = giftUnescape(strings.TrimSpace(text[2:end]))
+			text = strings.TrimSpace(text[end+2:])
+		}
+	}
+	quiz.next(name)
+
+	isHTML := false
+	if strings.HasPrefix(text, "[") {
+		if end := strings.Index(text, "]"); end > 0 {
+			switch strings.ToLower(text[1:end]) {
+			case "html":
+				isHTML = true
+				text = text[end+1:]
+			case "markdown", "plain", "moodle":
+				text = text[end+1:]
+			}
+		}
+	}
+
+	open := indexUnescaped(text, "{", 0)
+	if open < 0 {
+		quiz.skipf("нет блока ответов: описания не импортируются")
+		return
+	}
+	closing := indexUnescaped(text, "}", open+1)
+	if closing < 0 {
+		quiz.skipf("не закрыт блок ответов")
+		return
+	}
+
+	questionText := strings.TrimSpace(text[:open])
+	if after := strings.TrimSpace(text[closing+1:]); after != "" {
+		questionText = strings.TrimSpace(questionText + " _____ " + after)
+	}
+	if isHTML && hasEmbeddedFiles(questionText) {
+		quiz.warnf("изображения и файлы не переносятся")
+	}
+	questionText = giftText(questionText, isHTML)
+
+	body := strings.TrimSpace(text[open+1 : closing])
+	explanation := ""
+	if i := indexUnescaped(body, "####", 0); i >= 0 {
+		explanation = giftText(strings.TrimSpace(body[i+4:]), isHTML)
+		body = strings.TrimSpace(body[:i])
+	}
+
+	var question TestQuestionRequest
+	switch {
+	case body == "":
+		quiz.skipf("эссе не проверяется автоматически")
+		return
+	case strings.HasPrefix(body, "#"):
+		value, ok := giftNumericAnswer(body[1:])
+		if !ok {
+			quiz.skipf("числовые ответы с погрешностью или диапазоном не поддерживаются")
+			return
+		}
+		question = TestQuestionRequest{
+			Type:    models.QuestionTypeText,
+			Answers: []TestAnswerRequest{{Answer: value}},
+		}
+	default:
+		value, feedback := cutUnescaped(body, "#")
+		switch strings.ToUpper(strings.TrimSpace(value)) {
+		case "T", "TRUE":
+			question = trueFalseQuestion("", true)
+		case "F", "FALSE":
+			question = trueFalseQuestion("", false)
+		default:
+			var ok bool
+			if question, ok = giftOptionsQuestion(quiz, parseGIFTOptions(body), isHTML); !ok {
+				return
+			}
+			feedback = ""
+		}
+		if feedback != "" {
+			quiz.warnf("отзывы к ответам не переносятся")
+		}
+	}
+
+	question.Question = questionText
+	question.Explanation = explanation
+	question.Topic = topic
+	quiz.add(question)
+}
+
+// giftOptionsQuestion собирает вопрос из вариантов: сопоставление, короткий
+// ответ или выбор
+func giftOptionsQuestion(quiz *parsedQuiz, options []giftOption, isHTML bool) (TestQuestionRequest, bool) {
+	question := TestQuestionRequest{}
+	if len(options) == 0 {
+		quiz.skipf("не удалось разобрать варианты ответа")
+		return question, false
+	}
+
+	hasWrong, hasMatch, hasFeedback := false, false, false
+	for _, option := range options {
+		hasWrong = hasWrong || !option.correct
+		hasMatch = hasMatch || option.match != ""
+		hasFeedback = hasFeedback || option.feedback
+	}
+	if hasFeedback {
+		quiz.warnf("отзывы к вариантам не переносятся")
+	}
+
+	switch {
+	case hasMatch:
+		question.Type = models.QuestionTypeMatching
+		for _, option := range options {
+			if option.text == "" {
+				quiz.warnf("лишний вариант пары %q без вопроса не переносится", option.match)
+				continue
+			}
+			question.Answers = append(question.Answers, TestAnswerRequest{
+				Answer:    giftText(option.text, isHTML),
+				MatchText: giftText(option.match, isHTML),
+			})
+		}
+	case !hasWrong:
+		question.Type = models.QuestionTypeText
+		for _, option := range options {
+			if option.weight != nil && *option.weight < 100 {
+				quiz.warnf("ответ %q с частичным баллом не переносится", option.text)
+				continue
+			}
+			question.Answers = append(question.Answers, TestAnswerRequest{Answer: giftText(option.text, isHTML)})
+		}
+	default:
+		// Верный вариант, отмеченный «~» с положительным весом, а не «=»,
+		// означает выбор нескольких ответов, даже если верный вариант один
+		weights := make([]float64, 0, len(options))
+		multiple := false
+		for _, option := range options {
+			weight := giftOptionWeight(option)
+			multiple = multiple || (!option.correct && weight > 0)
+			weights = append(weights, weight)
+			question.Answers = append(question.Answers, TestAnswerRequest{
+				Answer:    giftText(option.text, isHTML),
+				IsCorrect: weight > 0,
+			})
+		}
+		question.Type = choiceType(quiz, weights, multiple)
+	}
+	return question, true
+}
+
+// parseGIFTOptions делит блок ответов на варианты, начинающиеся с «=» или «~»
+func parseGIFTOptions(body string) []giftOption {
+	var options []giftOption
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			options = append(options, parseGIFTOption(body[start:end]))
+		}
+	}
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			i++
+		case '=', '~':
+			// «->» в сопоставлении начинается с «-», «=» внутри него не встречается
+			flush(i)
+			start = i
+		}
+	}
+	flush(len(body))
+	return options
+}
+
+func parseGIFTOption(token string) giftOption {
+	option := giftOption{correct: token[0] == '='}
+	rest := strings.TrimSpace(token[1:])
+	if strings.HasPrefix(rest, "%") {
+		if end := strings.Index(rest[1:], "%"); end >= 0 {
+			if weight, err := strconv.ParseFloat(rest[1:end+1], 64); err == nil {
+				option.weight = &weight
+			}
+			rest = strings.TrimSpace(rest[end+2:])
+		}
+	}
+
+	rest, feedback := cutUnescaped(rest, "#")
+	option.feedback = strings.TrimSpace(feedback) != ""
+	if i := indexUnescaped(rest, "->", 0); i >= 0 {
+		option.text = strings.TrimSpace(rest[:i])
+		option.match = strings.TrimSpace(rest[i+2:])
+	} else {
+		option.text = strings.TrimSpace(rest)
+	}
+	return option
+}
+
+func giftOptionWeight(option giftOption) float64 {
+	if option.weight != nil {
+		return *option.weight
+	}
+	if option.correct {
+		return 100
+	}
+	return 0
+}
+
+// giftNumericAnswer принимает только точный числовой ответ: «#5» или «#5:0»
+func giftNumericAnswer(body string) (string, bool) {
+	value, _ := cutUnescaped(body, "#")
+	value = strings.TrimSpace(value)
+	if strings.ContainsAny(value, "=~") || strings.Contains(value, "..") {
+		return "", false
+	}
+	if number, tolerance, ok := strings.Cut(value, ":"); ok {
+		if t, err := strconv.ParseFloat(strings.TrimSpace(tolerance), 64); err != nil || t != 0 {
+			return "", false
+		}
+		value = strings.TrimSpace(number)
+	}
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+// writeGIFT выгружает тест в GIFT. Вопросы на упорядочивание, вывод и код
+// формат выразить не может, о них остаётся комментарий
+func writeGIFT(test *models.Test) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Тест: %s\n", strings.Join(strings.Fields(test.Title), " "))
+	if len(test.Rules) > 0 {
+		b.WriteString("// Вопросы из банков по правилам теста не выгружаются\n")
+	}
+	b.WriteString("\n")
+
+	topic := ""
+	for i := range test.Questions {
+		question := &test.Questions[i]
+		answers, ok := giftAnswers(question)
+		if !ok {
+			fmt.Fprintf(&b, "// Вопрос %d (%s) не поддерживается форматом GIFT\n\n", question.ID, question.Type)
+			continue
+		}
+		if question.Topic != topic {
+			topic = question.Topic
+			fmt.Fprintf(&b, "$CATEGORY: $course$/%s\n\n", topic)
+		}
+		if question.Type == models.QuestionTypeText {
+			for _, loss := range textQuestionLosses(question, false) {
+				fmt.Fprintf(&b, "// Вопрос %d: %s\n", question.ID, loss)
+			}
+		}
+
+		fmt.Fprintf(&b, "::%s::[markdown]%s {\n", giftEscape(quizQuestionName(question)), giftEscape(question.Question))
+		for _, answer := range answers {
+			b.WriteString("\t" + answer + "\n")
+		}
+		if question.Explanation != "" {
+			b.WriteString("\t####" + giftEscape(question.Explanation) + "\n")
+		}
+		b.WriteString("}\n\n")
+	}
+	return []byte(b.String())
+}
+
+// giftAnswers строки блока ответов вопроса
+func giftAnswers(question *models.TestQuestion) ([]string, bool) {
+	var lines []string
+	switch question.Type {
+	case models.QuestionTypeSingleChoice:
+		for _, answer := range question.Answers {
+			marker := "~"
+			if answer.IsCorrect {
+				marker = "="
+			}
+			lines = append(lines, marker+giftEscape(answer.Answer))
+		}
+	case models.QuestionTypeMultipleChoice:
+		weight := multipleChoiceWeight(question)
+		for _, answer := range question.Answers {
+			if answer.IsCorrect {
+				lines = append(lines, "~%"+weight+"%"+giftEscape(answer.Answer))
+			} else {
+				lines = append(lines, "~%-100%"+giftEscape(answer.Answer))
+			}
+		}
+	case models.QuestionTypeText:
+		for _, answer := range question.Answers {
+			lines = append(lines, "="+giftEscape(answer.Answer))
+		}
+	case models.QuestionTypeMatching:
+		for _, answer := range question.Answers {
+			lines = append(lines, "="+giftEscape(answer.Answer)+" -> "+giftEscape(answer.MatchText))
+		}
+	}
+	return lines, len(lines) > 0
+}
+
+// giftText снимает экранирование GIFT и, если текст в HTML, переводит его в простой текст
+func giftText(text string, isHTML bool) string {
+	text = giftUnescape(text)
+	if isHTML {
+		return htmlToText(text)
+	}
+	return strings.TrimSpace(text)
+}
+
+// giftEscape экранирует служебные символы GIFT, в том числе «->», которое
+// иначе превратит вариант в пару для сопоставления
+func giftEscape(text string) string {
+	var b strings.Builder
+	for i, r := range text {
+		switch r {
+		case '-':
+			if strings.HasPrefix(text[i+1:], ">") {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		case '~', '=', '#', '{', '}', ':', '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func giftUnescape(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			i++
+			if text[i] == 'n' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(text[i])
+			}
+			continue
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// indexUnescaped ищет sub, не экранированную обратной косой чертой, начиная с from
+func indexUnescaped(text, sub string, from int) int {
+	for i := from; i < len(text); i++ {
+		if text[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(text[i:], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// cutUnescaped делит текст по первой неэкранированной sep
+func cutUnescaped(text, sep string) (string, string) {
+	if i := indexUnescaped(text, sep, 0); i >= 0 {
+		return text[:i], text[i+len(sep):]
+	}
+	return text, ""
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strings"
+
+	"go-education-platform/internal/models"
+)
+
+// Форматы импорта и экспорта тестов
+const (
+	QuizFormatGIFT      = "gift"
+	QuizFormatMoodleXML = "moodle_xml"
+	QuizFormatCSV       = "csv"
+)
+
+// defaultImportedTestTitle название теста, если его нет ни в запросе, ни в файле
+const defaultImportedTestTitle = "Импортированный тест"
+
+// QuizImportReport результат импорта теста. При пробном импорте тест не
+// создаётся, а в Questions возвращаются вопросы, которые были бы созданы
+type QuizImportReport struct {
+	Format         string                `json:"format"`
+	DryRun         bool                  `json:"dry_run"`
+	QuestionsCount int                   `json:"questions_count"`
+	Skipped        int                   `json:"skipped"`  // вопросы, которые не удалось перенести
+	Warnings       []string              `json:"warnings"` // пропущенные вопросы и конструкции, потерянные при переносе
+	Questions      []TestQuestionRequest `json:"questions,omitempty"`
+	Test           *models.Test          `json:"test,omitempty"`
+}
+
+// parsedQuiz вопросы, разобранные из файла, с предупреждениями разбора
+type parsedQuiz struct {
+	Title     string
+	Questions []TestQuestionRequest
+	Skipped   int
+	Warnings  []string
+
+	labels  []string // подпись каждого вопроса из Questions для предупреждений проверки
+	current string   // подпись разбираемого вопроса
+	count   int      // вопросов в файле, включая пропущенные
+}
+
+// next начинает разбор очередного вопроса файла
+func (q *parsedQuiz) next(name string) {
+	q.count++
+	q.current = fmt.Sprintf("вопрос %d", q.count)
+	if name != "" {
+		q.current += fmt.Sprintf(" (%s)", truncateText(name, 40))
+	}
+}
+
+func (q *parsedQuiz) warnf(format string, args ...interface{}) {
+	q.Warnings = append(q.Warnings, q.current+": "+fmt.Sprintf(format, args...))
+}
+
+func (q *parsedQuiz) skipf(format string, args ...interface{}) {
+	q.Skipped++
+	q.warnf("пропущен, "+format, args...)
+}
+
+func (q *parsedQuiz) add(question TestQuestionRequest) {
+	q.Questions = append(q.Questions, question)
+	q.labels = append(q.labels, q.current)
+}
+
+// ImportTest разбирает тест в формате GIFT, Moodle XML или CSV. Пустой format
+// определяется по содержимому. Вопросы, которые нельзя перенести, пропускаются
+// с предупреждением. Без dryRun создаётся неактивный тест: его стоит
+// просмотреть перед публикацией
+func (s *TestService) ImportTest(data []byte, format, title string, dryRun bool) (*QuizImportReport, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if format == "" {
+		format = detectQuizFormat(data)
+	}
+
+	var quiz *parsedQuiz
+	var err error
+	switch format {
+	case QuizFormatGIFT:
+		quiz = parseGIFT(string(data))
+	case QuizFormatMoodleXML:
+		quiz, err = parseMoodleXML(data)
+	case QuizFormatCSV:
+		quiz, err = parseQuizCSV(data)
+	default:
+		return nil, fmt.Errorf("неизвестный формат %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report := &QuizImportReport{
+		Format:   format,
+		DryRun:   dryRun,
+		Skipped:  quiz.Skipped,
+		Warnings: quiz.Warnings,
+	}
+	questions := make([]TestQuestionRequest, 0, len(quiz.Questions))
+	for i, question := range quiz.Questions {
+		if question.Points == 0 {
+			question.Points = 1
+		}
+		if err := s.validateTestQuestion(&question); err != nil {
+			report.Skipped++
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s: пропущен, %v", quiz.labels[i], err))
+			continue
+		}
+		questions = append(questions, question)
+	}
+	report.QuestionsCount = len(questions)
+	if report.Warnings == nil {
+		report.Warnings = []string{}
+	}
+
+	if len(questions) > maxTestQuestions {
+		return nil, fmt.Errorf("в тесте может быть не больше %d вопросов", maxTestQuestions)
+	}
+	if dryRun {
+		report.Questions = questions
+		return report, nil
+	}
+	if len(questions) == 0 {
+		return nil, errors.New("в файле нет вопросов, которые можно импортировать")
+	}
+
+	if title = strings.TrimSpace(title); title == "" {
+		title = strings.TrimSpace(quiz.Title)
+	}
+	if runes := []rune(title); len(runes) < 2 {
+		title = defaultImportedTestTitle
+	} else if len(runes) > 200 {
+		title = string(runes[:200])
+	}
+	inactive := false
+	test, err := s.CreateTest(&CreateTestRequest{
+		Title:     title,
+		IsActive:  &inactive,
+		Questions: questions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Test = test
+	return report, nil
+}
+
+// ExportTest выгружает собственные вопросы теста в формате GIFT, Moodle XML
+// или CSV. Вопросы, которые формат не может выразить, пропускаются; в GIFT и
+// Moodle XML об этом остаётся комментарий
+func (s *TestService) ExportTest(testID uint, format string) ([]byte, error) {
+	test, err := s.GetTestForAdmin(testID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case QuizFormatGIFT:
+		return writeGIFT(test), nil
+	case QuizFormatMoodleXML:
+		return writeMoodleXML(test)
+	case QuizFormatCSV:
+		return writeQuizCSV(test)
+	default:
+		return nil, fmt.Errorf("неизвестный формат %q", format)
+	}
+}
+
+// detectQuizFormat определяет формат файла по содержимому
+func detectQuizFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		return QuizFormatMoodleXML
+	}
+	firstLine, _, _ := strings.Cut(string(trimmed), "\n")
+	if header := strings.ToLower(firstLine); strings.Contains(header, "question") && strings.Contains(header, "answers") && strings.Contains(header, ",") {
+		return QuizFormatCSV
+	}
+	return QuizFormatGIFT
+}
+
+var (
+	htmlBreakRe = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</li>|</h[1-6]>`)
+	htmlTagRe   = regexp.MustCompile(`<[^>]*>`)
+	blankLineRe = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText переводит HTML из Moodle в простой текст
+func htmlToText(text string) string {
+	text = htmlBreakRe.ReplaceAllString(text, "\n")
+	text = htmlTagRe.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+	return strings.TrimSpace(blankLineRe.ReplaceAllString(text, "\n\n"))
+}
+
+// hasEmbeddedFiles сообщает, что текст ссылается на картинки или файлы Moodle
+func hasEmbeddedFiles(text string) bool {
+	lower := strings.ToLower(text)
+	return strings.Contains(lower, "<img") || strings.Contains(lower, "@@pluginfile@@")
+}
+
+// categoryTopic берёт из пути категории Moodle последний сегмент как тему вопроса
+func categoryTopic(category string) string {
+	category = strings.TrimSpace(category)
+	if i := strings.LastIndex(category, "/"); i >= 0 {
+		category = category[i+1:]
+	}
+	if strings.HasPrefix(category, "$") {
+		return ""
+	}
+	runes := []rune(strings.TrimSpace(category))
+	if len(runes) > 50 {
+		runes = runes[:50]
+	}
+	return string(runes)
+}
+
+// quizQuestionName короткое название вопроса для форматов, где оно обязательно
+func quizQuestionName(question *models.TestQuestion) string {
+	name := strings.Join(strings.Fields(question.Question), " ")
+	if name == "" {
+		return fmt.Sprintf("Вопрос %d", question.ID)
+	}
+	return truncateText(name, 50)
+}
+
+// multipleChoiceWeight вес верного варианта в процентах для форматов Moodle
+func multipleChoiceWeight(question *models.TestQuestion) string {
+	correct := len(correctAnswerIDs(question))
+	if correct == 0 {
+		return "0"
+	}
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.5f", 100/float64(correct)), "0"), ".")
+}
+
+// textQuestionLosses перечисляет настройки вопроса text, которые теряются при
+// экспорте: шаблон ответа, а если формат не хранит регистр (keepsCase) — и
+// учёт регистра
+func textQuestionLosses(question *models.TestQuestion, keepsCase bool) []string {
+	var losses []string
+	if question.AnswerPattern != "" {
+		losses = append(losses, "шаблон ответа не переносится, переносятся только допустимые ответы")
+	}
+	if question.CaseSensitive && !keepsCase {
+		losses = append(losses, "учёт регистра не переносится")
+	}
+	return losses
+}
+
+// trueFalseAnswers варианты, которыми представлен вопрос «верно/неверно»
+var trueFalseAnswers = [2]string{"Верно", "Неверно"}
+
+func trueFalseQuestion(text string, value bool) TestQuestionRequest {
+	return TestQuestionRequest{
+		Type:     models.QuestionTypeSingleChoice,
+		Question: text,
+		Answers: []TestAnswerRequest{
+			{Answer: trueFalseAnswers[0], IsCorrect: value},
+			{Answer: trueFalseAnswers[1], IsCorrect: !value},
+		},
+	}
+}
+
+// choiceType выбирает тип вопроса с вариантами по их весам в процентах. Один
+// вариант на 100% — выбор одного ответа, если файл явно не помечает вопрос как
+// выбор нескольких (multiple), иначе — выбор нескольких. Веса, отличные от
+// равных долей, заменяются правилом оценки платформы
+func choiceType(quiz *parsedQuiz, weights []float64, multiple bool) models.QuestionType {
+	correct, full := 0, 0
+	for _, weight := range weights {
+		if weight > 0 {
+			correct++
+		}
+		if weight >= 100 {
+			full++
+		}
+	}
+	if correct == 1 && full == 1 && !multiple {
+		return models.QuestionTypeSingleChoice
+	}
+
+	if full > 1 {
+		quiz.warnf("несколько вариантов на 100%%: нужно будет выбрать все верные")
+	} else {
+		for _, weight := range weights {
+			if weight > 0 && math.Abs(weight-100/float64(correct)) > 0.01 {
+				quiz.warnf("веса вариантов заменены правилом оценки платформы")
+				break
+			}
+		}
+	}
+	return models.QuestionTypeMultipleChoice
+}
+
+// roundPoints переводит дробный балл вопроса в целый не меньше единицы
+func roundPoints(points float64) int {
+	return int(math.Max(1, math.Round(points)))
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go-education-platform/internal/models"
+)
+
+// Формат Moodle XML. Поддерживаются multichoice, truefalse, shortanswer,
+// numerical с точным ответом, matching и ordering; категории становятся
+// темами вопросов. Остальные типы (essay, cloze, calculated, перетаскивание)
+// пропускаются с предупреждением
+
+type moodleQuiz struct {
+	XMLName   xml.Name         `xml:"quiz"`
+	Comment   string           `xml:",comment"`
+	Questions []moodleQuestion `xml:"question"`
+}
+
+type moodleText struct {
+	Format string `xml:"format,attr,omitempty"`
+	Text   string `xml:"text"`
+}
+
+type moodleAnswer struct {
+	Fraction  string      `xml:"fraction,attr"`
+	Format    string      `xml:"format,attr,omitempty"`
+	Text      string      `xml:"text"`
+	Feedback  *moodleText `xml:"feedback,omitempty"`
+	Tolerance string      `xml:"tolerance,omitempty"`
+}
+
+type moodleSubquestion struct {
+	Format string `xml:"format,attr,omitempty"`
+	Text   string `xml:"text"`
+	Answer struct {
+		Text string `xml:"text"`
+	} `xml:"answer"`
+}
+
+type moodleQuestion struct {
+	Type            string              `xml:"type,attr"`
+	Category        *moodleText         `xml:"category,omitempty"`
+	Name            *moodleText         `xml:"name,omitempty"`
+	QuestionText    *moodleText         `xml:"questiontext,omitempty"`
+	GeneralFeedback *moodleText         `xml:"generalfeedback,omitempty"`
+	DefaultGrade    string              `xml:"defaultgrade,omitempty"`
+	Single          string              `xml:"single,omitempty"`
+	UseCase         string              `xml:"usecase,omitempty"`
+	Answers         []moodleAnswer      `xml:"answer"`
+	Subquestions    []moodleSubquestion `xml:"subquestion"`
+}
+
+// text возвращает текст поля как простой текст
+func (t *moodleText) text() string {
+	if t == nil {
+		return ""
+	}
+	if t.Format == "" || t.Format == "html" {
+		return htmlToText(t.Text)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// parseMoodleXML разбирает экспорт вопросов Moodle
+func parseMoodleXML(data []byte) (*parsedQuiz, error) {
+	var doc moodleQuiz
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("ошибка разбора Moodle XML: %w", err)
+	}
+
+	quiz := &parsedQuiz{}
+	topic := ""
+	for i := range doc.Questions {
+		source := &doc.Questions[i]
+		if source.Type == "category" {
+			if source.Category != nil {
+				topic = categoryTopic(source.Category.Text)
+				if quiz.Title == "" {
+					quiz.Title = topic
+				}
+			}
+			continue
+		}
+
+		name := ""
+		if source.Name != nil {
+			name = strings.TrimSpace(source.Name.Text)
+		}
+		quiz.next(name)
+		question, ok := moodleQuestionRequest(quiz, source)
+		if !ok {
+			continue
+		}
+
+		if source.QuestionText != nil && hasEmbeddedFiles(source.QuestionText.Text) {
+			quiz.warnf("изображения и файлы не переносятся")
+		}
+		question.Question = source.QuestionText.text()
+		question.Explanation = source.GeneralFeedback.text()
+		question.Topic = topic
+		if source.DefaultGrade != "" {
+			if grade, err := strconv.ParseFloat(source.DefaultGrade, 64); err == nil {
+				question.Points = roundPoints(grade)
+				if float64(question.Points) != grade {
+					quiz.warnf("балл %s округлён до %d", source.DefaultGrade, question.Points)
+				}
+			}
+		}
+		quiz.add(question)
+	}
+	return quiz, nil
+}
+
+// moodleQuestionRequest переводит вопрос Moodle в вопрос теста
+func moodleQuestionRequest(quiz *parsedQuiz, source *moodleQuestion) (TestQuestionRequest, bool) {
+	question := TestQuestionRequest{}
+	feedback := false
+	for _, answer := range source.Answers {
+		feedback = feedback || strings.TrimSpace(answer.Feedback.text()) != ""
+	}
+
+	switch source.Type {
+	case "multichoice":
+		weights := make([]float64, 0, len(source.Answers))
+		for _, answer := range source.Answers {
+			weight := moodleFraction(answer.Fraction)
+			weights = append(weights, weight)
+			question.Answers = append(question.Answers, TestAnswerRequest{
+				Answer:    moodleAnswerText(&answer),
+				IsCorrect: weight > 0,
+			})
+		}
+		question.Type = choiceType(quiz, weights, source.Single == "false" || source.Single == "0")
+		if source.Single == "true" || source.Single == "1" {
+			if question.Type != models.QuestionTypeSingleChoice {
+				quiz.warnf("вопрос с одним ответом и несколькими верными вариантами стал вопросом с несколькими ответами")
+			}
+		}
+	case "truefalse":
+		value := false
+		for _, answer := range source.Answers {
+			if moodleFraction(answer.Fraction) >= 100 {
+				value = strings.EqualFold(strings.TrimSpace(answer.Text), "true")
+			}
+		}
+		question = trueFalseQuestion("", value)
+	case "shortanswer":
+		question.Type = models.QuestionTypeText
+		question.CaseSensitive = source.UseCase == "1"
+		var patterns []string
+		for _, answer := range source.Answers {
+			if moodleFraction(answer.Fraction) < 100 {
+				quiz.warnf("ответ %q с частичным баллом не переносится", answer.Text)
+				continue
+			}
+			text := moodleAnswerText(&answer)
+			if strings.Contains(text, "*") {
+				// В Moodle «*» в ответе означает любую последовательность символов
+				parts := strings.Split(text, "*")
+				for i := range parts {
+					parts[i] = regexp.QuoteMeta(parts[i])
+				}
+				patterns = append(patterns, strings.Join(parts, ".*"))
+				continue
+			}
+			question.Answers = append(question.Answers, TestAnswerRequest{Answer: text})
+		}
+		question.AnswerPattern = strings.Join(patterns, "|")
+	case "numerical":
+		question.Type = models.QuestionTypeText
+		for _, answer := range source.Answers {
+			tolerance, _ := strconv.ParseFloat(strings.TrimSpace(answer.Tolerance), 64)
+			if moodleFraction(answer.Fraction) < 100 || tolerance != 0 {
+				quiz.warnf("числовой ответ %q с погрешностью или частичным баллом не переносится", answer.Text)
+				continue
+			}
+			question.Answers = append(question.Answers, TestAnswerRequest{Answer: strings.TrimSpace(answer.Text)})
+		}
+	case "matching":
+		question.Type = models.QuestionTypeMatching
+		for _, sub := range source.Subquestions {
+			text := (&moodleText{Format: sub.Format, Text: sub.Text}).text()
+			if text == "" {
+				quiz.warnf("лишний вариант пары %q без вопроса не переносится", sub.Answer.Text)
+				continue
+			}
+			question.Answers = append(question.Answers, TestAnswerRequest{
+				Answer:    text,
+				MatchText: strings.TrimSpace(sub.Answer.Text),
+			})
+		}
+	case "ordering":
+		question.Type = models.QuestionTypeOrdering
+		for _, answer := range source.Answers {
+			question.Answers = append(question.Answers, TestAnswerRequest{Answer: moodleAnswerText(&answer)})
+		}
+	case "description":
+		quiz.skipf("описания не импортируются")
+		return question, false
+	case "essay":
+		quiz.skipf("эссе не проверяется автоматически")
+		return question, false
+	default:
+		quiz.skipf("тип %q не поддерживается", source.Type)
+		return question, false
+	}
+
+	if feedback {
+		quiz.warnf("отзывы к вариантам не переносятся")
+	}
+	return question, true
+}
+
+func moodleAnswerText(answer *moodleAnswer) string {
+	return (&moodleText{Format: answer.Format, Text: answer.Text}).text()
+}
+
+func moodleFraction(fraction string) float64 {
+	value, _ := strconv.ParseFloat(strings.TrimSpace(fraction), 64)
+	return value
+}
+
+// writeMoodleXML выгружает тест в Moodle XML. Вопросы на вывод и код формат
+// выразить не может, о них остаётся комментарий
+func writeMoodleXML(test *models.Test) ([]byte, error) {
+	doc := moodleQuiz{}
+	var skipped []string
+	if len(test.Rules) > 0 {
+		skipped = append(skipped, "вопросы из банков по правилам теста не выгружаются")
+	}
+
+	topic := ""
+	for i := range test.Questions {
+		question := &test.Questions[i]
+		target, ok := moodleQuestionFrom(question)
+		if !ok {
+			skipped = append(skipped, fmt.Sprintf("вопрос %d (%s) не поддерживается форматом Moodle XML", question.ID, question.Type))
+			continue
+		}
+		if len(doc.Questions) == 0 || question.Topic != topic {
+			topic = question.Topic
+			doc.Questions = append(doc.Questions, moodleQuestion{
+				Type:     "category",
+				Category: &moodleText{Text: "$course$/" + topic},
+			})
+		}
+		doc.Questions = append(doc.Questions, target)
+		if question.Type == models.QuestionTypeText {
+			for _, loss := range textQuestionLosses(question, true) {
+				skipped = append(skipped, fmt.Sprintf("вопрос %d: %s", question.ID, loss))
+			}
+		}
+	}
+	if len(skipped) > 0 {
+		// Двойной дефис в XML-комментарии запрещён
+		doc.Comment = " " + strings.ReplaceAll(strings.Join(skipped, "; "), "--", "- -") + " "
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("ошибка формирования Moodle XML: %w", err)
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// moodleQuestionFrom переводит вопрос теста в вопрос Moodle
+func moodleQuestionFrom(question *models.TestQuestion) (moodleQuestion, bool) {
+	target := moodleQuestion{
+		Name:         &moodleText{Text: quizQuestionName(question)},
+		QuestionText: &moodleText{Format: "markdown", Text: question.Question},
+		DefaultGrade: strconv.Itoa(question.Points),
+	}
+	if question.Explanation != "" {
+		target.GeneralFeedback = &moodleText{Format: "markdown", Text: question.Explanation}
+	}
+
+	switch question.Type {
+	case models.QuestionTypeSingleChoice, models.QuestionTypeMultipleChoice:
+		target.Type = "multichoice"
+		target.Single = strconv.FormatBool(question.Type == models.QuestionTypeSingleChoice)
+		weight := multipleChoiceWeight(question)
+		for _, answer := range question.Answers {
+			fraction := "0"
+			if answer.IsCorrect {
+				fraction = weight
+			} else if question.Type == models.QuestionTypeMultipleChoice {
+				fraction = "-100"
+			}
+			target.Answers = append(target.Answers, moodleAnswer{Fraction: fraction, Format: "plain_text", Text: answer.Answer})
+		}
+	case models.QuestionTypeText:
+		if len(question.Answers) == 0 {
+			return target, false
+		}
+		target.Type = "shortanswer"
+		target.UseCase = "0"
+		if question.CaseSensitive {
+			target.UseCase = "1"
+		}
+		for _, answer := range question.Answers {
+			target.Answers = append(target.Answers, moodleAnswer{Fraction: "100", Format: "plain_text", Text: answer.Answer})
+		}
+	case models.QuestionTypeMatching:
+		target.Type = "matching"
+		for _, answer := range question.Answers {
+			sub := moodleSubquestion{Format: "plain_text", Text: answer.Answer}
+			sub.Answer.Text = answer.MatchText
+			target.Subquestions = append(target.Subquestions, sub)
+		}
+	case models.QuestionTypeOrdering:
+		target.Type = "ordering"
+		for _, answer := range question.Answers {
+			target.Answers = append(target.Answers, moodleAnswer{Fraction: "0", Format: "plain_text", Text: answer.Answer})
+		}
+	default:
+		return target, false
+	}
+	return target, true
+}
@@ -0,0 +1,391 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type ReviewService struct {
+	db       *gorm.DB
+	problems *ProblemService
+}
+
+func NewReviewService(db *gorm.DB, problems *ProblemService) *ReviewService {
+	return &ReviewService{db: db, problems: problems}
+}
+
+// RequestReview открывает ветку ревью по принятой отправке. Запросить ревью может
+// автор кода, начать его — ревьюер. Если по задаче уже есть незавершённое ревью,
+// возвращается оно
+func (s *ReviewService) RequestReview(submissionID, requesterID uint, role string) (*models.CodeReview, error) {
+	var submission models.UserSubmission
+	if err := s.db.First(&submission, submissionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("отправка не найдена")
+		}
+		return nil, fmt.Errorf("ошибка получения отправки: %w", err)
+	}
+
+	reviewer := isReviewerRole(role)
+	if submission.UserID != requesterID && !reviewer {
+		return nil, errors.New("нет доступа к отправке")
+	}
+	if submission.Status != models.SubmissionStatusAccepted {
+		return nil, errors.New("ревью доступно только для принятых решений")
+	}
+
+	var existing models.CodeReview
+	err := s.db.Where("user_id = ? AND problem_id = ? AND status <> ?",
+		submission.UserID, submission.ProblemID, models.ReviewStatusApproved).
+		First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("ошибка получения ревью: %w", err)
+	}
+
+	review := &models.CodeReview{
+		UserID:       submission.UserID,
+		ProblemID:    submission.ProblemID,
+		SubmissionID: submission.ID,
+		Status:       models.ReviewStatusPending,
+	}
+	if reviewer && submission.UserID != requesterID {
+		review.ReviewerID = &requesterID
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(review).Error; err != nil {
+			return fmt.Errorf("ошибка создания ревью: %w", err)
+		}
+		if err := tx.Model(&submission).Update("review_id", review.ID).Error; err != nil {
+			return fmt.Errorf("ошибка привязки отправки к ревью: %w", err)
+		}
+
+		if review.ReviewerID != nil {
+			return notify(tx, []uint{review.UserID}, models.NotificationReviewRequested,
+				"Ревью вашего решения", "Наставник начал ревью вашего решения", "review", review.ID)
+		}
+		reviewers, err := reviewerRecipients(tx, review)
+		if err != nil {
+			return err
+		}
+		return notify(tx, reviewers, models.NotificationReviewRequested,
+			"Новый запрос на ревью", "Студент запросил ревью решения", "review", review.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+// GetReviewQueue возвращает ревью, ожидающие проверки, начиная с самых старых
+func (s *ReviewService) GetReviewQueue(page, limit int) ([]*models.CodeReview, int64, error) {
+	var reviews []*models.CodeReview
+	var total int64
+
+	query := s.db.Model(&models.CodeReview{}).Where("status = ?", models.ReviewStatusPending)
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	if err := query.Preload("Problem").
+		Preload("User").
+		Order("updated_at ASC").
+		Offset(offset).Limit(limit).
+		Find(&reviews).Error; err != nil {
+		return nil, 0, fmt.Errorf("ошибка получения очереди ревью: %w", err)
+	}
+
+	for _, review := range reviews {
+		review.User.Password = ""
+	}
+	return reviews, total, nil
+}
+
+// GetUserReviews возвращает ревью решений пользователя
+func (s *ReviewService) GetUserReviews(userID uint) ([]*models.CodeReview, error) {
+	var reviews []*models.CodeReview
+	if err := s.db.Preload("Problem").
+		Where("user_id = ?", userID).
+		Order("updated_at DESC").
+		Find(&reviews).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения ревью: %w", err)
+	}
+	return reviews, nil
+}
+
+// GetReview возвращает ветку ревью со всеми ревизиями и комментариями
+func (s *ReviewService) GetReview(id, viewerID uint, role string) (*ReviewThread, error) {
+	review, err := s.getReview(id, viewerID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	thread := &ReviewThread{Review: review}
+	if err := s.db.Where("review_id = ?", review.ID).
+		Order("created_at ASC").
+		Find(&thread.Revisions).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения ревизий: %w", err)
+	}
+	if err := s.db.Preload("Author").
+		Where("review_id = ?", review.ID).
+		Order("created_at ASC").
+		Find(&thread.Comments).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения комментариев: %w", err)
+	}
+
+	for i := range thread.Comments {
+		thread.Comments[i].Author.Password = ""
+	}
+	return thread, nil
+}
+
+// AddComment добавляет комментарий в ветку ревью. Комментарий с номером строки
+// привязывается к строке кода указанной ревизии (по умолчанию текущей)
+func (s *ReviewService) AddComment(reviewID, authorID uint, role string, req *AddReviewCommentRequest) (*models.ReviewComment, error) {
+	review, err := s.getReview(reviewID, authorID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	submissionID := review.SubmissionID
+	if req.SubmissionID != 0 {
+		submissionID = req.SubmissionID
+	}
+
+	var revision models.UserSubmission
+	if err := s.db.Where("id = ? AND review_id = ?", submissionID, review.ID).First(&revision).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("ревизия не относится к этому ревью")
+		}
+		return nil, fmt.Errorf("ошибка получения ревизии: %w", err)
+	}
+	if req.Line != nil {
+		lines := strings.Count(strings.TrimRight(revision.Code, "\n"), "\n") + 1
+		if *req.Line < 1 || *req.Line > lines {
+			return nil, fmt.Errorf("строка %d вне диапазона 1-%d", *req.Line, lines)
+		}
+	}
+
+	comment := &models.ReviewComment{
+		ReviewID:     review.ID,
+		SubmissionID: revision.ID,
+		AuthorID:     authorID,
+		Line:         req.Line,
+		Body:         req.Body,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return fmt.Errorf("ошибка создания комментария: %w", err)
+		}
+		// Первый комментарий ревьюера закрепляет за ним ревью
+		if authorID != review.UserID && review.ReviewerID == nil {
+			if err := tx.Model(review).Update("reviewer_id", authorID).Error; err != nil {
+				return fmt.Errorf("ошибка назначения ревьюера: %w", err)
+			}
+		}
+
+		recipients := []uint{review.UserID}
+		if authorID == review.UserID {
+			var err error
+			if recipients, err = reviewerRecipients(tx, review); err != nil {
+				return err
+			}
+		}
+		return notify(tx, recipients, models.NotificationReviewComment,
+			"Новый комментарий в ревью", truncateText(req.Body, 200), "review", review.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+// errReviewDecided решение по ревью уже принято, а исправления ещё не отправлены
+var errReviewDecided = errors.New("решение по ревью уже принято")
+
+// SetDecision одобряет решение или запрашивает исправления. Необязательный текст
+// сохраняется как общий комментарий
+func (s *ReviewService) SetDecision(reviewID, reviewerID uint, status models.ReviewStatus, body string) (*models.CodeReview, error) {
+	var review models.CodeReview
+	if err := s.db.First(&review, reviewID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("ревью не найдено")
+		}
+		return nil, fmt.Errorf("ошибка получения ревью: %w", err)
+	}
+	if review.UserID == reviewerID {
+		return nil, errors.New("нельзя проверять собственное решение")
+	}
+	if review.Status != models.ReviewStatusPending {
+		return nil, errReviewDecided
+	}
+
+	notificationType, title := models.NotificationChangesRequested, "Требуются исправления"
+	if status == models.ReviewStatusApproved {
+		notificationType, title = models.NotificationReviewApproved, "Решение одобрено"
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Решение принимается только по ожидающему ревью: из двух параллельных
+		// решений второе не перезапишет первое и не отправит лишнее уведомление
+		result := tx.Model(&review).Where("status = ?", models.ReviewStatusPending).Updates(map[string]interface{}{
+			"status":      status,
+			"reviewer_id": reviewerID,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("ошибка обновления ревью: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errReviewDecided
+		}
+		if body != "" {
+			comment := &models.ReviewComment{
+				ReviewID:     review.ID,
+				SubmissionID: review.SubmissionID,
+				AuthorID:     reviewerID,
+				Body:         body,
+			}
+			if err := tx.Create(comment).Error; err != nil {
+				return fmt.Errorf("ошибка создания комментария: %w", err)
+			}
+		}
+		return notify(tx, []uint{review.UserID}, notificationType, title, truncateText(body, 200), "review", review.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+// Resubmit создаёт новую отправку в рамках ревью после запроса исправлений.
+// Отправку нужно передать на проверку судье; после принятия она станет текущей ревизией
+func (s *ReviewService) Resubmit(reviewID, userID uint, code string) (*models.UserSubmission, *models.Problem, error) {
+	var review models.CodeReview
+	if err := s.db.Preload("Problem").First(&review, reviewID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("ревью не найдено")
+		}
+		return nil, nil, fmt.Errorf("ошибка получения ревью: %w", err)
+	}
+	if review.UserID != userID {
+		return nil, nil, errors.New("нет доступа к ревью")
+	}
+	if review.Status != models.ReviewStatusChangesRequested {
+		return nil, nil, errors.New("исправления по ревью не запрашивались")
+	}
+
+	submission, err := s.problems.CreateSubmission(userID, review.ProblemID, nil, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.db.Model(submission).Update("review_id", review.ID).Error; err != nil {
+		return nil, nil, fmt.Errorf("ошибка привязки отправки к ревью: %w", err)
+	}
+	submission.ReviewID = &review.ID
+
+	return submission, &review.Problem, nil
+}
+
+func (s *ReviewService) getReview(id, viewerID uint, role string) (*models.CodeReview, error) {
+	var review models.CodeReview
+	if err := s.db.Preload("Problem").
+		Preload("Submission").
+		First(&review, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("ревью не найдено")
+		}
+		return nil, fmt.Errorf("ошибка получения ревью: %w", err)
+	}
+	if review.UserID != viewerID && !isReviewerRole(role) {
+		return nil, errors.New("ревью не найдено")
+	}
+	return &review, nil
+}
+
+// advanceReview делает принятую повторную отправку текущей ревизией ревью и
+// возвращает ревью в очередь проверки
+func (s *ProblemService) advanceReview(submissionID uint) error {
+	var submission models.UserSubmission
+	if err := s.db.First(&submission, submissionID).Error; err != nil {
+		return err
+	}
+	if submission.ReviewID == nil {
+		return nil
+	}
+
+	var review models.CodeReview
+	if err := s.db.First(&review, *submission.ReviewID).Error; err != nil {
+		return err
+	}
+	if review.SubmissionID == submission.ID || review.Status != models.ReviewStatusChangesRequested {
+		return nil
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&review).Updates(map[string]interface{}{
+			"submission_id": submission.ID,
+			"status":        models.ReviewStatusPending,
+		}).Error; err != nil {
+			return err
+		}
+		recipients, err := reviewerRecipients(tx, &review)
+		if err != nil {
+			return err
+		}
+		return notify(tx, recipients, models.NotificationReviewResubmitted,
+			"Исправленное решение", "Студент отправил исправленное решение", "review", review.ID)
+	})
+}
+
+// reviewerRecipients возвращает получателей уведомлений со стороны проверяющих:
+// назначенного ревьюера или всех ревьюеров, если ревью ещё не взято
+func reviewerRecipients(db *gorm.DB, review *models.CodeReview) ([]uint, error) {
+	if review.ReviewerID != nil {
+		return []uint{*review.ReviewerID}, nil
+	}
+	return usersWithRole(db, models.UserRoleReviewer)
+}
+
+func isReviewerRole(role string) bool {
+	return role == string(models.UserRoleReviewer) || role == string(models.UserRoleAdmin)
+}
+
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
+
+// ReviewThread ветка ревью с ревизиями кода и комментариями
+type ReviewThread struct {
+	Review    *models.CodeReview      `json:"review"`
+	Revisions []models.UserSubmission `json:"revisions"`
+	Comments  []models.ReviewComment  `json:"comments"`
+}
+
+type RequestReviewRequest struct {
+	SubmissionID uint `json:"submission_id" binding:"required"`
+}
+
+type AddReviewCommentRequest struct {
+	SubmissionID uint   `json:"submission_id" binding:"omitempty"`
+	Line         *int   `json:"line" binding:"omitempty,min=1"`
+	Body         string `json:"body" binding:"required,max=5000"`
+}
+
+type ReviewDecisionRequest struct {
+	Body string `json:"body" binding:"omitempty,max=5000"`
+}
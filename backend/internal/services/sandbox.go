@@ -3,12 +3,15 @@ package services
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"go-education-platform/internal/models"
@@ -17,6 +20,7 @@ import (
 type SandboxService struct {
 	tempDir       string
 	defaultTimeout time.Duration
+	activeDirs    sync.Map // директории exec_*, с которыми сейчас идёт работа
 }
 
 func NewSandboxService() *SandboxService {
@@ -34,6 +38,9 @@ type TestCase struct {
 	Input    string `json:"input"`
 	Expected string `json:"expected"`
 	Name     string `json:"name,omitempty"`
+	Group    string `json:"group,omitempty"` // имя группы тестов (подзадачи)
+	// Generated отмечает тесты, полученные генератором автора
+	Generated bool `json:"generated,omitempty"`
 }
 
 // ExecutionResult результат выполнения кода
@@ -46,10 +53,45 @@ type ExecutionResult struct {
 	TestsPassed   int                     `json:"tests_passed"`
 	TestsTotal    int                     `json:"tests_total"`
 	Score         int                     `json:"score"`
+	Groups        []GroupResult           `json:"groups,omitempty"`
 }
 
 // ExecuteCode выполняет Go код с заданными тест-кейсами
 func (s *SandboxService) ExecuteCode(code string, testCases []TestCase, timeLimit int) (*ExecutionResult, error) {
+	return s.ExecuteCodeWithChecker(code, "", testCases, timeLimit)
+}
+
+// ExecuteCodeWithChecker выполняет Go код и проверяет вывод программой-чекером.
+// Если checker пустой, вывод сравнивается с ожидаемым построчно.
+func (s *SandboxService) ExecuteCodeWithChecker(code, checker string, testCases []TestCase, timeLimit int) (*ExecutionResult, error) {
+	return s.ExecuteTests(code, testCases, ExecutionOptions{Checker: checker, TimeLimit: timeLimit})
+}
+
+// errJudgeSystem отмечает сбои проверяющей системы: отсутствие инструментов,
+// ошибки файловой системы, падение чекера или интерактора автора. В таких
+// случаях решение студента не виновато и вердикт по нему не выносится
+var errJudgeSystem = errors.New("сбой проверяющей системы")
+
+func judgeSystemError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errJudgeSystem, fmt.Sprintf(format, args...))
+}
+
+// ExecutionOptions параметры проверки решения
+type ExecutionOptions struct {
+	Checker   string      // исходный код чекера, пустой для построчного сравнения
+	// Исходный код интерактора. Если задан, решение общается с интерактором
+	// через stdin/stdout, а вердикт выносит интерактор
+	Interactor string
+	Groups    []TestGroup // группы тестов (подзадачи), пустые для оценки по всем тестам
+	TimeLimit int         // в секундах
+
+	// Ограничения на код, проверяемые до компиляции
+	Constraints *SolutionConstraints
+}
+
+// ExecuteTests компилирует Go код и прогоняет его на тест-кейсах с заданными параметрами
+func (s *SandboxService) ExecuteTests(code string, testCases []TestCase, opts ExecutionOptions) (*ExecutionResult, error) {
+	timeLimit := opts.TimeLimit
 	if timeLimit <= 0 {
 		timeLimit = 5 // секунды по умолчанию
 	}
@@ -60,11 +102,11 @@ func (s *SandboxService) ExecuteCode(code string, testCases []TestCase, timeLimi
 	}
 
 	// Создаем временную директорию для выполнения
-	execDir := filepath.Join(s.tempDir, fmt.Sprintf("exec_%d", time.Now().UnixNano()))
-	if err := os.MkdirAll(execDir, 0755); err != nil {
-		return nil, fmt.Errorf("ошибка создания временной директории: %w", err)
+	execDir, err := s.makeExecDir()
+	if err != nil {
+		return nil, err
 	}
-	defer os.RemoveAll(execDir)
+	defer s.releaseExecDir(execDir)
 
 	// Подготавливаем полный код с функцией main
 	fullCode := s.prepareCode(code)
@@ -72,7 +114,7 @@ func (s *SandboxService) ExecuteCode(code string, testCases []TestCase, timeLimi
 	// Создаем файл с кодом
 	codeFile := filepath.Join(execDir, "main.go")
 	if err := os.WriteFile(codeFile, []byte(fullCode), 0644); err != nil {
-		return nil, fmt.Errorf("ошибка записи кода в файл: %w", err)
+		return nil, judgeSystemError("ошибка записи кода в файл: %v", err)
 	}
 
 	result := &ExecutionResult{
@@ -82,18 +124,78 @@ func (s *SandboxService) ExecuteCode(code string, testCases []TestCase, timeLimi
 		Score:       0,
 	}
 
+	// Проверяем ограничения задачи до компиляции
+	if violations := CheckSolutionConstraints(code, opts.Constraints); len(violations) > 0 {
+		result.Status = models.SubmissionStatusConstraintViolation
+		result.ErrorOutput = FormatConstraintViolations(violations)
+		return result, nil
+	}
+
 	// Компилируем код
 	start := time.Now()
 	if err := s.compileCode(execDir, timeout); err != nil {
+		if errors.Is(err, errJudgeSystem) {
+			return nil, err
+		}
 		result.Status = models.SubmissionStatusCompileError
 		result.ErrorOutput = err.Error()
 		return result, nil
 	}
 
+	// Компилируем чекер и интерактор, если они заданы. Они собираются вне
+	// директории решения, чтобы решение не прочитало и не подменило их
+	tools := judgeTools{digests: make(map[string][sha256.Size]byte)}
+	if strings.TrimSpace(opts.Checker) != "" || strings.TrimSpace(opts.Interactor) != "" {
+		toolsDir, err := s.makeExecDir()
+		if err != nil {
+			return nil, judgeSystemError("ошибка создания директории чекера: %v", err)
+		}
+		defer s.releaseExecDir(toolsDir)
+
+		if strings.TrimSpace(opts.Checker) != "" {
+			path, err := s.compileHelper(toolsDir, "checker", opts.Checker)
+			if err != nil {
+				return nil, judgeSystemError("ошибка компиляции чекера: %v", err)
+			}
+			if tools.checkerPath, err = tools.add(path); err != nil {
+				return nil, err
+			}
+		}
+		if strings.TrimSpace(opts.Interactor) != "" {
+			path, err := s.compileHelper(toolsDir, "interactor", opts.Interactor)
+			if err != nil {
+				return nil, judgeSystemError("ошибка компиляции интерактора: %v", err)
+			}
+			if tools.interactorPath, err = tools.add(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// Выполняем тесты
+	if len(opts.Groups) > 0 {
+		if err := s.runGroupedTests(execDir, tools, testCases, opts.Groups, timeout, result); err != nil {
+			return nil, err
+		}
+	} else if err := s.runAllTests(execDir, tools, testCases, timeout, result); err != nil {
+		return nil, err
+	}
+
+	result.ExecutionTime = int(time.Since(start).Milliseconds())
+
+	return result, nil
+}
+
+// runAllTests выполняет тесты по порядку до первой ошибки выполнения.
+// Балл пропорционален числу пройденных тестов. Ошибка возвращается только
+// при сбое проверяющей системы
+func (s *SandboxService) runAllTests(execDir string, tools judgeTools, testCases []TestCase, timeout time.Duration, result *ExecutionResult) error {
 	for i, testCase := range testCases {
-		testResult, err := s.runTest(execDir, testCase, timeout)
+		testResult, err := s.runTest(execDir, tools, testCase, timeout)
 		if err != nil {
+			if errors.Is(err, errJudgeSystem) {
+				return err
+			}
 			result.Status = models.SubmissionStatusRuntimeError
 			result.ErrorOutput = err.Error()
 			break
@@ -104,8 +206,7 @@ func (s *SandboxService) ExecuteCode(code string, testCases []TestCase, timeLimi
 		} else {
 			// Если тест не прошёл, записываем детали
 			if result.ErrorOutput == "" {
-				result.ErrorOutput = fmt.Sprintf("Тест %d не пройден:\nВход: %s\nОжидалось: %s\nПолучено: %s",
-					i+1, testCase.Input, testCase.Expected, testResult.Output)
+				result.ErrorOutput = failedTestMessage(i, testCase, testResult)
 			}
 		}
 
@@ -115,8 +216,6 @@ func (s *SandboxService) ExecuteCode(code string, testCases []TestCase, timeLimi
 		}
 	}
 
-	result.ExecutionTime = int(time.Since(start).Milliseconds())
-
 	// Определяем финальный статус
 	if result.Status == models.SubmissionStatusRunning {
 		if result.TestsPassed == result.TestsTotal {
@@ -127,8 +226,12 @@ func (s *SandboxService) ExecuteCode(code string, testCases []TestCase, timeLimi
 			result.Score = (result.TestsPassed * 100) / result.TestsTotal
 		}
 	}
+	return nil
+}
 
-	return result, nil
+func failedTestMessage(index int, testCase TestCase, testResult *TestResult) string {
+	return fmt.Sprintf("Тест %d не пройден:\nВход: %s\nОжидалось: %s\nПолучено: %s",
+		index+1, testCase.Input, testCase.Expected, testResult.Output)
 }
 
 // TestResult результат выполнения одного теста
@@ -154,23 +257,214 @@ func (s *SandboxService) compileCode(execDir string, timeout time.Duration) erro
 		if ctx.Err() == context.DeadlineExceeded {
 			return fmt.Errorf("превышено время компиляции")
 		}
+		if _, ok := err.(*exec.ExitError); !ok {
+			return judgeSystemError("не удалось запустить компилятор Go: %v", err)
+		}
 		return fmt.Errorf("ошибка компиляции: %s", stderr.String())
 	}
 
 	return nil
 }
 
-// runTest выполняет один тест
-func (s *SandboxService) runTest(execDir string, testCase TestCase, timeout time.Duration) (*TestResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+// makeExecDir создаёт уникальную временную директорию для выполнения.
+// Директорию нужно освободить через releaseExecDir
+func (s *SandboxService) makeExecDir() (string, error) {
+	execDir := filepath.Join(s.tempDir, fmt.Sprintf("exec_%d", time.Now().UnixNano()))
+	s.activeDirs.Store(execDir, struct{}{})
+	if err := os.MkdirAll(execDir, 0755); err != nil {
+		s.activeDirs.Delete(execDir)
+		return "", fmt.Errorf("ошибка создания временной директории: %w", err)
+	}
+	return execDir, nil
+}
 
-	programPath := filepath.Join(execDir, "program")
+// releaseExecDir удаляет временную директорию выполнения
+func (s *SandboxService) releaseExecDir(execDir string) {
+	os.RemoveAll(execDir)
+	s.activeDirs.Delete(execDir)
+}
+
+// CleanOrphanedExecDirs удаляет директории exec_*, оставшиеся после аварийного
+// завершения сервера или прерванной проверки: не используемые сейчас и не
+// изменявшиеся дольше olderThan. Возвращает число удалённых директорий
+func (s *SandboxService) CleanOrphanedExecDirs(olderThan time.Duration) (int, error) {
+	entries, err := os.ReadDir(s.tempDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("ошибка чтения временной директории: %w", err)
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "exec_") {
+			continue
+		}
+		path := filepath.Join(s.tempDir, entry.Name())
+		if _, active := s.activeDirs.Load(path); active {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return removed, fmt.Errorf("ошибка удаления директории %s: %w", entry.Name(), err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// compileHelper компилирует вспомогательную программу (чекер, генератор и т.п.)
+// в отдельной поддиректории и возвращает путь к исполняемому файлу. Исходный
+// код после сборки удаляется
+func (s *SandboxService) compileHelper(execDir, name, source string) (string, error) {
+	helperDir := filepath.Join(execDir, name)
+	if err := os.MkdirAll(helperDir, 0755); err != nil {
+		return "", fmt.Errorf("ошибка создания директории: %w", err)
+	}
+
+	sourcePath := filepath.Join(helperDir, "main.go")
+	if err := os.WriteFile(sourcePath, []byte(s.prepareCode(source)), 0644); err != nil {
+		return "", fmt.Errorf("ошибка записи кода в файл: %w", err)
+	}
+	defer os.Remove(sourcePath)
+
+	if err := s.compileCode(helperDir, s.defaultTimeout); err != nil {
+		return "", err
+	}
+
+	return s.programPath(helperDir), nil
+}
+
+// programPath возвращает путь к скомпилированной программе в директории
+func (s *SandboxService) programPath(dir string) string {
+	programPath := filepath.Join(dir, "program")
 	if _, err := os.Stat(programPath); os.IsNotExist(err) {
-		programPath = filepath.Join(execDir, "program.exe") // Windows
+		programPath = filepath.Join(dir, "program.exe") // Windows
+	}
+	return programPath
+}
+
+// runChecker запускает чекер в формате testlib: checker <input> <output> <answer>.
+// Нулевой код возврата означает правильный ответ. Файлы чекера пишутся в
+// отдельную директорию и удаляются сразу после проверки, чтобы решение на
+// следующем тесте не прочитало ответ
+func (s *SandboxService) runChecker(tools judgeTools, testCase TestCase, output string) (bool, string, error) {
+	if err := tools.verify(tools.checkerPath); err != nil {
+		return false, "", err
+	}
+
+	filesDir, err := s.makeExecDir()
+	if err != nil {
+		return false, "", judgeSystemError("ошибка создания директории чекера: %v", err)
+	}
+	defer s.releaseExecDir(filesDir)
+
+	files := map[string]string{
+		"input.txt":  testCase.Input,
+		"output.txt": output,
+		"answer.txt": testCase.Expected,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(filesDir, name), []byte(content), 0644); err != nil {
+			return false, "", judgeSystemError("ошибка записи файла чекера: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.defaultTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, tools.checkerPath, "input.txt", "output.txt", "answer.txt")
+	cmd.Dir = filesDir
+
+	var combined bytes.Buffer
+	cmd.Stdout = &combined
+	cmd.Stderr = &combined
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return false, "", judgeSystemError("превышено время работы чекера")
+		}
+		if _, ok := err.(*exec.ExitError); ok {
+			return false, strings.TrimSpace(combined.String()), nil
+		}
+		return false, "", judgeSystemError("ошибка запуска чекера: %v", err)
+	}
+
+	return true, strings.TrimSpace(combined.String()), nil
+}
+
+// execDirKeep файлы и директории, которые остаются в директории выполнения между тестами
+var execDirKeep = map[string]bool{
+	"main.go":     true,
+	"program":     true,
+	"program.exe": true,
+}
+
+// resetExecDir удаляет из директории выполнения всё, что решение создало на
+// прошлых тестах: через файлы нельзя переносить состояние между тестами
+func resetExecDir(execDir string) error {
+	entries, err := os.ReadDir(execDir)
+	if err != nil {
+		return judgeSystemError("ошибка чтения директории выполнения: %v", err)
+	}
+	for _, entry := range entries {
+		if execDirKeep[entry.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(execDir, entry.Name())); err != nil {
+			return judgeSystemError("ошибка очистки директории выполнения: %v", err)
+		}
+	}
+	return nil
+}
+
+// judgeTools пути к скомпилированным программам автора задачи, пустые — если не заданы
+type judgeTools struct {
+	checkerPath    string
+	interactorPath string
+	digests        map[string][sha256.Size]byte // контрольные суммы программ сразу после сборки
+}
+
+// add запоминает контрольную сумму собранной программы и возвращает её путь
+func (t judgeTools) add(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", judgeSystemError("ошибка чтения программы автора: %v", err)
+	}
+	t.digests[path] = sha256.Sum256(data)
+	return path, nil
+}
+
+// verify проверяет, что программа автора не изменилась после сборки. Решение
+// работает от того же пользователя ОС и могло бы подменить чекер программой,
+// принимающей любой ответ. Изменить программу может только решение, поэтому
+// это ошибка решения, а не сбой проверяющей системы
+func (t judgeTools) verify(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil || sha256.Sum256(data) != t.digests[path] {
+		return errors.New("решение изменило программу автора задачи")
+	}
+	return nil
+}
+
+// runTest выполняет один тест
+func (s *SandboxService) runTest(execDir string, tools judgeTools, testCase TestCase, timeout time.Duration) (*TestResult, error) {
+	if err := resetExecDir(execDir); err != nil {
+		return nil, err
+	}
+	if tools.interactorPath != "" {
+		return s.runInteractiveTest(execDir, tools, testCase, timeout)
 	}
 
-	cmd := exec.CommandContext(ctx, programPath)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.programPath(execDir))
 	cmd.Dir = execDir
 
 	var stdout, stderr bytes.Buffer
@@ -192,9 +486,25 @@ func (s *SandboxService) runTest(execDir string, testCase TestCase, timeout time
 		if ctx.Err() == context.DeadlineExceeded {
 			return nil, fmt.Errorf("превышено время выполнения")
 		}
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, judgeSystemError("не удалось запустить решение: %v", err)
+		}
 		return result, nil // Возвращаем результат с ошибкой, но не прерываем
 	}
 
+	// Проверяем вывод чекером, если он задан
+	if tools.checkerPath != "" {
+		success, message, err := s.runChecker(tools, testCase, stdout.String())
+		if err != nil {
+			return nil, err
+		}
+		result.Success = success
+		if !success && message != "" {
+			result.ErrorOutput = message
+		}
+		return result, nil
+	}
+
 	// Сравниваем вывод с ожидаемым результатом
 	expected := strings.TrimSpace(testCase.Expected)
 	result.Success = result.Output == expected
@@ -271,6 +581,44 @@ func (s *SandboxService) ExecuteSubmission(submission *models.UserSubmission, pr
 		return nil, err
 	}
 
+	groups, err := ParseTestGroups(problem.TestGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	constraints, err := ParseSolutionConstraints(problem.Constraints)
+	if err != nil {
+		return nil, err
+	}
+
 	// Выполняем код
-	return s.ExecuteCode(submission.Code, testCases, problem.TimeLimit)
+	return s.ExecuteTests(submission.Code, testCases, ExecutionOptions{
+		Checker:     problem.Checker,
+		Interactor:  problem.Interactor,
+		Groups:      groups,
+		TimeLimit:   problem.TimeLimit,
+		Constraints: constraints,
+	})
+}
+
+// ValidateReferenceSolution проверяет, что эталонное решение проходит все тесты
+func (s *SandboxService) ValidateReferenceSolution(solution string, testCases []TestCase, opts ExecutionOptions) error {
+	if strings.TrimSpace(solution) == "" {
+		return errors.New("не задано эталонное решение")
+	}
+	if len(testCases) == 0 {
+		return errors.New("задача не содержит тестов")
+	}
+
+	result, err := s.ExecuteTests(solution, testCases, opts)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки эталонного решения: %w", err)
+	}
+
+	if result.Status != models.SubmissionStatusAccepted {
+		return fmt.Errorf("эталонное решение не прошло тесты (%s, пройдено %d из %d): %s",
+			result.Status, result.TestsPassed, result.TestsTotal, result.ErrorOutput)
+	}
+
+	return nil
 }
\ No newline at end of file
@@ -164,7 +164,7 @@ type ChangePasswordRequest struct {
 type UpdateUserRequest struct {
 	Name   string `json:"name" binding:"omitempty,min=2,max=50"`
 	Email  string `json:"email" binding:"omitempty,email"`
-	Role   string `json:"role" binding:"omitempty,oneof=user admin"`
+	Role   string `json:"role" binding:"omitempty,oneof=user reviewer admin"`
 	Level  string `json:"level" binding:"omitempty,oneof=junior middle senior"`
 	Points int    `json:"points" binding:"omitempty,min=0"`
 }
@@ -188,15 +188,16 @@ func GetUserFromContext(c *gin.Context) (*models.User, error) {
 }
 
 type TestService struct {
-	db *gorm.DB
+	db      *gorm.DB
+	sandbox *SandboxService
 }
 
-func NewTestService(db *gorm.DB) *TestService {
-	return &TestService{db: db}
+func NewTestService(db *gorm.DB, sandbox *SandboxService) *TestService {
+	return &TestService{db: db, sandbox: sandbox}
 }
 
 // GetTests получает список тестов с пагинацией и фильтрацией
-func (s *TestService) GetTests(page, limit int, search, difficulty string) ([]models.Test, int64, error) {
+func (s *TestService) GetTests(page, limit int, search, difficulty string) ([]TestView, int64, error) {
 	var tests []models.Test
 	var total int64
 	
@@ -214,14 +215,22 @@ func (s *TestService) GetTests(page, limit int, search, difficulty string) ([]mo
 	query.Count(&total)
 	
 	// Get paginated results with questions
-	err := query.Preload("Questions").
+	err := query.Preload("Questions").Preload("Rules").
 		Offset((page - 1) * limit).Limit(limit).
 		Order("created_at DESC").Find(&tests).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	
+	views := make([]TestView, 0, len(tests))
+	for i := range tests {
+		views = append(views, newTestView(&tests[i], paperSize(&tests[i])))
+	}
 	
-	return tests, total, err
+	return views, total, nil
 }
 
-// GetTestByID получает тест по ID с вопросами и ответами
+// GetTestByID получает активный тест по ID с вопросами без вариантов ответа
 func (s *TestService) GetTestByID(testID uint) (*models.Test, error) {
 	var test models.Test
 	err := s.db.Preload("Questions").
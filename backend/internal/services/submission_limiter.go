@@ -0,0 +1,142 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"go-education-platform/internal/config"
+)
+
+// bucketIdleTTL время, после которого неиспользуемое ведро удаляется из памяти
+const bucketIdleTTL = 30 * time.Minute
+
+// SubmissionLimiter ограничивает частоту отправок решений алгоритмом token bucket:
+// отдельное ведро на пользователя и на пару пользователь-задача
+type SubmissionLimiter struct {
+	mu          sync.Mutex
+	user        tokenBucketConfig
+	problem     tokenBucketConfig
+	userBuckets map[uint]*tokenBucket
+	pairBuckets map[string]*tokenBucket
+	lastSweep   time.Time
+	dupWindow   time.Duration
+}
+
+type tokenBucketConfig struct {
+	rate  float64 // токенов в секунду, 0 отключает ограничение
+	burst float64
+}
+
+func (c tokenBucketConfig) enabled() bool {
+	return c.rate > 0
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewSubmissionLimiter(cfg config.SubmissionConfig) *SubmissionLimiter {
+	window, err := time.ParseDuration(cfg.DuplicateWindow)
+	if err != nil || window < 0 {
+		window = 10 * time.Minute
+	}
+
+	return &SubmissionLimiter{
+		user:        newTokenBucketConfig(cfg.UserRatePerMinute, cfg.UserBurst),
+		problem:     newTokenBucketConfig(cfg.ProblemRatePerMinute, cfg.ProblemBurst),
+		userBuckets: make(map[uint]*tokenBucket),
+		pairBuckets: make(map[string]*tokenBucket),
+		lastSweep:   time.Now(),
+		dupWindow:   window,
+	}
+}
+
+func newTokenBucketConfig(perMinute, burst int) tokenBucketConfig {
+	if burst < 1 {
+		burst = 1
+	}
+	return tokenBucketConfig{rate: float64(perMinute) / 60, burst: float64(burst)}
+}
+
+// DuplicateWindow возвращает окно, в котором повторная отправка того же кода
+// возвращает предыдущий результат
+func (l *SubmissionLimiter) DuplicateWindow() time.Duration {
+	return l.dupWindow
+}
+
+// Allow списывает по токену из ведра пользователя и ведра задачи. Если хотя бы
+// в одном ведре токенов нет, ничего не списывается и возвращается время ожидания
+func (l *SubmissionLimiter) Allow(userID, problemID uint) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	userBucket := l.userBuckets[userID]
+	if userBucket == nil {
+		userBucket = &tokenBucket{tokens: l.user.burst, last: now}
+		l.userBuckets[userID] = userBucket
+	}
+	pairKey := fmt.Sprintf("%d:%d", userID, problemID)
+	pairBucket := l.pairBuckets[pairKey]
+	if pairBucket == nil {
+		pairBucket = &tokenBucket{tokens: l.problem.burst, last: now}
+		l.pairBuckets[pairKey] = pairBucket
+	}
+
+	userBucket.refill(l.user, now)
+	pairBucket.refill(l.problem, now)
+
+	wait := userBucket.wait(l.user)
+	if pairWait := pairBucket.wait(l.problem); pairWait > wait {
+		wait = pairWait
+	}
+	if wait > 0 {
+		return false, wait
+	}
+
+	if l.user.enabled() {
+		userBucket.tokens--
+	}
+	if l.problem.enabled() {
+		pairBucket.tokens--
+	}
+	return true, 0
+}
+
+// sweep удаляет давно не использованные вёдра, чтобы карта не росла бесконечно
+func (l *SubmissionLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketIdleTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for id, bucket := range l.userBuckets {
+		if now.Sub(bucket.last) > bucketIdleTTL {
+			delete(l.userBuckets, id)
+		}
+	}
+	for key, bucket := range l.pairBuckets {
+		if now.Sub(bucket.last) > bucketIdleTTL {
+			delete(l.pairBuckets, key)
+		}
+	}
+}
+
+func (b *tokenBucket) refill(cfg tokenBucketConfig, now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(cfg.burst, b.tokens+elapsed*cfg.rate)
+	b.last = now
+}
+
+// wait возвращает время до появления целого токена
+func (b *tokenBucket) wait(cfg tokenBucketConfig) time.Duration {
+	if !cfg.enabled() || b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / cfg.rate * float64(time.Second))
+}
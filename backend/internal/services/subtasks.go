@@ -0,0 +1,230 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-education-platform/internal/models"
+)
+
+// TestGroup описывает группу тестов (подзадачу) в стиле IOI. Баллы за группу
+// начисляются, только если пройдены все её тесты и все группы из DependsOn
+type TestGroup struct {
+	Name      string   `json:"name" yaml:"name"`
+	Weight    int      `json:"weight" yaml:"weight"`
+	DependsOn []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+}
+
+// GroupResult результат проверки одной группы тестов
+type GroupResult struct {
+	Name        string `json:"name"`
+	Weight      int    `json:"weight"`
+	Passed      bool   `json:"passed"`
+	Skipped     bool   `json:"skipped,omitempty"` // не проверялась из-за непройденной зависимости
+	TestsPassed int    `json:"tests_passed"`
+	TestsTotal  int    `json:"tests_total"`
+}
+
+// ParseTestGroups парсит JSON строку с группами тестов. Пустая строка означает отсутствие групп
+func ParseTestGroups(testGroupsJSON string) ([]TestGroup, error) {
+	if strings.TrimSpace(testGroupsJSON) == "" {
+		return nil, nil
+	}
+
+	var groups []TestGroup
+	if err := json.Unmarshal([]byte(testGroupsJSON), &groups); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга групп тестов: %w", err)
+	}
+	return groups, nil
+}
+
+// validateTestGroups проверяет группы тестов задачи: уникальность имён,
+// положительные веса, существующие зависимости без циклов и принадлежность
+// каждого теста одной из групп
+func validateTestGroups(groups []TestGroup, testCases []TestCase) error {
+	if len(groups) == 0 {
+		for _, tc := range testCases {
+			if tc.Group != "" {
+				return fmt.Errorf("тест %q относится к группе %q, но группы не заданы", tc.Name, tc.Group)
+			}
+		}
+		return nil
+	}
+
+	names := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		if group.Name == "" {
+			return errors.New("у группы тестов не задано имя")
+		}
+		if names[group.Name] {
+			return fmt.Errorf("группа тестов %q задана дважды", group.Name)
+		}
+		if group.Weight <= 0 {
+			return fmt.Errorf("вес группы %q должен быть положительным", group.Name)
+		}
+		names[group.Name] = true
+	}
+
+	if _, err := orderTestGroups(groups); err != nil {
+		return err
+	}
+
+	sizes := make(map[string]int, len(groups))
+	for i, tc := range testCases {
+		if !names[tc.Group] {
+			return fmt.Errorf("тест %d не относится ни к одной из групп", i+1)
+		}
+		sizes[tc.Group]++
+	}
+	for _, group := range groups {
+		if sizes[group.Name] == 0 {
+			return fmt.Errorf("группа %q не содержит тестов", group.Name)
+		}
+	}
+
+	return nil
+}
+
+// orderTestGroups упорядочивает группы так, чтобы зависимости шли раньше зависящих групп
+func orderTestGroups(groups []TestGroup) ([]TestGroup, error) {
+	byName := make(map[string]TestGroup, len(groups))
+	for _, group := range groups {
+		byName[group.Name] = group
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(groups))
+	ordered := make([]TestGroup, 0, len(groups))
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("циклическая зависимость групп тестов через %q", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range byName[name].DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return fmt.Errorf("группа %q зависит от несуществующей группы %q", name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		ordered = append(ordered, byName[name])
+		return nil
+	}
+
+	for _, group := range groups {
+		if err := visit(group.Name); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// runGroupedTests выполняет тесты по группам. Группа прекращает проверку на
+// первом непройденном тесте, группы с непройденными зависимостями пропускаются.
+// Балл равен доле веса полностью пройденных групп
+func (s *SandboxService) runGroupedTests(execDir string, tools judgeTools, testCases []TestCase, groups []TestGroup, timeout time.Duration, result *ExecutionResult) error {
+	ordered, err := orderTestGroups(groups)
+	if err != nil {
+		return err
+	}
+
+	testsByGroup := make(map[string][]int, len(groups))
+	for i, tc := range testCases {
+		testsByGroup[tc.Group] = append(testsByGroup[tc.Group], i)
+	}
+
+	passed := make(map[string]bool, len(groups))
+	resultsByName := make(map[string]GroupResult, len(groups))
+	totalWeight, passedWeight := 0, 0
+
+	for _, group := range ordered {
+		totalWeight += group.Weight
+		groupResult := GroupResult{
+			Name:       group.Name,
+			Weight:     group.Weight,
+			TestsTotal: len(testsByGroup[group.Name]),
+		}
+
+		dependenciesPassed := true
+		for _, dep := range group.DependsOn {
+			if !passed[dep] {
+				dependenciesPassed = false
+				break
+			}
+		}
+		if !dependenciesPassed {
+			groupResult.Skipped = true
+			resultsByName[group.Name] = groupResult
+			continue
+		}
+
+		groupPassed := true
+		for _, i := range testsByGroup[group.Name] {
+			testResult, err := s.runTest(execDir, tools, testCases[i], timeout)
+			if err != nil {
+				if errors.Is(err, errJudgeSystem) {
+					return err
+				}
+				if result.Status == models.SubmissionStatusRunning {
+					result.Status = models.SubmissionStatusRuntimeError
+					result.ErrorOutput = fmt.Sprintf("Тест %d: %s", i+1, err.Error())
+				}
+				groupPassed = false
+				break
+			}
+
+			if testResult.ExecutionTime > result.ExecutionTime {
+				result.ExecutionTime = testResult.ExecutionTime
+			}
+
+			if !testResult.Success {
+				if result.ErrorOutput == "" {
+					result.ErrorOutput = failedTestMessage(i, testCases[i], testResult)
+				}
+				groupPassed = false
+				break
+			}
+
+			result.TestsPassed++
+			groupResult.TestsPassed++
+		}
+
+		groupResult.Passed = groupPassed
+		passed[group.Name] = groupPassed
+		if groupPassed {
+			passedWeight += group.Weight
+		}
+		resultsByName[group.Name] = groupResult
+	}
+
+	// Результаты возвращаем в порядке, заданном автором
+	for _, group := range groups {
+		result.Groups = append(result.Groups, resultsByName[group.Name])
+	}
+
+	if totalWeight > 0 {
+		result.Score = passedWeight * 100 / totalWeight
+	}
+	if passedWeight == totalWeight {
+		result.Status = models.SubmissionStatusAccepted
+		result.Score = 100
+	} else if result.Status == models.SubmissionStatusRunning {
+		result.Status = models.SubmissionStatusWrongAnswer
+	}
+
+	return nil
+}
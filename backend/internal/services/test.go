@@ -0,0 +1,442 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// testDeadlineGrace запас после дедлайна попытки на задержку сети при отправке ответов
+const testDeadlineGrace = 30 * time.Second
+
+// ErrTestTimeExpired возвращается при отправке ответов после дедлайна попытки.
+// Попытка при этом завершается только с автосохранёнными до дедлайна ответами
+var ErrTestTimeExpired = errors.New("время на прохождение теста истекло")
+
+var errTestNotStarted = errors.New("тест не начат")
+
+// SubmittedTestAnswer ответ пользователя на один вопрос теста. AnswerIDs
+// заполняется для вопросов с вариантами (для ordering — номера вариантов из
+// попытки в выбранном порядке), Text — для text, code_output и code, Matches —
+// для matching
+type SubmittedTestAnswer struct {
+	QuestionID uint        `json:"question_id" binding:"required"`
+	AnswerIDs  []uint      `json:"answer_ids"`
+	Text       string      `json:"text" binding:"max=65536"`
+	Matches    []TestMatch `json:"matches"`
+	TimeSpent  int         `json:"time_spent" binding:"min=0"` // секунд на вопросе по данным клиента
+}
+
+// SubmitTestRequest ответы на вопросы теста. На вопросы без ответа берётся
+// автосохранённый ответ, если его нет — вопрос засчитывается как неверный
+type SubmitTestRequest struct {
+	Answers []SubmittedTestAnswer `json:"answers" binding:"dive"`
+}
+
+// GradedTestAnswer проверенный ответ, сохраняется в UserTestResult.Answers
+type GradedTestAnswer struct {
+	QuestionID uint        `json:"question_id"`
+	AnswerIDs  []uint      `json:"answer_ids"`
+	Text       string      `json:"text,omitempty"`
+	Matches    []TestMatch `json:"matches,omitempty"`
+	IsCorrect  bool        `json:"is_correct"`
+	Points     int         `json:"points"`
+	Feedback   string      `json:"feedback,omitempty"` // результат проверки кода
+	TimeSpent  int         `json:"time_spent,omitempty"`
+}
+
+// GetTest возвращает описание активного теста для студента. Вопросы выдаются
+// только при начале попытки
+func (s *TestService) GetTest(testID uint) (*TestView, error) {
+	test, err := s.GetTestByID(testID)
+	if err != nil {
+		return nil, err
+	}
+	if test.Rules, err = s.loadRules(testID); err != nil {
+		return nil, err
+	}
+	view := newTestView(test, paperSize(test))
+	return &view, nil
+}
+
+// GetTestForAdmin возвращает тест целиком, с верными ответами и разборами,
+// в том числе неактивный
+func (s *TestService) GetTestForAdmin(testID uint) (*models.Test, error) {
+	var test models.Test
+	if err := s.db.First(&test, testID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("тест не найден")
+		}
+		return nil, fmt.Errorf("ошибка получения теста: %w", err)
+	}
+
+	questions, err := s.loadQuestions(testID)
+	if err != nil {
+		return nil, err
+	}
+	test.Questions = questions
+	if test.Rules, err = s.loadRules(testID); err != nil {
+		return nil, err
+	}
+	return &test, nil
+}
+
+// StartTest начинает попытку прохождения теста. Если незавершённая попытка
+// ещё не истекла, она продолжается с прежним дедлайном и автосохранёнными
+// ответами; истёкшая попытка завершается с автосохранёнными ответами, и
+// вместо неё создаётся новая. Новая попытка
+// создаётся, только если это позволяют лимит попыток и пауза после неудачи
+func (s *TestService) StartTest(testID, userID uint) (*TestAttemptView, error) {
+	test, err := s.GetTestByID(testID)
+	if err != nil {
+		return nil, err
+	}
+	if test.Questions, err = s.loadQuestions(testID); err != nil {
+		return nil, err
+	}
+	if test.Rules, err = s.loadRules(testID); err != nil {
+		return nil, err
+	}
+	if paperSize(test) == 0 {
+		return nil, errors.New("в тесте нет вопросов")
+	}
+
+	now := time.Now()
+	attempt, err := s.activeAttempt(testID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt != nil && attemptExpired(attempt, now) {
+		if err := s.finishAttempt(attempt, test, nil, now); err != nil {
+			return nil, err
+		}
+		attempt = nil
+	}
+
+	if attempt == nil {
+		attempts, err := completedAttempts(s.db, testID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkAttemptPolicy(computeStanding(test, attempts, now)); err != nil {
+			return nil, err
+		}
+
+		attempt = &models.UserTestResult{
+			UserID:    userID,
+			TestID:    testID,
+			StartedAt: now,
+			Seed:      rand.Int63(),
+		}
+
+		paper, err := s.assemblePaper(test, test.Rules, userID, attempt.Seed)
+		if err != nil {
+			return nil, err
+		}
+		paperJSON, err := json.Marshal(paper)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сохранения билета: %w", err)
+		}
+		attempt.QuestionIDs = string(paperJSON)
+
+		if test.TimeLimit > 0 {
+			deadline := now.Add(time.Duration(test.TimeLimit) * time.Minute)
+			attempt.Deadline = &deadline
+		}
+
+		// Параллельный запрос мог уже создать попытку: уникальный индекс по
+		// незавершённым попыткам не даст создать вторую, берём существующую
+		result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(attempt)
+		if result.Error != nil {
+			return nil, fmt.Errorf("ошибка создания попытки: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			if attempt, err = s.activeAttempt(testID, userID); err != nil {
+				return nil, err
+			}
+			if attempt == nil {
+				return nil, errors.New("не удалось начать тест, попробуйте ещё раз")
+			}
+		}
+	}
+
+	questions, err := s.attemptQuestions(attempt)
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := savedAnswers(s.db, attempt.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	shuffleAnswers := test.ShuffleQuestions || len(test.Rules) > 0
+	return &TestAttemptView{
+		ID:           attempt.ID,
+		TestID:       attempt.TestID,
+		StartedAt:    attempt.StartedAt,
+		Deadline:     attempt.Deadline,
+		ServerTime:   now,
+		Test:         newTestView(test, len(questions)),
+		Questions:    newTestQuestionViews(questions, attemptSeed(attempt), shuffleAnswers),
+		SavedAnswers: saved,
+	}, nil
+}
+
+// SubmitTest проверяет ответы текущей попытки и завершает её. Ответы,
+// присланные позже дедлайна с учётом запаса, не учитываются: попытка
+// завершается с автосохранёнными ответами и возвращается ErrTestTimeExpired.
+// Результат содержит итог по тесту и, если тест его показывает, разбор
+// вопросов с верными ответами
+func (s *TestService) SubmitTest(testID, userID uint, req SubmitTestRequest) (*TestResultView, error) {
+	attempt, err := s.activeAttempt(testID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == nil {
+		return nil, errTestNotStarted
+	}
+
+	// Тест могли отключить или удалить во время попытки, начатую попытку всё равно проверяем
+	var test models.Test
+	if err := s.db.Unscoped().First(&test, testID).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения теста: %w", err)
+	}
+
+	now := time.Now()
+	answers := req.Answers
+	expired := attemptExpired(attempt, now)
+	if expired {
+		answers = nil
+	}
+
+	if err := s.finishAttempt(attempt, &test, answers, now); err != nil {
+		return nil, err
+	}
+	if err := s.db.First(attempt, attempt.ID).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения результата: %w", err)
+	}
+
+	attempts, err := completedAttempts(s.db, testID, userID)
+	if err != nil {
+		return nil, err
+	}
+	standing := computeStanding(&test, attempts, now)
+
+	var questions []models.TestQuestion
+	if feedbackVisible(&test, standing) {
+		if questions, err = s.attemptQuestions(attempt); err != nil {
+			return nil, err
+		}
+	}
+	view := newTestResultView(attempt, questions)
+	view.Standing = &standing
+
+	if expired {
+		return &view, ErrTestTimeExpired
+	}
+	return &view, nil
+}
+
+// GetTestResults возвращает завершённые попытки пользователя по тесту, новые
+// первыми, и итог по политике зачёта. Разбор вопросов отдаётся, когда тест
+// сдан или попытки закончились, а при ограниченных попытках и ShowFeedback — сразу
+func (s *TestService) GetTestResults(testID, userID uint) ([]TestResultView, *TestStanding, error) {
+	var test models.Test
+	if err := s.db.Unscoped().First(&test, testID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("тест не найден")
+		}
+		return nil, nil, fmt.Errorf("ошибка получения теста: %w", err)
+	}
+
+	results, err := completedAttempts(s.db, testID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	standing := computeStanding(&test, results, time.Now())
+	showReview := feedbackVisible(&test, standing)
+
+	views := make([]TestResultView, 0, len(results))
+	for i := len(results) - 1; i >= 0; i-- {
+		var questions []models.TestQuestion
+		if showReview {
+			if questions, err = s.attemptQuestions(&results[i]); err != nil {
+				return nil, nil, err
+			}
+		}
+		views = append(views, newTestResultView(&results[i], questions))
+	}
+	return views, &standing, nil
+}
+
+// GetUserTestResults возвращает все завершённые попытки пользователя с данными тестов
+func (s *TestService) GetUserTestResults(userID uint) ([]TestResultView, error) {
+	var results []models.UserTestResult
+	if err := s.db.Preload("Test", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).
+		Where("user_id = ? AND completed_at IS NOT NULL", userID).
+		Order("started_at DESC").
+		Find(&results).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения результатов: %w", err)
+	}
+
+	views := make([]TestResultView, 0, len(results))
+	for i := range results {
+		view := newTestResultView(&results[i], nil)
+		test := newTestView(&results[i].Test, 0)
+		view.Test = &test
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// activeAttempt возвращает незавершённую попытку пользователя или nil
+func (s *TestService) activeAttempt(testID, userID uint) (*models.UserTestResult, error) {
+	var attempt models.UserTestResult
+	err := s.db.Where("test_id = ? AND user_id = ? AND completed_at IS NULL", testID, userID).
+		First(&attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения попытки: %w", err)
+	}
+	return &attempt, nil
+}
+
+// loadQuestions загружает вопросы теста с вариантами ответов в порядке, заданном автором
+func (s *TestService) loadQuestions(testID uint) ([]models.TestQuestion, error) {
+	var questions []models.TestQuestion
+	if err := s.db.Preload("Answers", func(db *gorm.DB) *gorm.DB {
+		return db.Order(`"order" ASC, id ASC`)
+	}).
+		Where("test_id = ?", testID).
+		Order(`"order" ASC, id ASC`).
+		Find(&questions).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения вопросов: %w", err)
+	}
+	return questions, nil
+}
+
+// loadRules загружает правила набора билета теста
+func (s *TestService) loadRules(testID uint) ([]models.TestRule, error) {
+	var rules []models.TestRule
+	if err := s.db.Where("test_id = ?", testID).
+		Order(`"order" ASC, id ASC`).
+		Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения правил теста: %w", err)
+	}
+	return rules, nil
+}
+
+// paperSize возвращает число вопросов в билете теста
+func paperSize(test *models.Test) int {
+	size := len(test.Questions)
+	for _, rule := range test.Rules {
+		size += rule.Count
+	}
+	return size
+}
+
+// finishAttempt проверяет ответы и завершает попытку. На вопросы без
+// присланного ответа берётся автосохранённый. Баллы теста начисляются только
+// за первое успешное прохождение. Завершение выполняется условным
+// обновлением, поэтому повторная отправка той же попытки не засчитывается
+func (s *TestService) finishAttempt(attempt *models.UserTestResult, test *models.Test, answers []SubmittedTestAnswer, now time.Time) error {
+	questions, err := s.attemptQuestions(attempt)
+	if err != nil {
+		return err
+	}
+	saved, err := savedAnswers(s.db, attempt.ID)
+	if err != nil {
+		return err
+	}
+	answers = mergeAnswers(saved, answers)
+
+	graded, score, maxScore := s.gradeAnswers(questions, answers, attemptSeed(attempt))
+	percentage := 0.0
+	if maxScore > 0 {
+		percentage = math.Round(float64(score)*10000/float64(maxScore)) / 100
+	}
+	passed := maxScore > 0 && percentage >= float64(test.PassScore)
+
+	// Время после дедлайна в затраченное не входит
+	end := now
+	if attempt.Deadline != nil && end.After(*attempt.Deadline) {
+		end = *attempt.Deadline
+	}
+	timeSpent := int(end.Sub(attempt.StartedAt).Seconds())
+
+	// Время на вопросе присылает клиент, больше длительности попытки оно быть не может
+	for i := range graded {
+		if graded[i].TimeSpent > timeSpent {
+			graded[i].TimeSpent = timeSpent
+		}
+	}
+
+	answersJSON, err := json.Marshal(graded)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации ответов: %w", err)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.UserTestResult{}).
+			Where("id = ? AND completed_at IS NULL", attempt.ID).
+			Updates(map[string]interface{}{
+				"score":        score,
+				"max_score":    maxScore,
+				"percentage":   percentage,
+				"is_passed":    passed,
+				"time_spent":   timeSpent,
+				"answers":      string(answersJSON),
+				"completed_at": now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("ошибка сохранения результата: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("попытка уже завершена")
+		}
+		if err := tx.Where("attempt_id = ?", attempt.ID).Delete(&models.TestAttemptAnswer{}).Error; err != nil {
+			return fmt.Errorf("ошибка удаления сохранённых ответов: %w", err)
+		}
+
+		if !passed || test.Points <= 0 {
+			return nil
+		}
+
+		var passedBefore int64
+		if err := tx.Model(&models.UserTestResult{}).
+			Where("user_id = ? AND test_id = ? AND is_passed = ? AND id <> ?", attempt.UserID, test.ID, true, attempt.ID).
+			Count(&passedBefore).Error; err != nil {
+			return fmt.Errorf("ошибка проверки прошлых попыток: %w", err)
+		}
+		if passedBefore > 0 {
+			return nil
+		}
+
+		if err := tx.Model(&models.User{}).
+			Where("id = ?", attempt.UserID).
+			Update("points", gorm.Expr("points + ?", test.Points)).Error; err != nil {
+			return fmt.Errorf("ошибка начисления баллов: %w", err)
+		}
+		return tx.Model(&models.UserTestResult{}).
+			Where("id = ?", attempt.ID).
+			Update("points_awarded", test.Points).Error
+	})
+}
+
+// attemptExpired сообщает, истекло ли время попытки с учётом запаса
+func attemptExpired(attempt *models.UserTestResult, now time.Time) bool {
+	return attempt.Deadline != nil && now.After(attempt.Deadline.Add(testDeadlineGrace))
+}
@@ -0,0 +1,642 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxTestQuestions ограничивает размер одного теста
+const maxTestQuestions = 200
+
+type CreateTestRequest struct {
+	Title            string                `json:"title" binding:"required,min=2,max=200"`
+	Description      string                `json:"description"`
+	TimeLimit        *int                  `json:"time_limit" binding:"omitempty,min=0"` // в минутах, 0 — без ограничения
+	PassScore        *int                  `json:"pass_score" binding:"omitempty,min=0,max=100"`
+	Points           *int                  `json:"points" binding:"omitempty,min=0"`
+	IsActive         *bool                 `json:"is_active"`
+	ShuffleQuestions bool                  `json:"shuffle_questions"`
+	MaxAttempts      int                   `json:"max_attempts" binding:"min=0"`    // 0 — без ограничения
+	RetakeCooldown   int                   `json:"retake_cooldown" binding:"min=0"` // в минутах
+	ScoringPolicy    string                `json:"scoring_policy" binding:"omitempty,oneof=best last average"`
+	ShowFeedback     *bool                 `json:"show_feedback"`
+	Questions        []TestQuestionRequest `json:"questions" binding:"dive"`
+	Rules            []TestRuleRequest     `json:"rules" binding:"dive"`
+}
+
+type UpdateTestRequest struct {
+	Title            string  `json:"title" binding:"omitempty,min=2,max=200"`
+	Description      *string `json:"description"`
+	TimeLimit        *int    `json:"time_limit" binding:"omitempty,min=0"`
+	PassScore        *int    `json:"pass_score" binding:"omitempty,min=0,max=100"`
+	Points           *int    `json:"points" binding:"omitempty,min=0"`
+	IsActive         *bool   `json:"is_active"`
+	ShuffleQuestions *bool   `json:"shuffle_questions"`
+	MaxAttempts      *int    `json:"max_attempts" binding:"omitempty,min=0"`
+	RetakeCooldown   *int    `json:"retake_cooldown" binding:"omitempty,min=0"`
+	ScoringPolicy    string  `json:"scoring_policy" binding:"omitempty,oneof=best last average"`
+	ShowFeedback     *bool   `json:"show_feedback"`
+	// Если передан, заменяет вопросы теста: вопросы и варианты с ID обновляются,
+	// без ID создаются, отсутствующие удаляются. Порядок массива задаёт порядок вопросов
+	Questions []TestQuestionRequest `json:"questions" binding:"omitempty,dive"`
+	// Если передан, заменяет правила выбора вопросов из банков
+	Rules []TestRuleRequest `json:"rules" binding:"omitempty,dive"`
+}
+
+// TestQuestionRequest вопрос теста. Порядок вариантов в массиве задаёт порядок
+// показа, а для ordering — верный порядок
+type TestQuestionRequest struct {
+	ID            uint                `json:"id"`
+	Type          models.QuestionType `json:"type" binding:"omitempty,oneof=single_choice multiple_choice text code_output ordering matching code"`
+	Question      string              `json:"question" binding:"required"`
+	Topic         string              `json:"topic" binding:"max=50"`
+	Difficulty    models.ProblemLevel `json:"difficulty" binding:"omitempty,oneof=easy medium hard"`
+	Explanation   string              `json:"explanation"`
+	Code          string              `json:"code"`
+	AnswerPattern string              `json:"answer_pattern"`
+	CaseSensitive bool                `json:"case_sensitive"`
+	ProblemID     *uint               `json:"problem_id"`
+	Points        int                 `json:"points" binding:"omitempty,min=1"`
+	Answers       []TestAnswerRequest `json:"answers" binding:"dive"`
+}
+
+type TestAnswerRequest struct {
+	ID        uint   `json:"id"`
+	Answer    string `json:"answer" binding:"required"`
+	MatchText string `json:"match_text"`
+	IsCorrect bool   `json:"is_correct"`
+}
+
+// TestRuleRequest правило набора билета из банка вопросов
+type TestRuleRequest struct {
+	BankID     uint                `json:"bank_id" binding:"required"`
+	Topic      string              `json:"topic" binding:"max=50"`
+	Difficulty models.ProblemLevel `json:"difficulty" binding:"omitempty,oneof=easy medium hard"`
+	Count      int                 `json:"count" binding:"required,min=1"`
+}
+
+type ReorderTestQuestionsRequest struct {
+	QuestionIDs []uint `json:"question_ids" binding:"required"`
+}
+
+// GetTestsForAdmin возвращает все неудалённые тесты, включая неактивные
+func (s *TestService) GetTestsForAdmin() ([]TestView, error) {
+	var tests []models.Test
+	if err := s.db.Preload("Questions").Preload("Rules").Order("created_at DESC").Find(&tests).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения тестов: %w", err)
+	}
+
+	views := make([]TestView, 0, len(tests))
+	for i := range tests {
+		views = append(views, newTestView(&tests[i], paperSize(&tests[i])))
+	}
+	return views, nil
+}
+
+// CreateTest создаёт тест вместе с вопросами и вариантами ответов
+func (s *TestService) CreateTest(req *CreateTestRequest) (*models.Test, error) {
+	if len(req.Questions) > maxTestQuestions {
+		return nil, fmt.Errorf("в тесте может быть не больше %d вопросов", maxTestQuestions)
+	}
+	questions, err := s.prepareTestQuestions(req.Questions, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateTestRules(req.Rules); err != nil {
+		return nil, err
+	}
+	if err := validateFeedbackPolicy(req.ShowFeedback != nil && *req.ShowFeedback, req.MaxAttempts); err != nil {
+		return nil, err
+	}
+
+	test := &models.Test{
+		Title:            req.Title,
+		Description:      req.Description,
+		TimeLimit:        30,
+		PassScore:        70,
+		Points:           50,
+		ShuffleQuestions: req.ShuffleQuestions,
+		MaxAttempts:      req.MaxAttempts,
+		RetakeCooldown:   req.RetakeCooldown,
+		ScoringPolicy:    models.TestScoringBest,
+	}
+	if req.ScoringPolicy != "" {
+		test.ScoringPolicy = models.TestScoringPolicy(req.ScoringPolicy)
+	}
+	if req.TimeLimit != nil {
+		test.TimeLimit = *req.TimeLimit
+	}
+	if req.PassScore != nil {
+		test.PassScore = *req.PassScore
+	}
+	if req.Points != nil {
+		test.Points = *req.Points
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(test).Error; err != nil {
+			return fmt.Errorf("ошибка создания теста: %w", err)
+		}
+		// Нулевые значения и неактивность заменяются значениями по умолчанию при
+		// создании, поэтому сохраняем их явно
+		if err := tx.Model(test).Updates(map[string]interface{}{
+			"time_limit":    test.TimeLimit,
+			"pass_score":    test.PassScore,
+			"points":        test.Points,
+			"is_active":     req.IsActive == nil || *req.IsActive,
+			"show_feedback": req.ShowFeedback != nil && *req.ShowFeedback,
+		}).Error; err != nil {
+			return fmt.Errorf("ошибка создания теста: %w", err)
+		}
+		if err := saveTestQuestions(tx, &test.ID, nil, questions, nil); err != nil {
+			return err
+		}
+		return replaceTestRules(tx, test.ID, req.Rules)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetTestForAdmin(test.ID)
+}
+
+// UpdateTest обновляет настройки теста и, если переданы, его вопросы
+func (s *TestService) UpdateTest(id uint, req *UpdateTestRequest) (*models.Test, error) {
+	test, err := s.GetTestForAdmin(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var questions []models.TestQuestion
+	if req.Questions != nil {
+		if len(req.Questions) > maxTestQuestions {
+			return nil, fmt.Errorf("в тесте может быть не больше %d вопросов", maxTestQuestions)
+		}
+		if questions, err = s.prepareTestQuestions(req.Questions, test.Questions); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.validateTestRules(req.Rules); err != nil {
+		return nil, err
+	}
+	if req.ShowFeedback != nil || req.MaxAttempts != nil {
+		showFeedback, maxAttempts := test.ShowFeedback, test.MaxAttempts
+		if req.ShowFeedback != nil {
+			showFeedback = *req.ShowFeedback
+		}
+		if req.MaxAttempts != nil {
+			maxAttempts = *req.MaxAttempts
+		}
+		if err := validateFeedbackPolicy(showFeedback, maxAttempts); err != nil {
+			return nil, err
+		}
+	}
+
+	updates := map[string]interface{}{}
+	if req.Title != "" {
+		updates["title"] = req.Title
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.TimeLimit != nil {
+		updates["time_limit"] = *req.TimeLimit
+	}
+	if req.PassScore != nil {
+		updates["pass_score"] = *req.PassScore
+	}
+	if req.Points != nil {
+		updates["points"] = *req.Points
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if req.ShuffleQuestions != nil {
+		updates["shuffle_questions"] = *req.ShuffleQuestions
+	}
+	if req.MaxAttempts != nil {
+		updates["max_attempts"] = *req.MaxAttempts
+	}
+	if req.RetakeCooldown != nil {
+		updates["retake_cooldown"] = *req.RetakeCooldown
+	}
+	if req.ScoringPolicy != "" {
+		updates["scoring_policy"] = req.ScoringPolicy
+	}
+	if req.ShowFeedback != nil {
+		updates["show_feedback"] = *req.ShowFeedback
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&models.Test{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+				return fmt.Errorf("ошибка обновления теста: %w", err)
+			}
+		}
+		if req.Questions != nil {
+			if err := saveTestQuestions(tx, &id, nil, questions, test.Questions); err != nil {
+				return err
+			}
+		}
+		if req.Rules == nil {
+			return nil
+		}
+		return replaceTestRules(tx, id, req.Rules)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetTestForAdmin(id)
+}
+
+// DeleteTest скрывает тест. Вопросы и результаты пользователей сохраняются,
+// начатые попытки можно завершить
+func (s *TestService) DeleteTest(id uint) error {
+	result := s.db.Delete(&models.Test{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("ошибка удаления теста: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("тест не найден")
+	}
+	return nil
+}
+
+// ReorderQuestions меняет порядок вопросов теста. Список должен содержать все вопросы
+func (s *TestService) ReorderQuestions(id uint, questionIDs []uint) (*models.Test, error) {
+	test, err := s.GetTestForAdmin(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(questionIDs) != len(test.Questions) {
+		return nil, errors.New("список должен содержать все вопросы теста")
+	}
+	existing := make(map[uint]bool, len(test.Questions))
+	for _, question := range test.Questions {
+		existing[question.ID] = true
+	}
+	seen := make(map[uint]bool, len(questionIDs))
+	for _, questionID := range questionIDs {
+		if !existing[questionID] {
+			return nil, fmt.Errorf("вопрос %d не относится к тесту", questionID)
+		}
+		if seen[questionID] {
+			return nil, fmt.Errorf("вопрос %d указан дважды", questionID)
+		}
+		seen[questionID] = true
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for i, questionID := range questionIDs {
+			if err := tx.Model(&models.TestQuestion{}).
+				Where("id = ?", questionID).
+				Update("order", i).Error; err != nil {
+				return fmt.Errorf("ошибка изменения порядка вопросов: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetTestForAdmin(id)
+}
+
+// prepareTestQuestions проверяет вопросы и собирает модели для сохранения.
+// Вывод фрагментов code_output вычисляется здесь, до транзакции: запуск в
+// sandbox занимает секунды. Для неизменённого фрагмента берётся прежний вывод
+func (s *TestService) prepareTestQuestions(reqs []TestQuestionRequest, existing []models.TestQuestion) ([]models.TestQuestion, error) {
+
+	existingQuestions := make(map[uint]models.TestQuestion, len(existing))
+	existingAnswers := make(map[uint]models.TestAnswer, len(existing))
+	for _, question := range existing {
+		existingQuestions[question.ID] = question
+		for _, answer := range question.Answers {
+			existingAnswers[answer.ID] = answer
+		}
+	}
+
+	seen := make(map[uint]bool, len(reqs))
+	questions := make([]models.TestQuestion, 0, len(reqs))
+	for i, req := range reqs {
+		if req.ID != 0 {
+			if _, ok := existingQuestions[req.ID]; !ok {
+				return nil, fmt.Errorf("вопрос %d не относится к тесту", req.ID)
+			}
+			if seen[req.ID] {
+				return nil, fmt.Errorf("вопрос %d указан дважды", req.ID)
+			}
+			seen[req.ID] = true
+		}
+		if req.Type == "" {
+			req.Type = models.QuestionTypeSingleChoice
+		}
+		if req.Points == 0 {
+			req.Points = 1
+		}
+
+		if err := s.validateTestQuestion(&req); err != nil {
+			return nil, fmt.Errorf("вопрос %d: %w", i+1, err)
+		}
+
+		question := models.TestQuestion{
+			ID:            req.ID,
+			Type:          req.Type,
+			Question:      req.Question,
+			Topic:         req.Topic,
+			Difficulty:    req.Difficulty,
+			Explanation:   req.Explanation,
+			Code:          req.Code,
+			AnswerPattern: req.AnswerPattern,
+			CaseSensitive: req.CaseSensitive,
+			ProblemID:     req.ProblemID,
+			Order:         i,
+			Points:        req.Points,
+		}
+		if req.Type != models.QuestionTypeCode {
+			question.ProblemID = nil
+		}
+		if previous, ok := existingQuestions[req.ID]; ok {
+			question.CreatedAt = previous.CreatedAt
+			question.PlacementDifficulty = previous.PlacementDifficulty
+			question.PlacementAnswers = previous.PlacementAnswers
+		}
+
+		for j, answerReq := range req.Answers {
+			previous, ok := existingAnswers[answerReq.ID]
+			if answerReq.ID != 0 && (!ok || previous.QuestionID != req.ID) {
+				return nil, fmt.Errorf("вопрос %d: вариант %d не относится к вопросу", i+1, answerReq.ID)
+			}
+			question.Answers = append(question.Answers, models.TestAnswer{
+				ID:        answerReq.ID,
+				Answer:    answerReq.Answer,
+				MatchText: answerReq.MatchText,
+				IsCorrect: answerReq.IsCorrect,
+				Order:     j,
+				CreatedAt: previous.CreatedAt,
+			})
+		}
+
+		if req.Type == models.QuestionTypeCodeOutput {
+			if previous, ok := existingQuestions[req.ID]; ok && previous.Code == req.Code && previous.ExpectedOutput != "" {
+				question.ExpectedOutput = previous.ExpectedOutput
+			} else {
+				output, err := s.runCodeOutput(req.Code)
+				if err != nil {
+					return nil, fmt.Errorf("вопрос %d: %w", i+1, err)
+				}
+				question.ExpectedOutput = output
+			}
+		}
+
+		questions = append(questions, question)
+	}
+
+	return questions, nil
+}
+
+// validateTestQuestion проверяет, что вопрос можно проверить автоматически
+func (s *TestService) validateTestQuestion(req *TestQuestionRequest) error {
+	correct := 0
+	for _, answer := range req.Answers {
+		if answer.IsCorrect {
+			correct++
+		}
+	}
+
+	switch req.Type {
+	case models.QuestionTypeSingleChoice:
+		if len(req.Answers) < 2 {
+			return errors.New("нужно не меньше двух вариантов ответа")
+		}
+		if correct != 1 {
+			return errors.New("у вопроса с одним ответом должен быть ровно один верный вариант")
+		}
+	case models.QuestionTypeMultipleChoice:
+		if len(req.Answers) < 2 {
+			return errors.New("нужно не меньше двух вариантов ответа")
+		}
+		if correct == 0 {
+			return errors.New("нужен хотя бы один верный вариант")
+		}
+	case models.QuestionTypeText:
+		if len(req.Answers) == 0 && req.AnswerPattern == "" {
+			return errors.New("нужен допустимый ответ или регулярное выражение")
+		}
+		if req.AnswerPattern != "" {
+			if _, err := regexp.Compile(req.AnswerPattern); err != nil {
+				return fmt.Errorf("неверное регулярное выражение: %v", err)
+			}
+		}
+	case models.QuestionTypeCodeOutput:
+		if strings.TrimSpace(req.Code) == "" {
+			return errors.New("не задан фрагмент кода")
+		}
+	case models.QuestionTypeOrdering:
+		if len(req.Answers) < 2 {
+			return errors.New("нужно не меньше двух элементов для упорядочивания")
+		}
+	case models.QuestionTypeMatching:
+		if len(req.Answers) < 2 {
+			return errors.New("нужно не меньше двух пар для сопоставления")
+		}
+		for _, answer := range req.Answers {
+			if strings.TrimSpace(answer.MatchText) == "" {
+				return fmt.Errorf("у варианта %q не задана пара", answer.Answer)
+			}
+		}
+	case models.QuestionTypeCode:
+		if req.ProblemID == nil {
+			return errors.New("не задана задача для проверки кода")
+		}
+		var count int64
+		if err := s.db.Model(&models.Problem{}).Where("id = ?", *req.ProblemID).Count(&count).Error; err != nil {
+			return fmt.Errorf("ошибка проверки задачи: %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("задача %d не найдена", *req.ProblemID)
+		}
+	}
+	return nil
+}
+
+// saveTestQuestions сохраняет вопросы теста или банка: обновляет существующие,
+// создаёт новые и удаляет вопросы и варианты, которых больше нет. Удаление
+// мягкое: на них ссылаются ответы прошлых попыток. Вопрос или вариант, у
+// которого изменилось что-то влияющее на проверку, не обновляется, а
+// создаётся заново с удалением прежней версии: начатые и завершённые попытки
+// проверяются и разбираются по той версии, что была при их начале
+func saveTestQuestions(tx *gorm.DB, testID, bankID *uint, questions, existing []models.TestQuestion) error {
+	versionChangedQuestions(questions, existing)
+
+	keptQuestions := make(map[uint]bool, len(questions))
+	keptAnswers := make(map[uint]bool)
+	for _, question := range questions {
+		keptQuestions[question.ID] = true
+		for _, answer := range question.Answers {
+			keptAnswers[answer.ID] = true
+		}
+	}
+
+	var staleQuestions, staleAnswers []uint
+	for _, question := range existing {
+		if !keptQuestions[question.ID] {
+			staleQuestions = append(staleQuestions, question.ID)
+		}
+		for _, answer := range question.Answers {
+			if !keptAnswers[answer.ID] {
+				staleAnswers = append(staleAnswers, answer.ID)
+			}
+		}
+	}
+	if len(staleAnswers) > 0 {
+		if err := tx.Delete(&models.TestAnswer{}, staleAnswers).Error; err != nil {
+			return fmt.Errorf("ошибка удаления вариантов ответа: %w", err)
+		}
+	}
+	if len(staleQuestions) > 0 {
+		if err := tx.Delete(&models.TestQuestion{}, staleQuestions).Error; err != nil {
+			return fmt.Errorf("ошибка удаления вопросов: %w", err)
+		}
+	}
+
+	for i := range questions {
+		question := questions[i]
+		answers := question.Answers
+		question.Answers = nil
+		question.TestID = testID
+		question.BankID = bankID
+
+		// Save с нулевым ID создаёт запись, иначе обновляет все поля,
+		// включая нулевые значения вроде CaseSensitive=false
+		if err := tx.Omit(clause.Associations).Save(&question).Error; err != nil {
+			return fmt.Errorf("ошибка сохранения вопроса: %w", err)
+		}
+		for j := range answers {
+			answers[j].QuestionID = question.ID
+			if err := tx.Omit(clause.Associations).Save(&answers[j]).Error; err != nil {
+				return fmt.Errorf("ошибка сохранения варианта ответа: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// versionChangedQuestions сбрасывает ID у вопросов и вариантов, изменённых так,
+// что меняется проверка ответа, чтобы они сохранились новыми версиями
+func versionChangedQuestions(questions, existing []models.TestQuestion) {
+	previousQuestions := make(map[uint]*models.TestQuestion, len(existing))
+	previousAnswers := make(map[uint]*models.TestAnswer)
+	for i := range existing {
+		previousQuestions[existing[i].ID] = &existing[i]
+		for j := range existing[i].Answers {
+			previousAnswers[existing[i].Answers[j].ID] = &existing[i].Answers[j]
+		}
+	}
+
+	for i := range questions {
+		question := &questions[i]
+		previous, ok := previousQuestions[question.ID]
+		if !ok {
+			continue
+		}
+		if questionGradingChanged(question, previous) {
+			question.ID = 0
+			question.CreatedAt = time.Time{}
+			question.PlacementDifficulty = nil
+			question.PlacementAnswers = 0
+			for j := range question.Answers {
+				question.Answers[j].ID = 0
+				question.Answers[j].CreatedAt = time.Time{}
+			}
+			continue
+		}
+		for j := range question.Answers {
+			answer := &question.Answers[j]
+			if previousAnswer, ok := previousAnswers[answer.ID]; ok && answerGradingChanged(answer, previousAnswer) {
+				answer.ID = 0
+				answer.CreatedAt = time.Time{}
+			}
+		}
+	}
+}
+
+// questionGradingChanged сообщает, изменились ли текст вопроса или настройки его проверки
+func questionGradingChanged(question, previous *models.TestQuestion) bool {
+	sameProblem := (question.ProblemID == nil) == (previous.ProblemID == nil) &&
+		(question.ProblemID == nil || *question.ProblemID == *previous.ProblemID)
+	return question.Type != previous.Type ||
+		question.Question != previous.Question ||
+		question.Code != previous.Code ||
+		question.AnswerPattern != previous.AnswerPattern ||
+		question.CaseSensitive != previous.CaseSensitive ||
+		question.ExpectedOutput != previous.ExpectedOutput ||
+		question.Points != previous.Points ||
+		!sameProblem
+}
+
+// answerGradingChanged сообщает, изменились ли текст, верность, порядок или пара варианта
+func answerGradingChanged(answer, previous *models.TestAnswer) bool {
+	return answer.Answer != previous.Answer ||
+		answer.MatchText != previous.MatchText ||
+		answer.IsCorrect != previous.IsCorrect ||
+		answer.Order != previous.Order
+}
+
+// validateTestRules проверяет, что в банках хватает вопросов для каждого правила
+func (s *TestService) validateTestRules(rules []TestRuleRequest) error {
+	total := 0
+	for i, rule := range rules {
+		var bankCount int64
+		if err := s.db.Model(&models.QuestionBank{}).Where("id = ?", rule.BankID).Count(&bankCount).Error; err != nil {
+			return fmt.Errorf("ошибка проверки банка вопросов: %w", err)
+		}
+		if bankCount == 0 {
+			return fmt.Errorf("правило %d: банк вопросов %d не найден", i+1, rule.BankID)
+		}
+
+		var available int64
+		if err := bankQuestionsQuery(s.db, rule.BankID, rule.Topic, rule.Difficulty).Count(&available).Error; err != nil {
+			return fmt.Errorf("ошибка проверки банка вопросов: %w", err)
+		}
+		if int64(rule.Count) > available {
+			return fmt.Errorf("правило %d: в банке %d подходящих вопросов, требуется %d", i+1, available, rule.Count)
+		}
+		total += rule.Count
+	}
+	if total > maxTestQuestions {
+		return fmt.Errorf("в тесте может быть не больше %d вопросов", maxTestQuestions)
+	}
+	return nil
+}
+
+// replaceTestRules заменяет правила набора билета теста
+func replaceTestRules(tx *gorm.DB, testID uint, rules []TestRuleRequest) error {
+	if err := tx.Where("test_id = ?", testID).Delete(&models.TestRule{}).Error; err != nil {
+		return fmt.Errorf("ошибка удаления правил теста: %w", err)
+	}
+	for i, rule := range rules {
+		if err := tx.Create(&models.TestRule{
+			TestID:     testID,
+			BankID:     rule.BankID,
+			Topic:      rule.Topic,
+			Difficulty: rule.Difficulty,
+			Count:      rule.Count,
+			Order:      i,
+		}).Error; err != nil {
+			return fmt.Errorf("ошибка сохранения правил теста: %w", err)
+		}
+	}
+	return nil
+}
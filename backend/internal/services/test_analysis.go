@@ -0,0 +1,288 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Пороги, по которым вопрос помечается как требующий внимания автора
+const (
+	itemGroupShare          = 0.27 // доля сильных и слабых попыток для индекса дискриминации
+	itemMinResponses        = 10   // меньше ответов — статистика ненадёжна, пометки не ставятся
+	itemLowDiscrimination   = 0.2
+	itemTooEasyPercent      = 95
+	itemTooHardPercent      = 20
+	itemUnusedDistractorPct = 5
+)
+
+// Пометки вопросов в анализе
+const (
+	ItemFlagNegativeDiscrimination = "negative_discrimination" // слабые отвечают лучше сильных: возможна ошибка в ключе
+	ItemFlagLowDiscrimination      = "low_discrimination"      // вопрос не отличает сильных от слабых
+	ItemFlagTooEasy                = "too_easy"
+	ItemFlagTooHard                = "too_hard"
+	ItemFlagUnusedDistractor       = "unused_distractor"   // неверный вариант почти никто не выбирает
+	ItemFlagDistractorOverKey      = "distractor_over_key" // неверный вариант выбирают чаще верного: вопрос неоднозначен
+)
+
+// TestItemAnalysis статистика по вопросам теста для автора
+type TestItemAnalysis struct {
+	TestID    uint               `json:"test_id"`
+	Attempts  int                `json:"attempts"` // завершённые попытки, по которым считалась статистика
+	Questions []QuestionAnalysis `json:"questions"`
+}
+
+// QuestionAnalysis статистика по одному вопросу. Процент верных считается по
+// полностью верным ответам, средний балл учитывает частичные. Индекс
+// дискриминации — разница средних долей балла в 27% лучших и 27% худших
+// попыток среди тех, где вопрос встречался; nil, если попыток меньше двух
+type QuestionAnalysis struct {
+	QuestionID     uint                `json:"question_id"`
+	Type           models.QuestionType `json:"type"`
+	Question       string              `json:"question"`
+	BankID         *uint               `json:"bank_id,omitempty"`
+	Responses      int                 `json:"responses"` // попыток, где встретился вопрос
+	Skipped        int                 `json:"skipped"`   // из них без ответа
+	PercentCorrect float64             `json:"percent_correct"`
+	AverageScore   float64             `json:"average_score"` // средняя доля балла, 0–1
+	Discrimination *float64            `json:"discrimination"`
+	AverageTime    float64             `json:"average_time"` // секунд, по ответам с известным временем
+	Options        []OptionAnalysis    `json:"options,omitempty"`
+	Flags          []string            `json:"flags"`
+}
+
+// OptionAnalysis частота выбора варианта в вопросах с выбором ответа
+type OptionAnalysis struct {
+	AnswerID  uint    `json:"answer_id"`
+	Answer    string  `json:"answer"`
+	IsCorrect bool    `json:"is_correct"`
+	Selected  int     `json:"selected"`
+	Percent   float64 `json:"percent"` // от числа ответов на вопрос
+}
+
+// itemResponse ответ на вопрос в одной попытке
+type itemResponse struct {
+	attemptScore float64
+	fraction     float64
+	answer       GradedTestAnswer
+}
+
+// GetTestItemAnalysis считает статистику по вопросам теста из ответов
+// завершённых попыток. Вопросы из банков учитываются в тех попытках, в
+// билет которых они попали
+func (s *TestService) GetTestItemAnalysis(testID uint) (*TestItemAnalysis, error) {
+	var test models.Test
+	if err := s.db.Unscoped().First(&test, testID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("тест не найден")
+		}
+		return nil, fmt.Errorf("ошибка получения теста: %w", err)
+	}
+
+	var attempts []models.UserTestResult
+	if err := s.db.Select("id", "percentage", "answers").
+		Where("test_id = ? AND completed_at IS NOT NULL", testID).
+		Find(&attempts).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения попыток: %w", err)
+	}
+
+	responses := make(map[uint][]itemResponse)
+	for _, attempt := range attempts {
+		var graded []GradedTestAnswer
+		if err := json.Unmarshal([]byte(attempt.Answers), &graded); err != nil {
+			continue
+		}
+		for _, answer := range graded {
+			responses[answer.QuestionID] = append(responses[answer.QuestionID], itemResponse{
+				attemptScore: attempt.Percentage,
+				answer:       answer,
+			})
+		}
+	}
+
+	// Собственные вопросы теста показываются и без ответов, вопросы банков — только встречавшиеся
+	ids := make([]uint, 0, len(responses))
+	for id := range responses {
+		ids = append(ids, id)
+	}
+	// Удалённые вопросы и варианты, на которые есть ответы, тоже анализируются
+	var questions []models.TestQuestion
+	if err := s.db.Unscoped().Preload("Answers", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Order(`"order" ASC, id ASC`)
+	}).
+		Where("(test_id = ? AND deleted_at IS NULL) OR id IN ?", testID, append(ids, 0)).
+		Order(`"order" ASC, id ASC`).
+		Find(&questions).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения вопросов: %w", err)
+	}
+
+	analysis := &TestItemAnalysis{
+		TestID:    testID,
+		Attempts:  len(attempts),
+		Questions: make([]QuestionAnalysis, 0, len(questions)),
+	}
+	for i := range questions {
+		analysis.Questions = append(analysis.Questions, analyzeQuestion(&questions[i], responses[questions[i].ID]))
+	}
+	return analysis, nil
+}
+
+// analyzeQuestion считает статистику вопроса по его ответам
+func analyzeQuestion(question *models.TestQuestion, responses []itemResponse) QuestionAnalysis {
+	result := QuestionAnalysis{
+		QuestionID: question.ID,
+		Type:       question.Type,
+		Question:   question.Question,
+		BankID:     question.BankID,
+		Responses:  len(responses),
+		Flags:      []string{},
+	}
+
+	withOptions := question.Type == models.QuestionTypeSingleChoice || question.Type == models.QuestionTypeMultipleChoice
+	selected := make(map[uint]int)
+	correct, timed, totalTime := 0, 0, 0
+	totalFraction := 0.0
+	for i := range responses {
+		answer := responses[i].answer
+		if question.Points > 0 {
+			responses[i].fraction = float64(answer.Points) / float64(question.Points)
+		} else {
+			responses[i].fraction = boolFraction(answer.IsCorrect)
+		}
+		totalFraction += responses[i].fraction
+		if answer.IsCorrect {
+			correct++
+		}
+		if answerSkipped(answer) {
+			result.Skipped++
+		}
+		if answer.TimeSpent > 0 {
+			timed++
+			totalTime += answer.TimeSpent
+		}
+		if withOptions {
+			for _, id := range answer.AnswerIDs {
+				selected[id]++
+			}
+		}
+	}
+
+	if len(responses) > 0 {
+		result.PercentCorrect = roundTo(float64(correct)*100/float64(len(responses)), 2)
+		result.AverageScore = roundTo(totalFraction/float64(len(responses)), 3)
+	}
+	if timed > 0 {
+		result.AverageTime = roundTo(float64(totalTime)/float64(timed), 1)
+	}
+	result.Discrimination = discriminationIndex(responses)
+
+	if withOptions {
+		result.Options = make([]OptionAnalysis, 0, len(question.Answers))
+		for _, option := range question.Answers {
+			// Прежние версии варианта показываются, только если их выбирали
+			if option.DeletedAt.Valid && selected[option.ID] == 0 {
+				continue
+			}
+			analysis := OptionAnalysis{
+				AnswerID:  option.ID,
+				Answer:    option.Answer,
+				IsCorrect: option.IsCorrect,
+				Selected:  selected[option.ID],
+			}
+			if len(responses) > 0 {
+				analysis.Percent = roundTo(float64(analysis.Selected)*100/float64(len(responses)), 2)
+			}
+			result.Options = append(result.Options, analysis)
+		}
+	}
+
+	if len(responses) >= itemMinResponses {
+		result.Flags = itemFlags(&result)
+	}
+	return result
+}
+
+// discriminationIndex сравнивает долю балла за вопрос в лучших и худших по
+// общему результату попытках
+func discriminationIndex(responses []itemResponse) *float64 {
+	if len(responses) < 2 {
+		return nil
+	}
+
+	sorted := append([]itemResponse{}, responses...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].attemptScore > sorted[j].attemptScore
+	})
+
+	group := int(math.Round(float64(len(sorted)) * itemGroupShare))
+	if group < 1 {
+		group = 1
+	}
+	upper, lower := 0.0, 0.0
+	for i := 0; i < group; i++ {
+		upper += sorted[i].fraction
+		lower += sorted[len(sorted)-1-i].fraction
+	}
+	index := roundTo((upper-lower)/float64(group), 3)
+	return &index
+}
+
+// itemFlags помечает вопросы, которые стоит проверить автору
+func itemFlags(result *QuestionAnalysis) []string {
+	flags := []string{}
+	if result.Discrimination != nil {
+		if *result.Discrimination < 0 {
+			flags = append(flags, ItemFlagNegativeDiscrimination)
+		} else if *result.Discrimination < itemLowDiscrimination {
+			flags = append(flags, ItemFlagLowDiscrimination)
+		}
+	}
+	if result.PercentCorrect >= itemTooEasyPercent {
+		flags = append(flags, ItemFlagTooEasy)
+	} else if result.PercentCorrect <= itemTooHardPercent {
+		flags = append(flags, ItemFlagTooHard)
+	}
+
+	keyPercent := -1.0
+	for _, option := range result.Options {
+		if option.IsCorrect && (keyPercent < 0 || option.Percent < keyPercent) {
+			keyPercent = option.Percent
+		}
+	}
+	unused, overKey := false, false
+	for _, option := range result.Options {
+		if option.IsCorrect {
+			continue
+		}
+		if option.Percent < itemUnusedDistractorPct {
+			unused = true
+		}
+		if keyPercent >= 0 && option.Percent > keyPercent {
+			overKey = true
+		}
+	}
+	if unused {
+		flags = append(flags, ItemFlagUnusedDistractor)
+	}
+	if overKey {
+		flags = append(flags, ItemFlagDistractorOverKey)
+	}
+	return flags
+}
+
+// answerSkipped сообщает, что на вопрос не ответили
+func answerSkipped(answer GradedTestAnswer) bool {
+	return len(answer.AnswerIDs) == 0 && answer.Text == "" && len(answer.Matches) == 0
+}
+
+func roundTo(value float64, digits int) float64 {
+	scale := math.Pow(10, float64(digits))
+	return math.Round(value*scale) / scale
+}
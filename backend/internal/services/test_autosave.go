@@ -0,0 +1,228 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// attemptSweepBatchSize ограничивает число попыток, завершаемых за один проход:
+// проверка code-вопросов запускает sandbox. Попытка, которую не удалось
+// завершить attemptSweepMaxFailures раз, завершается с нулём баллов
+const (
+	attemptSweepBatchSize   = 50
+	attemptSweepMaxFailures = 5
+)
+
+// SaveTestAnswersRequest ответы на один или несколько вопросов текущей попытки.
+// Повторное сохранение ответа на вопрос заменяет прежний
+type SaveTestAnswersRequest struct {
+	Answers []SubmittedTestAnswer `json:"answers" binding:"required,min=1,dive"`
+}
+
+// TestAutosaveResult подтверждение автосохранения
+type TestAutosaveResult struct {
+	SavedAt  time.Time  `json:"saved_at"`
+	Deadline *time.Time `json:"deadline"`
+}
+
+// SaveTestAnswers автосохраняет ответы текущей попытки. После дедлайна ответы
+// не принимаются: попытка завершается с ранее сохранёнными ответами и
+// возвращается ErrTestTimeExpired
+func (s *TestService) SaveTestAnswers(testID, userID uint, req SaveTestAnswersRequest) (*TestAutosaveResult, error) {
+	attempt, err := s.activeAttempt(testID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == nil {
+		return nil, errTestNotStarted
+	}
+
+	now := time.Now()
+	if attemptExpired(attempt, now) {
+		if err := s.expireAttempt(attempt, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrTestTimeExpired
+	}
+
+	questions, err := s.attemptQuestions(attempt)
+	if err != nil {
+		return nil, err
+	}
+	inPaper := make(map[uint]bool, len(questions))
+	for _, question := range questions {
+		inPaper[question.ID] = true
+	}
+
+	// Один вопрос дважды в запросе сохраняется по последнему ответу: одна
+	// вставка с ON CONFLICT не может обновить строку дважды
+	rows := make([]models.TestAttemptAnswer, 0, len(req.Answers))
+	rowIndex := make(map[uint]int, len(req.Answers))
+	for _, answer := range req.Answers {
+		if !inPaper[answer.QuestionID] {
+			return nil, fmt.Errorf("вопрос %d не входит в попытку", answer.QuestionID)
+		}
+		answerJSON, err := json.Marshal(answer)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сериализации ответа: %w", err)
+		}
+		row := models.TestAttemptAnswer{
+			AttemptID:  attempt.ID,
+			QuestionID: answer.QuestionID,
+			Answer:     string(answerJSON),
+			UpdatedAt:  now,
+		}
+		if i, ok := rowIndex[answer.QuestionID]; ok {
+			rows[i] = row
+			continue
+		}
+		rowIndex[answer.QuestionID] = len(rows)
+		rows = append(rows, row)
+	}
+
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "attempt_id"}, {Name: "question_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"answer", "updated_at"}),
+	}).Create(&rows).Error; err != nil {
+		return nil, fmt.Errorf("ошибка сохранения ответов: %w", err)
+	}
+
+	return &TestAutosaveResult{SavedAt: now, Deadline: attempt.Deadline}, nil
+}
+
+// RunAttemptSweeper периодически завершает брошенные попытки, у которых истёк
+// дедлайн: пользователь закрыл страницу и не вернулся
+func (s *TestService) RunAttemptSweeper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		finished, err := s.FinishExpiredAttempts()
+		if err != nil {
+			log.Printf("Ошибка завершения просроченных попыток тестов: %v", err)
+		} else if finished > 0 {
+			log.Printf("Завершено просроченных попыток тестов: %d", finished)
+		}
+		<-ticker.C
+	}
+}
+
+// FinishExpiredAttempts завершает попытки с истёкшим дедлайном, проверяя
+// автосохранённые ответы. Попытки, которые не удавалось завершить, берутся в
+// последнюю очередь, чтобы не занимать весь проход. Возвращает число
+// завершённых попыток
+func (s *TestService) FinishExpiredAttempts() (int, error) {
+	now := time.Now()
+	var attempts []models.UserTestResult
+	if err := s.db.Where("completed_at IS NULL AND deadline < ?", now.Add(-testDeadlineGrace)).
+		Order("sweep_failures ASC, deadline ASC").
+		Limit(attemptSweepBatchSize).
+		Find(&attempts).Error; err != nil {
+		return 0, fmt.Errorf("ошибка поиска просроченных попыток: %w", err)
+	}
+
+	finished := 0
+	for i := range attempts {
+		attempt := &attempts[i]
+		err := s.expireAttempt(attempt, now)
+		if err == nil {
+			finished++
+			continue
+		}
+
+		log.Printf("Не удалось завершить попытку %d: %v", attempt.ID, err)
+		if attempt.SweepFailures+1 < attemptSweepMaxFailures {
+			if err := s.db.Model(&models.UserTestResult{}).
+				Where("id = ?", attempt.ID).
+				Update("sweep_failures", gorm.Expr("sweep_failures + 1")).Error; err != nil {
+				log.Printf("Не удалось сохранить число сбоев попытки %d: %v", attempt.ID, err)
+			}
+			continue
+		}
+		if err := s.abandonAttempt(attempt, now); err != nil {
+			log.Printf("Не удалось завершить попытку %d с нулём баллов: %v", attempt.ID, err)
+			continue
+		}
+		finished++
+	}
+	return finished, nil
+}
+
+// abandonAttempt завершает с нулём баллов просроченную попытку, которую так и
+// не удалось проверить
+func (s *TestService) abandonAttempt(attempt *models.UserTestResult, now time.Time) error {
+	end := now
+	if attempt.Deadline != nil && end.After(*attempt.Deadline) {
+		end = *attempt.Deadline
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.UserTestResult{}).
+			Where("id = ? AND completed_at IS NULL", attempt.ID).
+			Updates(map[string]interface{}{
+				"score":        0,
+				"percentage":   0,
+				"is_passed":    false,
+				"time_spent":   int(end.Sub(attempt.StartedAt).Seconds()),
+				"answers":      "[]",
+				"completed_at": now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("ошибка сохранения результата: %w", result.Error)
+		}
+		return tx.Where("attempt_id = ?", attempt.ID).Delete(&models.TestAttemptAnswer{}).Error
+	})
+}
+
+// expireAttempt завершает просроченную попытку с автосохранёнными ответами
+func (s *TestService) expireAttempt(attempt *models.UserTestResult, now time.Time) error {
+	// Тест могли отключить или удалить во время попытки
+	var test models.Test
+	if err := s.db.Unscoped().First(&test, attempt.TestID).Error; err != nil {
+		return fmt.Errorf("ошибка получения теста: %w", err)
+	}
+	return s.finishAttempt(attempt, &test, nil, now)
+}
+
+// savedAnswers возвращает автосохранённые ответы попытки
+func savedAnswers(db *gorm.DB, attemptID uint) ([]SubmittedTestAnswer, error) {
+	var rows []models.TestAttemptAnswer
+	if err := db.Where("attempt_id = ?", attemptID).Order("question_id ASC").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения сохранённых ответов: %w", err)
+	}
+
+	answers := make([]SubmittedTestAnswer, 0, len(rows))
+	for _, row := range rows {
+		var answer SubmittedTestAnswer
+		if err := json.Unmarshal([]byte(row.Answer), &answer); err != nil {
+			continue
+		}
+		answer.QuestionID = row.QuestionID
+		answers = append(answers, answer)
+	}
+	return answers, nil
+}
+
+// mergeAnswers дополняет присланные ответы автосохранёнными на вопросы, на
+// которые ответ не прислан
+func mergeAnswers(saved, submitted []SubmittedTestAnswer) []SubmittedTestAnswer {
+	answered := make(map[uint]bool, len(submitted))
+	for _, answer := range submitted {
+		answered[answer.QuestionID] = true
+	}
+
+	merged := append([]SubmittedTestAnswer{}, submitted...)
+	for _, answer := range saved {
+		if !answered[answer.QuestionID] {
+			merged = append(merged, answer)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go-education-platform/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrTestAttemptsExhausted возвращается при попытке начать тест сверх MaxAttempts
+var ErrTestAttemptsExhausted = errors.New("попытки прохождения теста закончились")
+
+// ErrTestCooldown возвращается при попытке пересдать тест до окончания паузы после неудачи
+var ErrTestCooldown = errors.New("пересдача пока недоступна")
+
+// TestStanding итог пользователя по тесту с учётом политики попыток
+type TestStanding struct {
+	Attempts      int                      `json:"attempts"`
+	AttemptsLeft  *int                     `json:"attempts_left"` // nil, если попытки не ограничены
+	ScoringPolicy models.TestScoringPolicy `json:"scoring_policy"`
+	Percentage    float64                  `json:"percentage"` // процент, идущий в зачёт
+	IsPassed      bool                     `json:"is_passed"`
+	NextAttemptAt *time.Time               `json:"next_attempt_at,omitempty"`
+}
+
+// computeStanding подводит итог по завершённым попыткам, упорядоченным от первой к последней
+func computeStanding(test *models.Test, attempts []models.UserTestResult, now time.Time) TestStanding {
+	standing := TestStanding{
+		Attempts:      len(attempts),
+		ScoringPolicy: test.ScoringPolicy,
+	}
+	if standing.ScoringPolicy == "" {
+		standing.ScoringPolicy = models.TestScoringBest
+	}
+	if test.MaxAttempts > 0 {
+		left := test.MaxAttempts - len(attempts)
+		if left < 0 {
+			left = 0
+		}
+		standing.AttemptsLeft = &left
+	}
+	if len(attempts) == 0 {
+		return standing
+	}
+
+	last := attempts[len(attempts)-1]
+	switch standing.ScoringPolicy {
+	case models.TestScoringLast:
+		standing.Percentage = last.Percentage
+		standing.IsPassed = last.IsPassed
+	case models.TestScoringAverage:
+		total := 0.0
+		for _, attempt := range attempts {
+			total += attempt.Percentage
+		}
+		standing.Percentage = math.Round(total/float64(len(attempts))*100) / 100
+		standing.IsPassed = standing.Percentage >= float64(test.PassScore)
+	default:
+		for _, attempt := range attempts {
+			if attempt.Percentage >= standing.Percentage {
+				standing.Percentage = attempt.Percentage
+			}
+			standing.IsPassed = standing.IsPassed || attempt.IsPassed
+		}
+	}
+
+	if test.RetakeCooldown > 0 && !last.IsPassed && last.CompletedAt != nil {
+		next := last.CompletedAt.Add(time.Duration(test.RetakeCooldown) * time.Minute)
+		if next.After(now) {
+			standing.NextAttemptAt = &next
+		}
+	}
+	return standing
+}
+
+// checkAttemptPolicy проверяет, можно ли начать новую попытку
+func checkAttemptPolicy(standing TestStanding) error {
+	if standing.AttemptsLeft != nil && *standing.AttemptsLeft == 0 {
+		return ErrTestAttemptsExhausted
+	}
+	if standing.NextAttemptAt != nil {
+		return fmt.Errorf("%w: следующая попытка будет доступна %s",
+			ErrTestCooldown, standing.NextAttemptAt.Format("02.01.2006 15:04"))
+	}
+	return nil
+}
+
+// feedbackVisible сообщает, можно ли показать разбор попыток с верными
+// ответами. Разбор открывается, когда тест сдан или попытки закончились.
+// ShowFeedback открывает его после каждой попытки, но только при ограниченном
+// числе попыток: иначе ответы можно собрать пустой попыткой и пересдать тест
+func feedbackVisible(test *models.Test, standing TestStanding) bool {
+	if standing.IsPassed || (standing.AttemptsLeft != nil && *standing.AttemptsLeft == 0) {
+		return true
+	}
+	return test.ShowFeedback && test.MaxAttempts > 0
+}
+
+// errFeedbackUnlimited ShowFeedback без ограничения попыток ничего бы не менял
+var errFeedbackUnlimited = errors.New("разбор после каждой попытки можно включить только при ограниченном числе попыток")
+
+// validateFeedbackPolicy отклоняет ShowFeedback при неограниченных попытках,
+// когда feedbackVisible всё равно его не учтёт
+func validateFeedbackPolicy(showFeedback bool, maxAttempts int) error {
+	if showFeedback && maxAttempts == 0 {
+		return errFeedbackUnlimited
+	}
+	return nil
+}
+
+// completedAttempts возвращает завершённые попытки пользователя от первой к последней
+func completedAttempts(db *gorm.DB, testID, userID uint) ([]models.UserTestResult, error) {
+	var attempts []models.UserTestResult
+	if err := db.Where("test_id = ? AND user_id = ? AND completed_at IS NOT NULL", testID, userID).
+		Order("started_at ASC").
+		Find(&attempts).Error; err != nil {
+		return nil, fmt.Errorf("ошибка получения попыток: %w", err)
+	}
+	return attempts, nil
+}
+
+// countPassedTests считает тесты, сданные пользователем по политике зачёта каждого теста
+func countPassedTests(db *gorm.DB, userID uint) (int, error) {
+	var attempts []models.UserTestResult
+	if err := db.Where("user_id = ? AND completed_at IS NOT NULL", userID).
+		Order("started_at ASC").
+		Find(&attempts).Error; err != nil {
+		return 0, fmt.Errorf("ошибка получения результатов тестов: %w", err)
+	}
+	if len(attempts) == 0 {
+		return 0, nil
+	}
+
+	byTest := make(map[uint][]models.UserTestResult)
+	testIDs := make([]uint, 0)
+	for _, attempt := range attempts {
+		if _, ok := byTest[attempt.TestID]; !ok {
+			testIDs = append(testIDs, attempt.TestID)
+		}
+		byTest[attempt.TestID] = append(byTest[attempt.TestID], attempt)
+	}
+
+	// Удалённые тесты не засчитываются
+	var tests []models.Test
+	if err := db.Where("id IN ?", testIDs).Find(&tests).Error; err != nil {
+		return 0, fmt.Errorf("ошибка получения тестов: %w", err)
+	}
+
+	now := time.Now()
+	passed := 0
+	for i := range tests {
+		if computeStanding(&tests[i], byTest[tests[i].ID], now).IsPassed {
+			passed++
+		}
+	}
+	return passed, nil
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strings"
+
+	"go-education-platform/internal/models"
+)
+
+// TestMatch пара в вопросе на сопоставление: вариант AnswerID сопоставлен
+// с парой MatchID. В ответе попытки MatchID — номер пары из TestMatchOption,
+// в проверенном ответе — ID варианта, которому пара принадлежит
+type TestMatch struct {
+	AnswerID uint `json:"answer_id"`
+	MatchID  uint `json:"match_id"`
+}
+
+// gradeAnswers проверяет ответы на вопросы теста. Возвращает проверенные
+// ответы с ID вариантов вместо номеров попытки, набранные и максимальные
+// баллы. Вопрос, который не удалось проверить из-за сбоя sandbox или неверной
+// настройки, оценивается нулём с причиной в Feedback: попытка всё равно
+// должна завершиться
+func (s *TestService) gradeAnswers(questions []models.TestQuestion, answers []SubmittedTestAnswer, seed int64) ([]GradedTestAnswer, int, int) {
+	submitted := make(map[uint]SubmittedTestAnswer, len(answers))
+	for _, answer := range answers {
+		submitted[answer.QuestionID] = answer
+	}
+
+	graded := make([]GradedTestAnswer, 0, len(questions))
+	score, maxScore := 0, 0
+	for i := range questions {
+		question := &questions[i]
+		answer := resolveOptionIDs(question, submitted[question.ID], seed)
+		maxScore += question.Points
+
+		result := GradedTestAnswer{
+			QuestionID: question.ID,
+			AnswerIDs:  uniqueIDs(answer.AnswerIDs),
+			Text:       answer.Text,
+			Matches:    answer.Matches,
+			TimeSpent:  answer.TimeSpent,
+		}
+
+		fraction, feedback, err := s.gradeQuestion(question, answer)
+		if err != nil {
+			log.Printf("Не удалось проверить ответ на вопрос %d: %v", question.ID, err)
+			fraction, feedback = 0, fmt.Sprintf("ответ не удалось проверить: %v", err)
+		}
+
+		// Частичный балл округляется вниз, вопрос засчитан только при полном балле
+		result.Points = int(math.Floor(fraction*float64(question.Points) + 1e-9))
+		result.IsCorrect = fraction >= 1
+		result.Feedback = feedback
+		score += result.Points
+		graded = append(graded, result)
+	}
+
+	return graded, score, maxScore
+}
+
+// gradeQuestion возвращает долю балла за ответ от 0 до 1 и пояснение проверки
+func (s *TestService) gradeQuestion(question *models.TestQuestion, answer SubmittedTestAnswer) (float64, string, error) {
+	switch question.Type {
+	case models.QuestionTypeMultipleChoice:
+		return gradeMultipleChoice(question, answer.AnswerIDs), "", nil
+	case models.QuestionTypeText:
+		return boolFraction(textAnswerMatches(question, answer.Text)), "", nil
+	case models.QuestionTypeCodeOutput:
+		// Вывод фрагмента вычисляется при сохранении вопроса
+		return boolFraction(normalizeOutput(answer.Text) == normalizeOutput(question.ExpectedOutput)), "", nil
+	case models.QuestionTypeOrdering:
+		return gradeOrdering(question, answer.AnswerIDs), "", nil
+	case models.QuestionTypeMatching:
+		return gradeMatching(question, answer.Matches), "", nil
+	case models.QuestionTypeCode:
+		return s.gradeCode(question, answer.Text)
+	default:
+		return gradeSingleChoice(question, answer.AnswerIDs), "", nil
+	}
+}
+
+// gradeSingleChoice засчитывает ответ, если выбраны ровно все верные варианты
+func gradeSingleChoice(question *models.TestQuestion, answerIDs []uint) float64 {
+	correct := correctAnswerIDs(question)
+	selected := uniqueIDs(answerIDs)
+	if len(selected) != len(correct) {
+		return 0
+	}
+	for _, id := range selected {
+		if !correct[id] {
+			return 0
+		}
+	}
+	return 1
+}
+
+// gradeMultipleChoice начисляет долю за каждый выбранный верный вариант и
+// снимает такую же долю за каждый выбранный неверный
+func gradeMultipleChoice(question *models.TestQuestion, answerIDs []uint) float64 {
+	correct := correctAnswerIDs(question)
+	selected := uniqueIDs(answerIDs)
+	if len(correct) == 0 {
+		return boolFraction(len(selected) == 0)
+	}
+
+	hits, misses := 0, 0
+	for _, id := range selected {
+		if correct[id] {
+			hits++
+		} else {
+			misses++
+		}
+	}
+	return math.Max(0, float64(hits-misses)/float64(len(correct)))
+}
+
+// textAnswerMatches сравнивает короткий ответ с допустимыми ответами без учёта
+// лишних пробелов, а если задан AnswerPattern — проверяет его целиком
+func textAnswerMatches(question *models.TestQuestion, text string) bool {
+	normalized := normalizeText(text, question.CaseSensitive)
+	if normalized == "" {
+		return false
+	}
+
+	for _, answer := range question.Answers {
+		if normalizeText(answer.Answer, question.CaseSensitive) == normalized {
+			return true
+		}
+	}
+
+	if question.AnswerPattern != "" {
+		pattern := "^(?:" + question.AnswerPattern + ")$"
+		if !question.CaseSensitive {
+			pattern = "(?i)" + pattern
+		}
+		if re, err := regexp.Compile(pattern); err == nil && re.MatchString(strings.TrimSpace(text)) {
+			return true
+		}
+	}
+	return false
+}
+
+// gradeOrdering засчитывает ответ, если варианты расставлены в порядке Order
+func gradeOrdering(question *models.TestQuestion, answerIDs []uint) float64 {
+	if len(answerIDs) != len(question.Answers) {
+		return 0
+	}
+	for i, answer := range question.Answers {
+		if answerIDs[i] != answer.ID {
+			return 0
+		}
+	}
+	return 1
+}
+
+// gradeMatching начисляет долю балла за каждую верно сопоставленную пару.
+// Пара верна, если у выбранного варианта тот же MatchText
+func gradeMatching(question *models.TestQuestion, matches []TestMatch) float64 {
+	if len(question.Answers) == 0 {
+		return 0
+	}
+
+	matchText := make(map[uint]string, len(question.Answers))
+	for _, answer := range question.Answers {
+		matchText[answer.ID] = answer.MatchText
+	}
+
+	chosen := make(map[uint]uint, len(matches))
+	for _, match := range matches {
+		if _, ok := matchText[match.AnswerID]; ok {
+			chosen[match.AnswerID] = match.MatchID
+		}
+	}
+
+	correct := 0
+	for answerID, matchID := range chosen {
+		if text, ok := matchText[matchID]; ok && text == matchText[answerID] {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(question.Answers))
+}
+
+// runCodeOutput запускает фрагмент кода в sandbox без входных данных и возвращает его вывод
+func (s *TestService) runCodeOutput(code string) (string, error) {
+	outputs, err := s.sandbox.ProduceExpectedOutputs(code, []string{""}, 0)
+	if err != nil {
+		return "", fmt.Errorf("ошибка выполнения фрагмента: %w", err)
+	}
+	return outputs[0], nil
+}
+
+// gradeCode проверяет решение тестами задачи вопроса, доля балла равна баллу проверки
+func (s *TestService) gradeCode(question *models.TestQuestion, code string) (float64, string, error) {
+	if question.ProblemID == nil {
+		return 0, "", errors.New("не задана задача для проверки кода")
+	}
+	if strings.TrimSpace(code) == "" {
+		return 0, "", nil
+	}
+
+	var problem models.Problem
+	if err := s.db.First(&problem, *question.ProblemID).Error; err != nil {
+		return 0, "", fmt.Errorf("задача для проверки кода не найдена: %w", err)
+	}
+
+	result, err := s.sandbox.ExecuteSubmission(&models.UserSubmission{Code: code}, &problem)
+	if err != nil {
+		return 0, "", err
+	}
+
+	feedback := fmt.Sprintf("%s, пройдено тестов: %d из %d", result.Status, result.TestsPassed, result.TestsTotal)
+	if result.Status == models.SubmissionStatusCompileError || result.Status == models.SubmissionStatusConstraintViolation {
+		feedback = fmt.Sprintf("%s: %s", result.Status, truncateText(result.ErrorOutput, 500))
+	}
+	return float64(result.Score) / 100, feedback, nil
+}
+
+func correctAnswerIDs(question *models.TestQuestion) map[uint]bool {
+	correct := make(map[uint]bool)
+	for _, answer := range question.Answers {
+		if answer.IsCorrect {
+			correct[answer.ID] = true
+		}
+	}
+	return correct
+}
+
+// uniqueIDs убирает повторы, сохраняя порядок
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+func boolFraction(ok bool) float64 {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+// normalizeText схлопывает пробелы и при необходимости приводит к нижнему регистру
+func normalizeText(text string, caseSensitive bool) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if !caseSensitive {
+		text = strings.ToLower(text)
+	}
+	return text
+}
+
+// normalizeOutput убирает пробелы в концах строк и пустые строки по краям
+func normalizeOutput(output string) string {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
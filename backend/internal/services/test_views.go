@@ -0,0 +1,313 @@
+package services
+
+import (
+	"encoding/json"
+	"math/rand"
+	"time"
+
+	"go-education-platform/internal/models"
+)
+
+// Представления теста для студентов. Модели теста отдают верные ответы и
+// разборы в JSON, поэтому наружу, кроме админских ручек, отдаются только эти
+// структуры: до завершения попытки в них нет ни IsCorrect, ни Explanation
+
+// TestView описание теста без вопросов
+type TestView struct {
+	ID             uint                     `json:"id"`
+	Title          string                   `json:"title"`
+	Description    string                   `json:"description"`
+	TimeLimit      int                      `json:"time_limit"`
+	PassScore      int                      `json:"pass_score"`
+	Points         int                      `json:"points"`
+	QuestionsCount int                      `json:"questions_count,omitempty"`
+	MaxAttempts    int                      `json:"max_attempts"`
+	RetakeCooldown int                      `json:"retake_cooldown"`
+	ScoringPolicy  models.TestScoringPolicy `json:"scoring_policy"`
+	ShowFeedback   bool                     `json:"show_feedback"`
+}
+
+// TestAnswerView вариант ответа без признака верного
+type TestAnswerView struct {
+	ID     uint   `json:"id"`
+	Answer string `json:"answer"`
+	Order  int    `json:"order"`
+}
+
+// TestMatchOption пара для сопоставления. ID — номер пары в перемешанном списке
+// попытки, с ID вариантов он не связан
+type TestMatchOption struct {
+	ID   uint   `json:"id"`
+	Text string `json:"text"`
+}
+
+// TestQuestionView вопрос без разбора. Варианты отдаются только вопросам с
+// выбором, упорядочиванием и сопоставлением: у text это допустимые ответы.
+// ID вариантов ordering — их номера в перемешанном списке попытки
+type TestQuestionView struct {
+	ID       uint                `json:"id"`
+	Type     models.QuestionType `json:"type"`
+	Question string              `json:"question"`
+	Code     string              `json:"code,omitempty"`
+	Order    int                 `json:"order"`
+	Points   int                 `json:"points"`
+	Answers  []TestAnswerView    `json:"answers,omitempty"`
+	Matches  []TestMatchOption   `json:"matches,omitempty"`
+}
+
+// TestAttemptView незавершённая попытка с вопросами теста. SavedAnswers
+// содержит автосохранённые ответы, чтобы продолжить попытку после перезагрузки
+// страницы, ServerTime — время сервера для расчёта оставшегося времени
+type TestAttemptView struct {
+	ID           uint                  `json:"id"`
+	TestID       uint                  `json:"test_id"`
+	StartedAt    time.Time             `json:"started_at"`
+	Deadline     *time.Time            `json:"deadline"`
+	ServerTime   time.Time             `json:"server_time"`
+	Test         TestView              `json:"test"`
+	Questions    []TestQuestionView    `json:"questions"`
+	SavedAnswers []SubmittedTestAnswer `json:"saved_answers"`
+}
+
+// ReviewedTestAnswer вариант ответа в разборе завершённой попытки
+type ReviewedTestAnswer struct {
+	ID         uint   `json:"id"`
+	Answer     string `json:"answer"`
+	MatchText  string `json:"match_text,omitempty"`
+	Order      int    `json:"order"`
+	IsCorrect  bool   `json:"is_correct"`
+	IsSelected bool   `json:"is_selected"`
+}
+
+// ReviewedTestQuestion вопрос в разборе завершённой попытки. Варианты идут в
+// верном порядке, ответ пользователя — в AnswerIDs, Text и Matches
+type ReviewedTestQuestion struct {
+	ID             uint                 `json:"id"`
+	Type           models.QuestionType  `json:"type"`
+	Question       string               `json:"question"`
+	Code           string               `json:"code,omitempty"`
+	Explanation    string               `json:"explanation"`
+	ExpectedOutput string               `json:"expected_output,omitempty"`
+	Points         int                  `json:"points"`
+	EarnedPoints   int                  `json:"earned_points"`
+	IsCorrect      bool                 `json:"is_correct"`
+	AnswerIDs      []uint               `json:"answer_ids,omitempty"`
+	Text           string               `json:"text,omitempty"`
+	Matches        []TestMatch          `json:"matches,omitempty"`
+	Feedback       string               `json:"feedback,omitempty"`
+	Answers        []ReviewedTestAnswer `json:"answers"`
+}
+
+// TestResultView результат завершённой попытки. Review заполняется, когда
+// пользователь смотрит результаты конкретного теста и тест показывает разбор
+type TestResultView struct {
+	ID            uint                   `json:"id"`
+	TestID        uint                   `json:"test_id"`
+	Score         int                    `json:"score"`
+	MaxScore      int                    `json:"max_score"`
+	Percentage    float64                `json:"percentage"`
+	IsPassed      bool                   `json:"is_passed"`
+	TimeSpent     int                    `json:"time_spent"`
+	PointsAwarded int                    `json:"points_awarded"`
+	StartedAt     time.Time              `json:"started_at"`
+	CompletedAt   *time.Time             `json:"completed_at"`
+	Test          *TestView              `json:"test,omitempty"`
+	Standing      *TestStanding          `json:"standing,omitempty"` // итог по тесту, заполняется при отправке ответов
+	Review        []ReviewedTestQuestion `json:"review,omitempty"`
+}
+
+func newTestView(test *models.Test, questionsCount int) TestView {
+	return TestView{
+		ID:             test.ID,
+		Title:          test.Title,
+		Description:    test.Description,
+		TimeLimit:      test.TimeLimit,
+		PassScore:      test.PassScore,
+		Points:         test.Points,
+		QuestionsCount: questionsCount,
+		MaxAttempts:    test.MaxAttempts,
+		RetakeCooldown: test.RetakeCooldown,
+		ScoringPolicy:  test.ScoringPolicy,
+		ShowFeedback:   test.ShowFeedback,
+	}
+}
+
+// newTestQuestionViews собирает вопросы для попытки. В ordering варианты, а в
+// matching пары перемешиваются всегда: их порядок в базе и есть ответ. Вместо
+// ID им выдаются номера в перемешанном списке, иначе ответ можно восстановить
+// по ID; при проверке номера переводятся обратно resolveOptionIDs. Варианты
+// вопросов с выбором перемешиваются, если shuffleAnswers. Перемешивание
+// зависит от seed, чтобы при продолжении попытки порядок не менялся
+func newTestQuestionViews(questions []models.TestQuestion, seed int64, shuffleAnswers bool) []TestQuestionView {
+	views := make([]TestQuestionView, 0, len(questions))
+	for i := range questions {
+		question := &questions[i]
+		view := TestQuestionView{
+			ID:       question.ID,
+			Type:     question.Type,
+			Question: question.Question,
+			Order:    question.Order,
+			Points:   question.Points,
+		}
+		if question.Type == models.QuestionTypeCodeOutput || question.Type == models.QuestionTypeCode {
+			view.Code = question.Code
+		}
+
+		switch question.Type {
+		case models.QuestionTypeText, models.QuestionTypeCodeOutput, models.QuestionTypeCode:
+			views = append(views, view)
+			continue
+		}
+
+		view.Answers = make([]TestAnswerView, 0, len(question.Answers))
+		switch question.Type {
+		case models.QuestionTypeOrdering:
+			for position, index := range optionPermutation(question, seed) {
+				view.Answers = append(view.Answers, TestAnswerView{
+					ID:     uint(position + 1),
+					Answer: question.Answers[index].Answer,
+				})
+			}
+		case models.QuestionTypeMatching:
+			for _, answer := range question.Answers {
+				view.Answers = append(view.Answers, TestAnswerView{ID: answer.ID, Answer: answer.Answer})
+			}
+			for position, index := range optionPermutation(question, seed) {
+				view.Matches = append(view.Matches, TestMatchOption{
+					ID:   uint(position + 1),
+					Text: question.Answers[index].MatchText,
+				})
+			}
+		default:
+			for _, answer := range question.Answers {
+				view.Answers = append(view.Answers, TestAnswerView{
+					ID:     answer.ID,
+					Answer: answer.Answer,
+					Order:  answer.Order,
+				})
+			}
+			if shuffleAnswers {
+				rng := rand.New(rand.NewSource(seed + int64(question.ID)))
+				rng.Shuffle(len(view.Answers), func(i, j int) {
+					view.Answers[i], view.Answers[j] = view.Answers[j], view.Answers[i]
+				})
+			}
+		}
+
+		views = append(views, view)
+	}
+	return views
+}
+
+// optionPermutation возвращает порядок вариантов ordering и пар matching в
+// попытке: на i-й позиции стоит индекс варианта в question.Answers
+func optionPermutation(question *models.TestQuestion, seed int64) []int {
+	permutation := make([]int, len(question.Answers))
+	for i := range permutation {
+		permutation[i] = i
+	}
+	rng := rand.New(rand.NewSource(seed + int64(question.ID)))
+	rng.Shuffle(len(permutation), func(i, j int) {
+		permutation[i], permutation[j] = permutation[j], permutation[i]
+	})
+	return permutation
+}
+
+// resolveOptionIDs переводит номера вариантов ordering и пар matching, выданные
+// в попытке, в ID вариантов. Неизвестный номер становится нулём и не засчитывается
+func resolveOptionIDs(question *models.TestQuestion, answer SubmittedTestAnswer, seed int64) SubmittedTestAnswer {
+	if question.Type != models.QuestionTypeOrdering && question.Type != models.QuestionTypeMatching {
+		return answer
+	}
+
+	permutation := optionPermutation(question, seed)
+	resolve := func(position uint) uint {
+		if position == 0 || position > uint(len(permutation)) {
+			return 0
+		}
+		return question.Answers[permutation[position-1]].ID
+	}
+
+	if question.Type == models.QuestionTypeOrdering {
+		ids := make([]uint, len(answer.AnswerIDs))
+		for i, position := range answer.AnswerIDs {
+			ids[i] = resolve(position)
+		}
+		answer.AnswerIDs = ids
+		return answer
+	}
+
+	matches := make([]TestMatch, len(answer.Matches))
+	for i, match := range answer.Matches {
+		matches[i] = TestMatch{AnswerID: match.AnswerID, MatchID: resolve(match.MatchID)}
+	}
+	answer.Matches = matches
+	return answer
+}
+
+// newTestResultView собирает результат попытки. Разбор строится, только если
+// переданы вопросы теста; попытка должна быть завершена
+func newTestResultView(result *models.UserTestResult, questions []models.TestQuestion) TestResultView {
+	view := TestResultView{
+		ID:            result.ID,
+		TestID:        result.TestID,
+		Score:         result.Score,
+		MaxScore:      result.MaxScore,
+		Percentage:    result.Percentage,
+		IsPassed:      result.IsPassed,
+		TimeSpent:     result.TimeSpent,
+		PointsAwarded: result.PointsAwarded,
+		StartedAt:     result.StartedAt,
+		CompletedAt:   result.CompletedAt,
+	}
+	if result.CompletedAt == nil || len(questions) == 0 {
+		return view
+	}
+
+	var graded []GradedTestAnswer
+	_ = json.Unmarshal([]byte(result.Answers), &graded)
+	byQuestion := make(map[uint]GradedTestAnswer, len(graded))
+	for _, answer := range graded {
+		byQuestion[answer.QuestionID] = answer
+	}
+
+	view.Review = make([]ReviewedTestQuestion, 0, len(questions))
+	for _, question := range questions {
+		answer := byQuestion[question.ID]
+		selected := make(map[uint]bool, len(answer.AnswerIDs))
+		for _, id := range answer.AnswerIDs {
+			selected[id] = true
+		}
+
+		reviewed := ReviewedTestQuestion{
+			ID:           question.ID,
+			Type:         question.Type,
+			Question:     question.Question,
+			Code:         question.Code,
+			Explanation:  question.Explanation,
+			Points:       question.Points,
+			EarnedPoints: answer.Points,
+			IsCorrect:    answer.IsCorrect,
+			AnswerIDs:    answer.AnswerIDs,
+			Text:         answer.Text,
+			Matches:      answer.Matches,
+			Feedback:     answer.Feedback,
+			Answers:      make([]ReviewedTestAnswer, 0, len(question.Answers)),
+		}
+		if question.Type == models.QuestionTypeCodeOutput {
+			reviewed.ExpectedOutput = question.ExpectedOutput
+		}
+		for _, option := range question.Answers {
+			reviewed.Answers = append(reviewed.Answers, ReviewedTestAnswer{
+				ID:         option.ID,
+				Answer:     option.Answer,
+				MatchText:  option.MatchText,
+				Order:      option.Order,
+				IsCorrect:  option.IsCorrect,
+				IsSelected: selected[option.ID],
+			})
+		}
+		view.Review = append(view.Review, reviewed)
+	}
+	return view
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// errRunTimeout возвращается runBinary при превышении времени выполнения
+var errRunTimeout = errors.New("превышено время выполнения")
+
+// GenerateTestInputs компилирует генератор автора и запускает его для каждой
+// строки аргументов. Каждый запуск должен вывести в stdout один входной файл
+func (s *SandboxService) GenerateTestInputs(generator string, args []string) ([]string, error) {
+	execDir, err := s.makeExecDir()
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseExecDir(execDir)
+
+	generatorPath, err := s.compileHelper(execDir, "generator", generator)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка компиляции генератора: %w", err)
+	}
+
+	inputs := make([]string, 0, len(args))
+	for i, line := range args {
+		stdout, stderr, err := s.runBinary(execDir, generatorPath, strings.Fields(line), "", s.defaultTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("генератор завершился с ошибкой на запуске %d (%q): %v %s", i+1, line, err, stderr)
+		}
+		inputs = append(inputs, stdout)
+	}
+
+	return inputs, nil
+}
+
+// ProduceExpectedOutputs запускает эталонное решение на входных данных и
+// возвращает его вывод в качестве ожидаемых ответов
+func (s *SandboxService) ProduceExpectedOutputs(solution string, inputs []string, timeLimit int) ([]string, error) {
+	if timeLimit <= 0 {
+		timeLimit = 5
+	}
+	timeout := time.Duration(timeLimit) * time.Second
+	if timeout > s.defaultTimeout {
+		timeout = s.defaultTimeout
+	}
+
+	execDir, err := s.makeExecDir()
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseExecDir(execDir)
+
+	solutionPath, err := s.compileHelper(execDir, "solution", solution)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка компиляции эталонного решения: %w", err)
+	}
+
+	outputs := make([]string, 0, len(inputs))
+	for i, input := range inputs {
+		stdout, stderr, err := s.runBinary(execDir, solutionPath, nil, input, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("эталонное решение завершилось с ошибкой на тесте %d: %v %s", i+1, err, stderr)
+		}
+		outputs = append(outputs, stdout)
+	}
+
+	return outputs, nil
+}
+
+// runBinary запускает программу с аргументами и входными данными
+func (s *SandboxService) runBinary(dir, path string, args []string, input string, timeout time.Duration) (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(input)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", stderr.String(), errRunTimeout
+		}
+		return stdout.String(), stderr.String(), err
+	}
+
+	return stdout.String(), stderr.String(), nil
+}
+
+// prepareTestCases собирает итоговый набор тестов задачи: ручные тесты автора,
+// тесты генератора и ответы эталонного решения. Если эталонное решение задано,
+// задача отклоняется при любом превышении времени или расхождении ответов.
+// В интерактивных задачах ответы не заполняются: вердикт выносит интерактор
+func (s *ProblemService) prepareTestCases(testCasesJSON, solution, generator string, generatorArgs []string, checker, interactor string, timeLimit int) (string, error) {
+	var testCases []TestCase
+	if strings.TrimSpace(testCasesJSON) != "" {
+		parsed, err := s.sandbox.ParseTestCases(testCasesJSON)
+		if err != nil {
+			return "", err
+		}
+		// Ранее сгенерированные тесты пересоздаются заново
+		for _, tc := range parsed {
+			if !tc.Generated {
+				testCases = append(testCases, tc)
+			}
+		}
+	}
+
+	hasGenerator := strings.TrimSpace(generator) != ""
+	hasSolution := strings.TrimSpace(solution) != ""
+
+	if hasGenerator {
+		if !hasSolution {
+			return "", errors.New("для генератора тестов необходимо эталонное решение")
+		}
+		if len(generatorArgs) == 0 {
+			return "", errors.New("не заданы аргументы запуска генератора")
+		}
+
+		inputs, err := s.sandbox.GenerateTestInputs(generator, generatorArgs)
+		if err != nil {
+			return "", err
+		}
+		for i, input := range inputs {
+			testCases = append(testCases, TestCase{
+				Name:      fmt.Sprintf("gen-%d", i+1),
+				Input:     input,
+				Generated: true,
+			})
+		}
+	}
+
+	if len(testCases) == 0 {
+		return "", errors.New("задача должна содержать тест-кейсы или генератор")
+	}
+
+	interactive := strings.TrimSpace(interactor) != ""
+
+	if hasSolution {
+		// Заполняем ответы для тестов без ожидаемого вывода
+		var missing []int
+		var inputs []string
+		for i, tc := range testCases {
+			if tc.Expected == "" {
+				missing = append(missing, i)
+				inputs = append(inputs, tc.Input)
+			}
+		}
+		if len(missing) > 0 && !interactive {
+			outputs, err := s.sandbox.ProduceExpectedOutputs(solution, inputs, timeLimit)
+			if err != nil {
+				return "", err
+			}
+			for j, i := range missing {
+				testCases[i].Expected = outputs[j]
+			}
+		}
+
+		opts := ExecutionOptions{Checker: checker, Interactor: interactor, TimeLimit: timeLimit}
+		if err := s.sandbox.ValidateReferenceSolution(solution, testCases, opts); err != nil {
+			return "", err
+		}
+	}
+
+	return encodeTestCases(testCases)
+}
@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Типы строк в результате сравнения
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// maxDiffLines ограничивает суммарный размер сравниваемых текстов в строках.
+// Память сравнения линейна, время в худшем случае квадратично: два совершенно
+// разных текста по 5000 строк сравниваются примерно за 0,25 с
+const maxDiffLines = 10000
+
+// DiffLine одна строка результата построчного сравнения
+type DiffLine struct {
+	Op      string `json:"op"`
+	OldLine int    `json:"old_line,omitempty"` // номер строки в старом тексте, с 1
+	NewLine int    `json:"new_line,omitempty"` // номер строки в новом тексте, с 1
+	Text    string `json:"text"`
+}
+
+// DiffLines сравнивает два текста построчно алгоритмом Майерса в линейной
+// памяти: задача делится пополам по средней змейке кратчайшего пути
+func DiffLines(oldText, newText string) ([]DiffLine, error) {
+	a := splitLines(oldText)
+	b := splitLines(newText)
+	if len(a)+len(b) > maxDiffLines {
+		return nil, errors.New("тексты слишком большие для сравнения")
+	}
+
+	size := 2*((len(a)+len(b)+1)/2) + 3
+	d := &differ{
+		a:       a,
+		b:       b,
+		forward: make([]int, size),
+		reverse: make([]int, size),
+	}
+	d.diff(0, len(a), 0, len(b))
+	return d.lines, nil
+}
+
+// differ состояние сравнения: тексты, результат и общие для всех уровней
+// рекурсии массивы дальних точек диагоналей
+type differ struct {
+	a, b    []string
+	forward []int
+	reverse []int
+	lines   []DiffLine
+}
+
+// diff сравнивает a[aLo:aHi] с b[bLo:bHi] и дописывает результат
+func (d *differ) diff(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.lines = append(d.lines, DiffLine{Op: DiffEqual, OldLine: aLo + 1, NewLine: bLo + 1, Text: d.a[aLo]})
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aHi > aLo && bHi > bLo && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+		suffix++
+	}
+
+	switch {
+	case aLo == aHi:
+		for y := bLo; y < bHi; y++ {
+			d.lines = append(d.lines, DiffLine{Op: DiffInsert, NewLine: y + 1, Text: d.b[y]})
+		}
+	case bLo == bHi:
+		for x := aLo; x < aHi; x++ {
+			d.lines = append(d.lines, DiffLine{Op: DiffDelete, OldLine: x + 1, Text: d.a[x]})
+		}
+	default:
+		// Без общих начала и конца путь содержит не меньше двух правок,
+		// поэтому обе половины строго меньше исходной задачи
+		x, y := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.diff(aLo, x, bLo, y)
+		d.diff(x, aHi, y, bHi)
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.lines = append(d.lines, DiffLine{Op: DiffEqual, OldLine: aHi + i + 1, NewLine: bHi + i + 1, Text: d.a[aHi+i]})
+	}
+}
+
+// middleSnake ищет кратчайший путь одновременно с начала и с конца и
+// возвращает точку, где пути встретились: через неё проходит кратчайший путь
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (int, int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	limit := (n + m + 1) / 2
+	offset := limit + 1
+	forward, reverse := d.forward, d.reverse
+	forward[offset+1] = 0
+	reverse[offset+1] = 0
+
+	for step := 0; step <= limit; step++ {
+		// Прямой проход по диагоналям k = x - y
+		for k := -step; k <= step; k += 2 {
+			var x int
+			if k == -step || (k != step && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			// Обратный путь той же диагонали — на диагонали delta - k с конца
+			if back := delta - k; odd && back >= -(step-1) && back <= step-1 && x+reverse[offset+back] >= n {
+				return aLo + startX, bLo + startY
+			}
+		}
+
+		// Обратный проход: координаты отсчитываются от концов текстов
+		for k := -step; k <= step; k += 2 {
+			var x int
+			if k == -step || (k != step && reverse[offset+k-1] < reverse[offset+k+1]) {
+				x = reverse[offset+k+1]
+			} else {
+				x = reverse[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			reverse[offset+k] = x
+
+			if ahead := delta - k; !odd && ahead >= -step && ahead <= step && x+forward[offset+ahead] >= n {
+				return aHi - startX, bHi - startY
+			}
+		}
+	}
+
+	// Недостижимо: пути встречаются не позже, чем за (n+m+1)/2 шагов
+	return aHi, bHi
+}
+
+// UnifiedDiff форматирует результат сравнения в unified-формате с context строками контекста
+func UnifiedDiff(lines []DiffLine, oldName, newName string, context int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+
+	for i := 0; i < len(lines); {
+		if lines[i].Op == DiffEqual {
+			i++
+			continue
+		}
+
+		// Границы блока изменений вместе с контекстом
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != DiffEqual {
+				end++
+				continue
+			}
+			// Объединяем изменения, если между ними не больше 2*context равных строк
+			next := end
+			for next < len(lines) && lines[next].Op == DiffEqual && next-end < 2*context {
+				next++
+			}
+			if next < len(lines) && lines[next].Op != DiffEqual {
+				end = next
+				continue
+			}
+			break
+		}
+		stop := end + context
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+
+		oldStart, newStart, oldCount, newCount := hunkRange(lines, start, stop)
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, line := range lines[start:stop] {
+			switch line.Op {
+			case DiffEqual:
+				sb.WriteString(" ")
+			case DiffInsert:
+				sb.WriteString("+")
+			case DiffDelete:
+				sb.WriteString("-")
+			}
+			sb.WriteString(line.Text)
+			sb.WriteString("\n")
+		}
+		i = stop
+	}
+
+	return sb.String()
+}
+
+// hunkRange вычисляет начальные строки и размеры блока в старом и новом тексте
+func hunkRange(lines []DiffLine, start, stop int) (oldStart, newStart, oldCount, newCount int) {
+	for _, line := range lines[start:stop] {
+		if line.OldLine > 0 {
+			if oldStart == 0 {
+				oldStart = line.OldLine
+			}
+			oldCount++
+		}
+		if line.NewLine > 0 {
+			if newStart == 0 {
+				newStart = line.NewLine
+			}
+			newCount++
+		}
+	}
+
+	// Для пустой стороны указываем строку, после которой происходит вставка
+	if oldStart == 0 {
+		oldStart = precedingLine(lines, start, func(l DiffLine) int { return l.OldLine })
+	}
+	if newStart == 0 {
+		newStart = precedingLine(lines, start, func(l DiffLine) int { return l.NewLine })
+	}
+	return oldStart, newStart, oldCount, newCount
+}
+
+func precedingLine(lines []DiffLine, start int, number func(DiffLine) int) int {
+	for i := start - 1; i >= 0; i-- {
+		if n := number(lines[i]); n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	"go-education-platform/internal/config"
 	"go-education-platform/internal/database"
@@ -42,13 +43,26 @@ func main() {
 		log.Printf("Предупреждение: не удалось инициализировать уровни платформы: %v", err)
 	}
 
+	// Запускаем планировщик задач дня и недели
+	challengeService := services.NewChallengeService(db)
+	go challengeService.RunScheduler(time.Hour)
+
+	// Сторож зависших проверок работает с теми же sandbox и сервисом задач, что
+	// и обработчики: ему нужно знать, какие отправки и директории сейчас заняты
+	sandboxService := services.NewSandboxService()
+	problemService := services.NewProblemService(db, sandboxService)
+	go services.NewJudgeWatchdog(db, problemService, sandboxService, cfg.Judge).Run()
+
+	// Завершаем брошенные попытки тестов с истёкшим дедлайном
+	go services.NewTestService(db, sandboxService).RunAttemptSweeper(time.Minute)
+
 	// Настраиваем режим Gin
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	// Создаём роутер
-	r := router.Setup(db, cfg)
+	r := router.Setup(db, cfg, sandboxService, problemService)
 
 	// Запускаем сервер
 	port := os.Getenv("SERVER_PORT")